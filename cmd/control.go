@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techtacles/sysmonitoring/internal/control"
+	"github.com/techtacles/sysmonitoring/internal/dashboard"
+)
+
+var controlServer string
+
+var SignalCmd = &cobra.Command{
+	Use:   "signal <pid> <TERM|KILL|STOP|CONT>",
+	Short: "Send a signal to a process through a running dashboard",
+	Long:  `Send a signal to a process on the host of a running dashboard started with --enable-control. The token is read from the ` + dashboard.ControlTokenEnv + ` environment variable.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sig, err := control.ParseSignal(args[1])
+		if err != nil {
+			return err
+		}
+		return sendControl(args[0], "signal", map[string]interface{}{"signal": sig})
+	},
+}
+
+var ReniceCmd = &cobra.Command{
+	Use:   "renice <pid> <nice>",
+	Short: "Change the priority of a process through a running dashboard",
+	Long:  `Change the nice value (-20 to 19) of a process on the host of a running dashboard started with --enable-control. The token is read from the ` + dashboard.ControlTokenEnv + ` environment variable.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nice, err := strconv.Atoi(args[1])
+		if err != nil || nice < control.MinNice || nice > control.MaxNice {
+			return fmt.Errorf("invalid nice %q, must be between %d and %d", args[1], control.MinNice, control.MaxNice)
+		}
+		return sendControl(args[0], "renice", map[string]interface{}{"nice": nice})
+	},
+}
+
+// sendControl posts a control request to the dashboard at controlServer
+func sendControl(pid, action string, body map[string]interface{}) error {
+	if _, err := strconv.ParseInt(pid, 10, 32); err != nil {
+		return fmt.Errorf("invalid pid %q", pid)
+	}
+	token := os.Getenv(dashboard.ControlTokenEnv)
+	if token == "" {
+		return fmt.Errorf("%s is not set", dashboard.ControlTokenEnv)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/api/processes/%s/%s", strings.TrimRight(controlServer, "/"), pid, action)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if u, err := user.Current(); err == nil {
+		req.Header.Set(dashboard.ActorHeader, u.Username)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed: %s: %s", action, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	fmt.Printf("%s pid %s: ok\n", action, pid)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(SignalCmd)
+	rootCmd.AddCommand(ReniceCmd)
+	for _, c := range []*cobra.Command{SignalCmd, ReniceCmd} {
+		c.Flags().StringVarP(&controlServer, "server", "", "http://localhost:8080", "URL of the dashboard to send the request to")
+	}
+}
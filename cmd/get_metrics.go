@@ -1,23 +1,35 @@
 package cmd
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
 	"text/tabwriter"
 	"time"
 
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/techtacles/sysmonitoring/internal/config"
 	"github.com/techtacles/sysmonitoring/internal/logging"
 	"github.com/techtacles/sysmonitoring/internal/metrics/aggregator"
+	"github.com/techtacles/sysmonitoring/internal/metrics/cgroup"
 	"github.com/techtacles/sysmonitoring/internal/metrics/cpu"
 	"github.com/techtacles/sysmonitoring/internal/metrics/disk"
 	"github.com/techtacles/sysmonitoring/internal/metrics/docker"
 	"github.com/techtacles/sysmonitoring/internal/metrics/host"
+	"github.com/techtacles/sysmonitoring/internal/metrics/kernel"
 	"github.com/techtacles/sysmonitoring/internal/metrics/kubernetes"
 	"github.com/techtacles/sysmonitoring/internal/metrics/memory"
 	"github.com/techtacles/sysmonitoring/internal/metrics/network"
+	"github.com/techtacles/sysmonitoring/internal/metrics/pressure"
+	"github.com/techtacles/sysmonitoring/internal/metrics/procgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+	"github.com/techtacles/sysmonitoring/internal/metrics/script"
+	"github.com/techtacles/sysmonitoring/internal/metrics/sensors"
 	"github.com/techtacles/sysmonitoring/internal/metrics/user"
 )
 
@@ -26,53 +38,60 @@ const metricsLogTag = "get_metrics"
 var collectAutoRefresh bool
 var refreshInterval int
 var getKubeconfigPath string
+var getConfigPath string
 
 var GetMetricCmd = &cobra.Command{
 	Use:   "get_metrics",
 	Short: "Get a particular metric",
-	Long:  `Get a particular metric. Can take in args like cpu, disk, host, memory, network, user, docker, all, kubernetes`,
+	Long:  `Get a particular metric. Can take in any registered collector name like cpu, disk, host, memory, network, user, docker, kubernetes, or all`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		newAgg := aggregator.NewAggregator(false, false, getKubeconfigPath)
+
+		cfg, err := config.Load(getConfigPath)
+		if err != nil {
+			return err
+		}
+		for _, check := range cfg.Checks() {
+			if err := newAgg.RegisterScript(check); err != nil {
+				return fmt.Errorf("registering script %s: %w", check.Name, err)
+			}
+		}
+		if err := newAgg.RegisterProcessGroups(cfg.Groups()); err != nil {
+			return fmt.Errorf("registering process groups: %w", err)
+		}
+		if err := newAgg.RegisterCgroups(cfg.Cgroups); err != nil {
+			return fmt.Errorf("registering cgroups: %w", err)
+		}
+		// flags given replace the settings of the config file
+		filter := proctable.DefaultFilter.Merge(cfg.Filter()).Merge(processFilterOverride(cmd))
+		if err := filter.Validate(); err != nil {
+			return fmt.Errorf("invalid process filter: %w", err)
+		}
+		proctable.ListFilter = filter
+
+		available := strings.Join(newAgg.CollectorNames(), ", ")
+
 		if len(args) == 0 {
-			logging.Info(metricsLogTag, "No metrics passed in. Please ensure metrics is either: cpu, disk, host, memory, network, user, docker, all, kubernetes")
+			logging.Info(metricsLogTag, fmt.Sprintf("No metrics passed in. Please ensure metrics is either: %s, all", available))
 			return nil
 		}
 
-		newAgg := aggregator.NewAggregator(false, false, getKubeconfigPath)
 		metricList := args
 		if len(args) == 1 && args[0] == "all" {
-			metricList = []string{"cpu", "memory", "disk", "host", "network", "user", "docker", "kubernetes"}
+			metricList = newAgg.CollectorNames()
 		}
 
 		collectAndPrint := func() {
 			for _, metric := range metricList {
-				var err error
-				switch metric {
-				case "cpu":
-					err = newAgg.CollectCPU()
-				case "memory":
-					err = newAgg.CollectMemory()
-				case "disk":
-					err = newAgg.CollectDisk()
-				case "host":
-					err = newAgg.CollectHost()
-				case "network":
-					err = newAgg.CollectNetwork()
-				case "user":
-					err = newAgg.CollectUser()
-				case "docker":
-					err = newAgg.CollectDocker()
-				case "kubernetes":
-					err = newAgg.CollectKubernetes()
-				}
-
-				if err != nil {
-					logging.Error(metricsLogTag, fmt.Sprintf("failed to collect %s metrics", metric), err)
+				if err := newAgg.Collect(cmd.Context(), metric); err != nil {
+					logging.Error(metricsLogTag, fmt.Sprintf("failed to collect %s metrics (available: %s)", metric, available), err)
 					continue
 				}
 
 				if val, ok := newAgg.GetMetric(metric); ok {
 					printMetric(metric, val)
 				}
+				printRates(newAgg.Rates(metric))
 			}
 		}
 
@@ -91,62 +110,65 @@ var GetMetricCmd = &cobra.Command{
 	},
 }
 
+// printMetric renders a collector result as a table. Results without a
+// dedicated table, such as in-house collectors, are dumped as is.
 func printMetric(name string, result interface{}) {
 	fmt.Printf("\n--- %s Metrics ---\n", strings.ToUpper(name))
-	switch name {
-	case "cpu":
-		if info, ok := result.(cpu.CpuInfo); ok {
-			printCPUTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
-	case "memory":
-		if info, ok := result.(memory.MemoryInfo); ok {
-			printMemoryTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
-	case "disk":
-		if info, ok := result.(disk.DiskInfo); ok {
-			printDiskTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
-	case "network":
-		if info, ok := result.(network.NetworkInfo); ok {
-			printNetworkTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
-	case "host":
-		if info, ok := result.(host.HostInfo); ok {
-			printHostTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
-	case "user":
-		if info, ok := result.(user.UserInfo); ok {
-			printUserTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
-	case "docker":
-		if info, ok := result.(docker.DockerInfo); ok {
-			printDockerTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
-	case "kubernetes":
-		if info, ok := result.(kubernetes.KubeInfo); ok {
-			printKubernetesTable(info)
-		} else {
-			fmt.Printf("%+v\n", result)
-		}
+	switch info := result.(type) {
+	case *cpu.CpuInfo:
+		printCPUTable(*info)
+	case *sensors.SensorsInfo:
+		printSensorsTable(*info)
+	case *memory.MemoryInfo:
+		printMemoryTable(*info)
+	case *cgroup.CgroupsInfo:
+		printCgroupsTable(*info)
+	case *disk.DiskInfo:
+		printDiskTable(*info)
+	case *network.NetworkInfo:
+		printNetworkTable(*info)
+	case *host.HostInfo:
+		printHostTable(*info)
+	case *pressure.PressureInfo:
+		printPressureTable(*info)
+	case *kernel.KernelInfo:
+		printKernelTable(*info)
+	case *user.UserInfo:
+		printUserTable(*info)
+	case *docker.DockerInfo:
+		printDockerTable(*info)
+	case *kubernetes.KubeInfo:
+		printKubernetesTable(*info)
+	case *script.Result:
+		printScriptTable(*info)
+	case *procgroup.GroupsInfo:
+		printProcessGroupsTable(*info)
 	default:
 		fmt.Printf("%+v\n", result)
 	}
 }
 
+// printRates renders per-second counter rates. They are only available from
+// the second refresh onwards.
+func printRates(rates map[string]float64) {
+	if len(rates) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(rates))
+	for key := range rates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Println("\nRates:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%.1f/s\n", key, rates[key])
+	}
+	w.Flush()
+}
+
 func formatBytes(b uint64) string {
 	const unit = 1024
 	if b < unit {
@@ -165,8 +187,25 @@ func printCPUTable(info cpu.CpuInfo) {
 	fmt.Fprintf(w, "Physical Cores:\t%d\n", info.PhysicalCores)
 	fmt.Fprintf(w, "Logical Cores:\t%d\n", info.LogicalCores)
 	fmt.Fprintf(w, "Average Usage:\t%.2f%%\n", info.AveragePercentages)
+	if hw := info.Hardware; hw != nil {
+		fmt.Fprintf(w, "Model:\t%s\n", hw.ModelName)
+		fmt.Fprintf(w, "Vendor:\t%s (family %s, model %s, stepping %d)\n", hw.VendorID, hw.Family, hw.Model, hw.Stepping)
+		fmt.Fprintf(w, "Sockets:\t%d\n", hw.Sockets)
+		fmt.Fprintf(w, "Cache:\t%d KB\n", hw.CacheSize)
+		fmt.Fprintf(w, "Flags:\t%d (%s)\n", len(hw.Flags), strings.Join(hw.Flags, " "))
+	}
 	w.Flush()
 
+	if len(info.Frequencies) > 0 {
+		fmt.Println("\nCore Frequencies:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "CPU\tCurrent\tMin\tMax\tThrottled")
+		for _, f := range info.Frequencies {
+			fmt.Fprintf(w, "%s\t%.0f MHz\t%.0f MHz\t%.0f MHz\t%d\n", f.CPU, f.CurrentMHz, f.MinMHz, f.MaxMHz, f.ThrottleCount)
+		}
+		w.Flush()
+	}
+
 	fmt.Println("\nCore Percentages:")
 	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
 	header := ""
@@ -182,6 +221,15 @@ func printCPUTable(info cpu.CpuInfo) {
 	fmt.Fprintln(w, row)
 	w.Flush()
 
+	fmt.Printf("\nTime Breakdown (last %.1fs):\n", info.TimesWindow)
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "CPU\tUser\tSystem\tNice\tIowait\tIrq\tSoftirq\tSteal\tIdle")
+	for _, t := range append([]cpu.CpuTimes{info.TotalTimes}, info.CoreTimes...) {
+		fmt.Fprintf(w, "%s\t%.1f%%\t%.1f%%\t%.1f%%\t%.1f%%\t%.1f%%\t%.1f%%\t%.1f%%\t%.1f%%\n",
+			t.CPU, t.User, t.System, t.Nice, t.Iowait, t.Irq, t.Softirq, t.Steal, t.Idle)
+	}
+	w.Flush()
+
 	if len(info.Processes) > 0 {
 		fmt.Println("\nTop Processes (CPU):")
 		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
@@ -193,6 +241,36 @@ func printCPUTable(info cpu.CpuInfo) {
 	}
 }
 
+func printSensorsTable(info sensors.SensorsInfo) {
+	if len(info.Sensors) == 0 {
+		fmt.Println("No sensors found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "Sensor\tCurrent\tHigh\tCritical\tStatus")
+	for _, s := range info.Sensors {
+		status := "OK"
+		if s.AboveCritical {
+			status = "CRITICAL"
+		} else if s.AboveHigh {
+			status = "HIGH"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, formatReading(s.Current, s.Unit),
+			formatReading(s.High, s.Unit), formatReading(s.Critical, s.Unit), status)
+	}
+	w.Flush()
+}
+
+// formatReading renders a sensor value, or "-" for a threshold the chip
+// does not report
+func formatReading(value float64, unit string) string {
+	if value == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f %s", value, unit)
+}
+
 func printKubernetesTable(info kubernetes.KubeInfo) {
 	fmt.Printf("Summary: %d Nodes, %d Pods, %d Services, %d Deployments\n",
 		len(info.NodeStats), len(info.PodStats), len(info.ServiceStats), len(info.DeploymentStats))
@@ -260,6 +338,58 @@ func printMemoryTable(info memory.MemoryInfo) {
 	}
 }
 
+func printCgroupsTable(info cgroup.CgroupsInfo) {
+	fmt.Printf("cgroup v%d\n", info.Version)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "Cgroup\tPath\tMemory\tCPU Limit\tCPU Time\tThrottled\tPids")
+	for _, cg := range info.Cgroups {
+		if cg.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\terror: %s\t\t\t\t\n", cg.Name, cg.Path, cg.Error)
+			continue
+		}
+
+		// n/a for controllers that are not enabled for the cgroup
+		memory := "n/a"
+		if cg.Available(cgroup.ControllerMemory) {
+			memory = formatBytes(cg.MemoryUsage) + " / unlimited"
+			if cg.MemoryLimit > 0 {
+				memory = fmt.Sprintf("%s / %s (%.1f%%)", formatBytes(cg.MemoryUsage), formatBytes(cg.MemoryLimit), cg.MemoryPercent)
+			}
+		}
+		cpuLimit, cpuTime, throttled := "n/a", "n/a", "n/a"
+		if cg.Available(cgroup.ControllerCPU) {
+			cpuLimit = "unlimited"
+			if cg.CPULimit > 0 {
+				cpuLimit = fmt.Sprintf("%.2f cpus", cg.CPULimit)
+			}
+			cpuTime = (time.Duration(cg.CPUUsage) * time.Microsecond).Round(time.Millisecond).String()
+			throttled = fmt.Sprintf("%s (%.1f%% of periods)", (time.Duration(cg.ThrottledTime) * time.Microsecond).Round(time.Millisecond), cg.ThrottledPercent)
+		}
+		pids := "n/a"
+		if cg.Available(cgroup.ControllerPids) {
+			pids = fmt.Sprintf("%d", cg.Pids)
+			if cg.PidsLimit > 0 {
+				pids = fmt.Sprintf("%d / %d", cg.Pids, cg.PidsLimit)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", cg.Name, cg.Path, memory, cpuLimit, cpuTime, throttled, pids)
+	}
+	w.Flush()
+
+	for _, cg := range info.Cgroups {
+		if len(cg.IO) == 0 {
+			continue
+		}
+		fmt.Printf("\nI/O of %s:\n", cg.Name)
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "Device\tRead\tWritten\tRead Ops\tWrite Ops")
+		for _, io := range cg.IO {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", io.Device, formatBytes(io.ReadBytes), formatBytes(io.WriteBytes), io.ReadOps, io.WriteOps)
+		}
+		w.Flush()
+	}
+}
+
 func printDiskTable(info disk.DiskInfo) {
 	fmt.Println("Disk Usage:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
@@ -314,6 +444,70 @@ func printHostTable(info host.HostInfo) {
 	w.Flush()
 }
 
+func printPressureTable(info pressure.PressureInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "Resource\tKind\tAvg10\tAvg60\tAvg300\tTotal Stall")
+	for _, r := range info.Resources() {
+		for _, kind := range []struct {
+			name  string
+			stall *pressure.Stall
+		}{{"some", r.Some}, {"full", r.Full}} {
+			if kind.stall == nil {
+				continue
+			}
+			s := kind.stall
+			fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%.2f%%\t%.2f%%\t%s\n", r.Name, kind.name, s.Avg10, s.Avg60, s.Avg300,
+				(time.Duration(s.Total) * time.Microsecond).Round(time.Millisecond))
+		}
+	}
+	w.Flush()
+}
+
+func printKernelTable(info kernel.KernelInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Context Switches:\t%d\n", info.ContextSwitches)
+	fmt.Fprintf(w, "Forks:\t%d\n", info.Forks)
+	fmt.Fprintf(w, "Interrupts:\t%d\n", info.Interrupts)
+	fmt.Fprintf(w, "SoftIRQs:\t%d\n", info.SoftIRQs)
+	fmt.Fprintf(w, "Processes:\t%d running, %d blocked\n", info.ProcsRunning, info.ProcsBlocked)
+	w.Flush()
+
+	irqs := slices.Clone(info.IRQs)
+	slices.SortStableFunc(irqs, func(a, b kernel.IRQ) int { return cmp.Compare(b.Total, a.Total) })
+	if len(irqs) > 10 {
+		irqs = irqs[:10]
+	}
+	if len(irqs) > 0 {
+		fmt.Println("\nTop Interrupts:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "IRQ\tTotal\tDescription")
+		for _, irq := range irqs {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", irq.Name, irq.Total, irq.Description)
+		}
+		w.Flush()
+	}
+
+	if len(info.SoftIRQTypes) > 0 {
+		fmt.Println("\nSoftIRQs:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "Type\tTotal")
+		for _, irq := range info.SoftIRQTypes {
+			fmt.Fprintf(w, "%s\t%d\n", irq.Name, irq.Total)
+		}
+		w.Flush()
+	}
+
+	if len(info.CPUs) > 0 {
+		fmt.Println("\nPer CPU:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "CPU\tInterrupts\tSoftIRQs")
+		for _, c := range info.CPUs {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", c.CPU, c.Interrupts, c.SoftIRQs)
+		}
+		w.Flush()
+	}
+}
+
 func printUserTable(info user.UserInfo) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
 	fmt.Fprintf(w, "Username:\t%s\n", info.Username)
@@ -366,10 +560,118 @@ func printDockerTable(info docker.DockerInfo) {
 	}
 }
 
+func printScriptTable(result script.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Format:\t%s\n", result.Format)
+	fmt.Fprintf(w, "Exit Code:\t%d\n", result.ExitCode)
+	if result.Format == script.FormatNagios {
+		fmt.Fprintf(w, "Status:\t%s\n", result.Status)
+		fmt.Fprintf(w, "Output:\t%s\n", result.Output)
+	}
+	w.Flush()
+
+	if result.LongOutput != "" {
+		fmt.Println()
+		fmt.Println(result.LongOutput)
+	}
+
+	if len(result.PerfData) > 0 {
+		fmt.Println("\nPerformance Data:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "Label\tValue\tWarn\tCrit")
+		for _, p := range result.PerfData {
+			fmt.Fprintf(w, "%s\t%g%s\t%s\t%s\n", p.Label, p.Value, p.Unit, p.Warn, p.Crit)
+		}
+		w.Flush()
+	}
+
+	if result.Data != nil {
+		out, err := json.MarshalIndent(result.Data, "", "  ")
+		if err != nil {
+			logging.Error(metricsLogTag, "error encoding script output", err)
+			return
+		}
+		fmt.Println(string(out))
+	}
+}
+
+func printProcessGroupsTable(info procgroup.GroupsInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "Group\tStatus\tInstances\tCPU%\tMem%\tRSS\tThreads\tRestarts\tUptime")
+	for _, g := range info.Groups {
+		uptime := "-"
+		if g.DownSince != nil {
+			uptime = fmt.Sprintf("down for %s", time.Since(*g.DownSince).Round(time.Second))
+		} else if g.StartedAt != nil {
+			uptime = time.Duration(g.UptimeSeconds * float64(time.Second)).Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%.2f%%\t%.2f%%\t%s\t%d\t%d\t%s\n",
+			g.Name, strings.ToUpper(g.Status), g.Instances, g.MinInstances, g.CPUPercent, g.MemPercent,
+			formatBytes(g.RSS), g.NumThreads, g.Restarts, uptime)
+	}
+	w.Flush()
+}
+
 func init() {
 	rootCmd.AddCommand(GetMetricCmd)
-	GetMetricCmd.Flags().BoolVarP(&collectAutoRefresh, "auto", "a", false, "Whether to autorefresh every 30 seconds")
-	GetMetricCmd.Flags().IntVarP(&refreshInterval, "refresh", "r", 30, "Number of seconds to autorefresh")
+	GetMetricCmd.PersistentFlags().BoolVarP(&collectAutoRefresh, "auto", "a", false, "Whether to autorefresh every 30 seconds")
+	GetMetricCmd.PersistentFlags().IntVarP(&refreshInterval, "refresh", "r", 30, "Number of seconds to autorefresh")
 	GetMetricCmd.Flags().StringVarP(&getKubeconfigPath, "kubeconfig", "", "", "absolute path to the kubeconfig file (optional)")
+	GetMetricCmd.Flags().StringVarP(&getConfigPath, "config", "", "", "Path to a JSON config file, eg with script collectors (optional)")
 	GetMetricCmd.Flags().BoolVarP(&collectDocker, "docker", "d", false, "Whether to collect docker metrics. Make sure docker is running when passing this flag")
+	addProcessFilterFlags(GetMetricCmd)
+}
+
+// listFilterFlags holds the flags added by addProcessFilterFlags. Only the
+// flags given are merged into proctable.ListFilter, see processFilterOverride.
+var listFilterFlags proctable.Filter
+
+// addProcessFilterFlags adds the flags setting proctable.ListFilter, which
+// the cpu and memory process lists honour
+func addProcessFilterFlags(cmd *cobra.Command) {
+	f := &listFilterFlags
+	cmd.Flags().StringSliceVarP(&f.IncludeUsers, "process-include-user", "", nil, "Only list processes of these users in the cpu and memory metrics, globs like svc-* allowed")
+	cmd.Flags().StringSliceVarP(&f.ExcludeUsers, "process-exclude-user", "", nil, "Hide processes of these users from the cpu and memory metrics, globs like _* allowed")
+	cmd.Flags().StringSliceVarP(&f.IncludeNames, "process-include-name", "", nil, "Only list processes whose name matches one of these regular expressions")
+	cmd.Flags().StringSliceVarP(&f.ExcludeNames, "process-exclude-name", "", nil, "Hide processes whose name matches one of these regular expressions")
+	cmd.Flags().Float64VarP(&f.MinCPU, "process-min-cpu", "", 0, "Hide processes using less CPU (percent of one core)")
+	cmd.Flags().Float64VarP(&f.MinMem, "process-min-mem", "", 0, "Hide processes using less memory (percent)")
+	cmd.Flags().BoolVarP(&f.KernelThreads, "process-kernel-threads", "", false, "List kernel threads such as kworker")
+	cmd.Flags().IntVarP(&f.Top, "process-top", "", 0, fmt.Sprintf("Maximum number of processes per list, 0 for all (default %d)", proctable.DefaultTop))
+}
+
+// processFilterOverride returns the process filter flags given to cmd, so
+// that eg --process-top 0 or an empty --process-exclude-user= replaces the
+// setting of the config file
+func processFilterOverride(cmd *cobra.Command) proctable.FilterOverride {
+	f := listFilterFlags
+	var o proctable.FilterOverride
+	flags := cmd.Flags()
+	for _, list := range []struct {
+		flag string
+		dst  *[]string
+		src  []string
+	}{
+		{"process-include-user", &o.IncludeUsers, f.IncludeUsers},
+		{"process-exclude-user", &o.ExcludeUsers, f.ExcludeUsers},
+		{"process-include-name", &o.IncludeNames, f.IncludeNames},
+		{"process-exclude-name", &o.ExcludeNames, f.ExcludeNames},
+	} {
+		if flags.Changed(list.flag) {
+			*list.dst = append([]string{}, list.src...)
+		}
+	}
+	if flags.Changed("process-min-cpu") {
+		o.MinCPU = &f.MinCPU
+	}
+	if flags.Changed("process-min-mem") {
+		o.MinMem = &f.MinMem
+	}
+	if flags.Changed("process-kernel-threads") {
+		o.KernelThreads = &f.KernelThreads
+	}
+	if flags.Changed("process-top") {
+		o.Top = &f.Top
+	}
+	return o
 }
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+)
+
+var inspectEnv bool
+
+var InspectCmd = &cobra.Command{
+	Use:   "inspect <pid>",
+	Short: "Show everything known about one process",
+	Long:  `Show the command line, working directory, executable, environment, open files, resource limits, I/O counters, context switches and network connections of one process. The environment is only shown with --env, and only the values of the variables in --show-env.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pid, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil || pid <= 0 {
+			return fmt.Errorf("invalid pid %q", args[0])
+		}
+
+		detail, err := proctable.Inspect(cmd.Context(), int32(pid), inspectEnv)
+		if err != nil {
+			return err
+		}
+		printProcessDetail(*detail)
+		return nil
+	},
+}
+
+func printProcessDetail(d proctable.Detail) {
+	fmt.Printf("\n--- PROCESS %d ---\n", d.Pid)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Name:\t%s\n", d.Name)
+	fmt.Fprintf(w, "Command Line:\t%s\n", strings.Join(d.Cmdline, " "))
+	fmt.Fprintf(w, "Executable:\t%s\n", d.Exe)
+	fmt.Fprintf(w, "Working Dir:\t%s\n", d.Cwd)
+	fmt.Fprintf(w, "User:\t%s\n", d.Username)
+	fmt.Fprintf(w, "Parent PID:\t%d\n", d.ParentPid)
+	fmt.Fprintf(w, "Status:\t%s\n", d.Status)
+	fmt.Fprintf(w, "Started:\t%s (%s ago)\n", d.CreateTime.Format(time.RFC3339), time.Since(d.CreateTime).Round(time.Second))
+	fmt.Fprintf(w, "CPU:\t%.2f%% (%s total)\n", d.CPUPercent, time.Duration(d.CPUSeconds*float64(time.Second)).Round(time.Millisecond))
+	fmt.Fprintf(w, "Memory:\t%.2f%% (RSS %s, VMS %s)\n", d.MemPercent, formatBytes(d.Memory.RSS), formatBytes(d.Memory.VMS))
+	fmt.Fprintf(w, "Threads:\t%d\n", d.NumThreads)
+	fmt.Fprintf(w, "Open FDs:\t%d\n", d.NumFDs)
+	if d.ContextSwitches != nil {
+		fmt.Fprintf(w, "Context Switches:\t%d voluntary, %d involuntary\n", d.ContextSwitches.Voluntary, d.ContextSwitches.Involuntary)
+	}
+	if d.IO != nil {
+		fmt.Fprintf(w, "I/O Read:\t%s in %d calls (%s from disk)\n", formatBytes(d.IO.ReadBytes), d.IO.ReadCount, formatBytes(d.IO.DiskReadBytes))
+		fmt.Fprintf(w, "I/O Written:\t%s in %d calls (%s to disk)\n", formatBytes(d.IO.WriteBytes), d.IO.WriteCount, formatBytes(d.IO.DiskWriteBytes))
+	}
+	w.Flush()
+
+	if len(d.Rlimits) > 0 {
+		fmt.Println("\nResource Limits:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "Resource\tSoft\tHard\tUsed")
+		for _, l := range d.Rlimits {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", l.Resource, formatLimit(l.Soft), formatLimit(l.Hard), l.Used)
+		}
+		w.Flush()
+	}
+
+	if len(d.Connections) > 0 {
+		fmt.Println("\nConnections:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "FD\tFamily\tType\tLocal\tRemote\tStatus")
+		for _, c := range d.Connections {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", c.Fd, c.Family, c.Type, c.LocalAddr, c.RemoteAddr, c.Status)
+		}
+		w.Flush()
+	}
+
+	if len(d.OpenFiles) > 0 {
+		fmt.Println("\nOpen Files:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "FD\tPath")
+		for _, f := range d.OpenFiles {
+			fmt.Fprintf(w, "%d\t%s\n", f.Fd, f.Path)
+		}
+		w.Flush()
+	}
+
+	if len(d.Environ) > 0 {
+		fmt.Println("\nEnvironment:")
+		for _, kv := range d.Environ {
+			fmt.Println("  " + kv)
+		}
+	}
+
+	if len(d.Errors) > 0 {
+		fields := make([]string, 0, len(d.Errors))
+		for field := range d.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		fmt.Println("\nUnavailable:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+		for _, field := range fields {
+			fmt.Fprintf(w, "%s\t%s\n", field, d.Errors[field])
+		}
+		w.Flush()
+	}
+}
+
+// formatLimit renders an rlimit, where the largest value means unlimited
+func formatLimit(v uint64) string {
+	if v == math.MaxUint64 {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+func init() {
+	rootCmd.AddCommand(InspectCmd)
+	InspectCmd.Flags().BoolVarP(&inspectEnv, "env", "", false, "Show the environment of the process")
+	InspectCmd.Flags().StringSliceVarP(&proctable.ShowEnv, "show-env", "", proctable.ShowEnv, "Environment variables whose values are shown; the values of all others are hidden")
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+)
+
+var processQuery proctable.Query
+var treeQuery proctable.TreeQuery
+
+var ProcessesCmd = &cobra.Command{
+	Use:   "processes",
+	Short: "List, filter and sort running processes",
+	Long:  `List running processes from the full process table. Filter by user, name, state, CPU or memory usage and sort by any column, eg: sysmon get_metrics processes --sort mem --top 20`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printProcesses := func() error {
+			table, err := proctable.Get(cmd.Context())
+			if err != nil {
+				return err
+			}
+			result, err := processQuery.Run(table)
+			if err != nil {
+				return err
+			}
+			printProcessTable(*result)
+			return nil
+		}
+
+		if err := printProcesses(); err != nil {
+			return err
+		}
+
+		if collectAutoRefresh {
+			ticker := time.NewTicker(time.Duration(refreshInterval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				logging.Info(metricsLogTag, fmt.Sprintf("refreshing every %d seconds", refreshInterval))
+				if err := printProcesses(); err != nil {
+					logging.Error(metricsLogTag, "failed to read processes", err)
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
+var ProcessTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show the process tree with usage per subtree",
+	Long:  `Show the process tree with the CPU, RSS and thread count of every process and of its whole subtree, eg: sysmon get_metrics processes tree --name gunicorn`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printTree := func() error {
+			table, err := proctable.Get(cmd.Context())
+			if err != nil {
+				return err
+			}
+			result, err := treeQuery.Run(table)
+			if err != nil {
+				return err
+			}
+			printProcessTree(*result)
+			return nil
+		}
+
+		if err := printTree(); err != nil {
+			return err
+		}
+
+		if collectAutoRefresh {
+			ticker := time.NewTicker(time.Duration(refreshInterval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				logging.Info(metricsLogTag, fmt.Sprintf("refreshing every %d seconds", refreshInterval))
+				if err := printTree(); err != nil {
+					logging.Error(metricsLogTag, "failed to read processes", err)
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
+func printProcessTable(result proctable.Result) {
+	fmt.Printf("\n--- PROCESSES (%d of %d matching, %d total) ---\n", len(result.Processes), result.Matched, result.Total)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "PID\tPPID\tUser\tName\tState\tCPU%\tMem%\tRSS\tThreads\tCPU Time")
+	for _, p := range result.Processes {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%.2f%%\t%.2f%%\t%s\t%d\t%s\n",
+			p.Pid, p.ParentPid, p.Username, p.Name, p.Status, p.CPUPercent, p.MemPercent,
+			formatBytes(p.Memory.RSS), p.NumThreads, time.Duration(p.CPUSeconds*float64(time.Second)).Round(time.Second))
+	}
+	w.Flush()
+}
+
+func printProcessTree(result proctable.TreeResult) {
+	fmt.Printf("\n--- PROCESS TREE (%d processes) ---\n", result.Total)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "Process\tCPU%\tRSS\tThreads\tSubtree Procs\tSubtree CPU%\tSubtree RSS\tSubtree Threads")
+	var walk func(n *proctable.Node, indent string)
+	walk = func(n *proctable.Node, indent string) {
+		fmt.Fprintf(w, "%s%s (%d)\t%.2f%%\t%s\t%d\t%d\t%.2f%%\t%s\t%d\n",
+			indent, n.Name, n.Pid, n.CPUPercent, formatBytes(n.Memory.RSS), n.NumThreads,
+			n.Subtree.Processes, n.Subtree.CPUPercent, formatBytes(n.Subtree.RSS), n.Subtree.NumThreads)
+		for _, child := range n.Children {
+			walk(child, indent+"  ")
+		}
+	}
+	for _, root := range result.Roots {
+		walk(root, "")
+	}
+	w.Flush()
+}
+
+func init() {
+	GetMetricCmd.AddCommand(ProcessesCmd)
+	ProcessesCmd.Flags().StringVarP(&processQuery.Sort, "sort", "s", proctable.SortCPU, "Column to sort by: "+strings.Join(proctable.SortKeys(), ", "))
+	ProcessesCmd.Flags().StringVarP(&processQuery.Order, "order", "", "", "Sort order, asc or desc (defaults to desc for numeric columns)")
+	ProcessesCmd.Flags().IntVarP(&processQuery.Top, "top", "n", 20, "Number of processes to show, 0 for all")
+	ProcessesCmd.Flags().StringVarP(&processQuery.User, "user", "u", "", "Only show processes of this user")
+	ProcessesCmd.Flags().StringVarP(&processQuery.Name, "name", "", "", "Only show processes whose name matches this regular expression")
+	ProcessesCmd.Flags().StringVarP(&processQuery.State, "state", "", "", "Only show processes in this state, eg running, sleep or zombie")
+	ProcessesCmd.Flags().Float64VarP(&processQuery.MinCPU, "min-cpu", "", 0, "Only show processes using at least this CPU percent")
+	ProcessesCmd.Flags().Float64VarP(&processQuery.MinMem, "min-mem", "", 0, "Only show processes using at least this memory percent")
+
+	ProcessesCmd.AddCommand(ProcessTreeCmd)
+	ProcessTreeCmd.Flags().Int32VarP(&treeQuery.Pid, "pid", "p", 0, "Only show the subtree of this process")
+	ProcessTreeCmd.Flags().StringVarP(&treeQuery.Name, "name", "", "", "Only show the subtrees of processes whose name matches this regular expression")
+	ProcessTreeCmd.Flags().StringVarP(&treeQuery.Sort, "sort", "s", proctable.SortRSS, "Order of siblings: pid, cpu, rss or threads")
+	ProcessTreeCmd.Flags().IntVarP(&treeQuery.Depth, "depth", "", 0, "Levels of children to show below each root, 0 for all")
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/techtacles/sysmonitoring/internal/control"
 	"github.com/techtacles/sysmonitoring/internal/dashboard"
 	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
 )
 
 var logtag string = "cmd"
@@ -51,8 +55,13 @@ var RunCmd = &cobra.Command{
 			os.Exit(0)
 		}
 
+		// stop cleanly on Ctrl+C and on SIGTERM from service managers
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		dashboard.ProcessFilter = processFilterOverride(cmd)
 		logging.Info(logtag, "running dashboard server")
-		if err := dashboard.Run(collectDocker, collectKubernetes, kubeconfigpath); err != nil {
+		if err := dashboard.Run(ctx, collectDocker, collectKubernetes, kubeconfigpath); err != nil {
 			return err
 		}
 		return nil
@@ -65,5 +74,23 @@ func init() {
 	RunCmd.Flags().BoolVarP(&collectDocker, "docker", "d", false, "Whether to collect docker metrics. Make sure docker is running when passing this flag")
 	RunCmd.Flags().BoolVarP(&collectKubernetes, "kubernetes", "k", false, "Whether to collect kubernetes metrics.")
 	RunCmd.Flags().StringVarP(&kubeconfigpath, "kubeconfig", "", "", "absolute path to the kubeconfig file (optional)")
+	RunCmd.Flags().StringVarP(&dashboard.ConfigFile, "config", "", "", "Path to a JSON config file, eg with script collectors (optional)")
+	RunCmd.Flags().IntVarP(&dashboard.HistoryRetention, "history", "", dashboard.HistoryRetention, "Number of samples to keep in memory per metric for /api/history")
+	RunCmd.Flags().StringVarP(&dashboard.DataDir, "data-dir", "", "", "Directory to persist metrics in so history survives restarts (disabled when empty)")
+	RunCmd.Flags().DurationVarP(&dashboard.StorageRetention, "retention", "", dashboard.StorageRetention, "How long to keep persisted metrics")
+	RunCmd.Flags().Int64VarP(&dashboard.StorageMaxMB, "max-storage", "", dashboard.StorageMaxMB, "Maximum size of persisted metrics in MB")
+	RunCmd.Flags().DurationVarP(&dashboard.CollectInterval, "interval", "", dashboard.CollectInterval, "How often collectors run unless overridden")
+	RunCmd.Flags().StringToStringVarP(&dashboard.CollectorIntervals, "collector-interval", "", nil, "Per-collector interval overrides, eg cpu=5s,kubernetes=5m")
+	RunCmd.Flags().DurationVarP(&dashboard.CollectorTimeout, "timeout", "", dashboard.CollectorTimeout, "Maximum time a single collector may take before it is abandoned")
+	RunCmd.Flags().StringToStringVarP(&dashboard.CollectorTimeouts, "collector-timeout", "", nil, "Per-collector timeout overrides, eg kubernetes=1m,docker=30s")
+	RunCmd.Flags().DurationVarP(&dashboard.ShutdownTimeout, "shutdown-timeout", "", dashboard.ShutdownTimeout, "How long to wait for in-flight requests and collections on shutdown")
+	RunCmd.Flags().BoolVarP(&dashboard.ProcessDetail, "process-detail", "", false, "Serve /api/processes/{pid}. Needs a token in "+dashboard.ControlTokenEnv)
+	RunCmd.Flags().BoolVarP(&dashboard.ProcessDetailEnv, "process-detail-env", "", false, "Include the environment in /api/processes/{pid}")
+	RunCmd.Flags().StringSliceVarP(&proctable.ShowEnv, "show-env", "", proctable.ShowEnv, "Environment variables whose values are shown; the values of all others are hidden")
+	RunCmd.Flags().BoolVarP(&control.Enabled, "enable-control", "", false, "Allow signalling and renicing processes through the API. Needs a token in "+dashboard.ControlTokenEnv+", --audit-log and --control-allow-user")
+	RunCmd.Flags().StringSliceVarP(&control.AllowUsers, "control-allow-user", "", nil, "Users whose processes may be controlled (required with --enable-control)")
+	RunCmd.Flags().StringSliceVarP(&control.AllowNames, "control-allow-name", "", nil, "Process names that may be controlled, in addition to the user (any name when empty)")
+	RunCmd.Flags().StringVarP(&control.AuditLog, "audit-log", "", "", "File every process control action is appended to")
 	RunCmd.Flags().BoolVarP(&isDetached, "detached", "D", false, "Run the dashboard server in the background")
+	addProcessFilterFlags(RunCmd)
 }
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/cgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/procgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+	"github.com/techtacles/sysmonitoring/internal/metrics/script"
+)
+
+const logtag string = "config"
+
+// validName keeps collector names usable in URLs, CLI arguments and the
+// dashboard, eg "nfs_mounts" or "backup-age"
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Config is the optional JSON file passed with --config
+type Config struct {
+	Scripts       []Script       `json:"scripts"`
+	ProcessGroups []ProcessGroup `json:"processGroups"`
+	Cgroups       []string       `json:"cgroups"` // paths below the cgroup root, eg "system.slice/nginx.service"
+	ProcessFilter ProcessFilter  `json:"processFilter"`
+}
+
+// ProcessFilter selects the processes listed by the cpu and memory
+// collectors. Every field is optional; a field that is left out keeps the
+// default, eg "top": 0 lists every process.
+type ProcessFilter struct {
+	IncludeUsers  []string `json:"includeUsers,omitempty"` // user names or globs, eg "svc-*"
+	ExcludeUsers  []string `json:"excludeUsers,omitempty"`
+	IncludeNames  []string `json:"includeNames,omitempty"` // regular expressions matched against the process name
+	ExcludeNames  []string `json:"excludeNames,omitempty"`
+	MinCPU        *float64 `json:"minCpu,omitempty"` // percent of one core
+	MinMem        *float64 `json:"minMem,omitempty"` // percent of physical memory
+	KernelThreads *bool    `json:"kernelThreads,omitempty"`
+	Top           *int     `json:"top,omitempty"`
+}
+
+// Script configures an external check published as a metric
+type Script struct {
+	Name           string   `json:"name"`
+	Command        string   `json:"command"`
+	Args           []string `json:"args,omitempty"`
+	Format         string   `json:"format,omitempty"`         // "json" (default) or "nagios"
+	Timeout        string   `json:"timeout,omitempty"`        // eg "10s"
+	Interval       string   `json:"interval,omitempty"`       // eg "5m"
+	MaxOutputBytes int64    `json:"maxOutputBytes,omitempty"` // defaults to 1MB
+}
+
+// ProcessGroup declares a named group of processes to watch. At least one
+// of ProcessName, User and Cmdline is required.
+type ProcessGroup struct {
+	Name         string `json:"name"`
+	ProcessName  string `json:"processName,omitempty"`  // exact process name, eg "nginx"
+	User         string `json:"user,omitempty"`         // exact user name, eg "www-data"
+	Cmdline      string `json:"cmdline,omitempty"`      // regular expression matched against the command line
+	MinInstances *int   `json:"minInstances,omitempty"` // defaults to 1
+}
+
+// Load reads and validates a config file. An empty path returns an empty
+// config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logging.Error(logtag, "error reading config file", err)
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		logging.Error(logtag, "error parsing config file", err)
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	logging.Info(logtag, fmt.Sprintf("loaded config from %s", path))
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	seen := make(map[string]bool)
+	for i, s := range c.Scripts {
+		if !validName.MatchString(s.Name) {
+			return fmt.Errorf("scripts[%d]: name %q must only contain letters, digits, '_' and '-'", i, s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("scripts[%d]: duplicate name %q", i, s.Name)
+		}
+		seen[s.Name] = true
+
+		if s.Command == "" {
+			return fmt.Errorf("scripts[%d] (%s): command is required", i, s.Name)
+		}
+		if s.Format != "" && s.Format != script.FormatJSON && s.Format != script.FormatNagios {
+			return fmt.Errorf("scripts[%d] (%s): format must be %q or %q", i, s.Name, script.FormatJSON, script.FormatNagios)
+		}
+		if s.Timeout != "" {
+			if d, err := time.ParseDuration(s.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("scripts[%d] (%s): invalid timeout %q", i, s.Name, s.Timeout)
+			}
+		}
+		if s.Interval != "" {
+			if d, err := time.ParseDuration(s.Interval); err != nil || d <= 0 {
+				return fmt.Errorf("scripts[%d] (%s): invalid interval %q", i, s.Name, s.Interval)
+			}
+		}
+		if s.MaxOutputBytes < 0 {
+			return fmt.Errorf("scripts[%d] (%s): maxOutputBytes must not be negative", i, s.Name)
+		}
+	}
+
+	groups := make(map[string]bool)
+	for i, g := range c.ProcessGroups {
+		if !validName.MatchString(g.Name) {
+			return fmt.Errorf("processGroups[%d]: name %q must only contain letters, digits, '_' and '-'", i, g.Name)
+		}
+		if groups[g.Name] {
+			return fmt.Errorf("processGroups[%d]: duplicate name %q", i, g.Name)
+		}
+		groups[g.Name] = true
+
+		if g.ProcessName == "" && g.User == "" && g.Cmdline == "" {
+			return fmt.Errorf("processGroups[%d] (%s): one of processName, user or cmdline is required", i, g.Name)
+		}
+		if _, err := regexp.Compile(g.Cmdline); err != nil {
+			return fmt.Errorf("processGroups[%d] (%s): invalid cmdline pattern: %w", i, g.Name, err)
+		}
+		if g.MinInstances != nil && *g.MinInstances < 0 {
+			return fmt.Errorf("processGroups[%d] (%s): minInstances must not be negative", i, g.Name)
+		}
+	}
+
+	cgroups := make(map[string]bool)
+	for i, path := range c.Cgroups {
+		trimmed := strings.Trim(path, "/")
+		if trimmed == "" || slices.Contains(strings.Split(trimmed, "/"), "..") {
+			return fmt.Errorf("cgroups[%d]: invalid path %q", i, path)
+		}
+		if trimmed == cgroup.SelfName || cgroups[trimmed] {
+			return fmt.Errorf("cgroups[%d]: duplicate path %q", i, path)
+		}
+		cgroups[trimmed] = true
+	}
+
+	if err := proctable.DefaultFilter.Merge(c.Filter()).Validate(); err != nil {
+		return fmt.Errorf("processFilter: %w", err)
+	}
+	return nil
+}
+
+// Checks returns the configured scripts as checks ready to be registered
+func (c *Config) Checks() []script.Check {
+	checks := make([]script.Check, 0, len(c.Scripts))
+	for _, s := range c.Scripts {
+		format := s.Format
+		if format == "" {
+			format = script.FormatJSON
+		}
+		// validated by Load
+		timeout, _ := time.ParseDuration(s.Timeout)
+		interval, _ := time.ParseDuration(s.Interval)
+
+		checks = append(checks, script.Check{
+			Name:           s.Name,
+			Command:        s.Command,
+			Args:           s.Args,
+			Format:         format,
+			Timeout:        timeout,
+			Interval:       interval,
+			MaxOutputBytes: s.MaxOutputBytes,
+		})
+	}
+	return checks
+}
+
+// Filter returns the configured process filter, to be merged into
+// proctable.DefaultFilter
+func (c *Config) Filter() proctable.FilterOverride {
+	f := c.ProcessFilter
+	return proctable.FilterOverride{
+		IncludeUsers:  f.IncludeUsers,
+		ExcludeUsers:  f.ExcludeUsers,
+		IncludeNames:  f.IncludeNames,
+		ExcludeNames:  f.ExcludeNames,
+		MinCPU:        f.MinCPU,
+		MinMem:        f.MinMem,
+		KernelThreads: f.KernelThreads,
+		Top:           f.Top,
+	}
+}
+
+// Groups returns the configured process groups ready to be watched
+func (c *Config) Groups() []procgroup.Group {
+	groups := make([]procgroup.Group, 0, len(c.ProcessGroups))
+	for _, g := range c.ProcessGroups {
+		group := procgroup.Group{
+			Name:         g.Name,
+			ProcessName:  g.ProcessName,
+			User:         g.User,
+			MinInstances: 1,
+		}
+		if g.Cmdline != "" {
+			// validated by Load
+			group.Cmdline = regexp.MustCompile(g.Cmdline)
+		}
+		if g.MinInstances != nil {
+			group.MinInstances = *g.MinInstances
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
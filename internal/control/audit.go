@@ -0,0 +1,129 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+// AuditLog is the file every action is appended to, one JSON object per line
+var AuditLog string
+
+// Outcomes recorded in the audit log. An action that was allowed is
+// recorded twice: as requested before it is carried out, then with its
+// result.
+const (
+	OutcomeRequested = "requested"
+	OutcomeOK        = "ok"
+	OutcomeDenied    = "denied"
+	OutcomeFailed    = "failed"
+)
+
+// Entry is one line of the audit log
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Pid        int32     `json:"pid"`
+	Name       string    `json:"name,omitempty"`
+	User       string    `json:"user,omitempty"`
+	CreateTime int64     `json:"createTime,omitempty"`
+	Signal     string    `json:"signal,omitempty"`
+	Nice       *int      `json:"nice,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+var auditMu sync.Mutex
+
+// Deny records a request refused before it reached the policy, eg for a
+// missing token
+func Deny(kind string, action Action, err error) {
+	audit(action, kind, nil, err)
+}
+
+// auditRequest records an allowed action before it is carried out. The
+// action must not go ahead when it returns an error.
+func auditRequest(action Action, kind string, t *target) error {
+	entry := newEntry(action, kind, t)
+	entry.Outcome = OutcomeRequested
+	if err := appendEntry(entry); err != nil {
+		logging.Error(logtag, fmt.Sprintf("refusing %s of pid %d, the audit log cannot be written", kind, action.Pid), err)
+		return fmt.Errorf("%w: %v", ErrAudit, err)
+	}
+	return nil
+}
+
+// audit records the outcome of an action. Failing to write the audit log is
+// logged but does not undo an action that has already been carried out.
+func audit(action Action, kind string, t *target, err error) {
+	entry := newEntry(action, kind, t)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrDisabled), errors.Is(err, ErrDenied), errors.Is(err, ErrUnauthorized):
+		entry.Outcome = OutcomeDenied
+		entry.Error = err.Error()
+	default:
+		entry.Outcome = OutcomeFailed
+		entry.Error = err.Error()
+	}
+
+	msg := fmt.Sprintf("%s pid %d (%s) by %s from %s: %s", kind, entry.Pid, entry.Name, entry.Actor, entry.Source, entry.Outcome)
+	if err != nil {
+		logging.Error(logtag, msg, err)
+	} else {
+		logging.Info(logtag, msg)
+	}
+
+	if AuditLog == "" {
+		return
+	}
+	if err := appendEntry(entry); err != nil {
+		logging.Error(logtag, "error writing audit log", err)
+	}
+}
+
+func newEntry(action Action, kind string, t *target) Entry {
+	entry := Entry{
+		Time:    time.Now().UTC(),
+		Action:  kind,
+		Pid:     action.Pid,
+		Signal:  action.Signal,
+		Nice:    action.Nice,
+		Actor:   action.Actor,
+		Source:  action.Source,
+		Outcome: OutcomeOK,
+	}
+	if t != nil {
+		entry.Name = t.name
+		entry.User = t.username
+		entry.CreateTime = t.createTime
+	}
+	return entry
+}
+
+func appendEntry(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.OpenFile(AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,226 @@
+// Package control signals and renices processes on behalf of the dashboard
+// API. Every action is off unless Enabled is set, is limited to processes
+// matching the allow-lists and is written to the audit log, whether it was
+// carried out, denied or failed. An action is refused when its audit entry
+// cannot be written before it is carried out.
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+)
+
+const logtag string = "control"
+
+// Signals that can be sent to a process
+const (
+	SignalTerm = "TERM"
+	SignalKill = "KILL"
+	SignalStop = "STOP"
+	SignalCont = "CONT"
+)
+
+// Nice values accepted by Renice
+const (
+	MinNice = -20
+	MaxNice = 19
+)
+
+var (
+	// ErrDisabled is returned for every action while Enabled is false
+	ErrDisabled = errors.New("process control is disabled")
+	// ErrDenied is returned for processes not covered by the allow-lists
+	ErrDenied = errors.New("process is not allowed by the control allow-lists")
+	// ErrNotFound is returned when the process is not running
+	ErrNotFound = errors.New("process not found")
+	// ErrInvalid is returned for unknown signals and out of range nice values
+	ErrInvalid = errors.New("invalid action")
+	// ErrUnauthorized is recorded for requests without a valid token
+	ErrUnauthorized = errors.New("missing or invalid token")
+	// ErrAudit is returned when an action is refused because the audit log
+	// cannot be written
+	ErrAudit = errors.New("audit log cannot be written")
+)
+
+// Enabled turns process control on
+var Enabled bool
+
+// AllowUsers lists the users whose processes may be controlled. It is
+// required, so that naming a process in AllowNames never extends to the
+// copies run by root.
+var AllowUsers []string
+
+// AllowNames lists the process names that may be controlled. Empty allows
+// any process of the users in AllowUsers.
+var AllowNames []string
+
+// Action describes a request to control a process. Actor and Source identify
+// who asked, for the audit log.
+type Action struct {
+	Pid        int32
+	CreateTime int64  // optional, in unix milliseconds; refuses a pid reused by another process
+	Signal     string // one of the Signal* constants, for signal actions
+	Nice       *int   // for renice actions
+	Actor      string
+	Source     string
+}
+
+// target is the process an action applies to, as read before the policy
+// was checked
+type target struct {
+	proc         *process.Process
+	name         string
+	username     string
+	createTime   int64
+	kernelThread bool
+}
+
+// Validate checks the settings before the dashboard starts, including that
+// the audit log can be written
+func Validate() error {
+	if !Enabled {
+		return nil
+	}
+	if len(AllowUsers) == 0 {
+		return fmt.Errorf("process control needs at least one allowed user")
+	}
+	if slices.Contains(AllowUsers, "root") {
+		logging.Info(logtag, "process control allows root processes, limit it with an allowed process name")
+	}
+	if AuditLog == "" {
+		return fmt.Errorf("process control needs an audit log")
+	}
+	f, err := os.OpenFile(AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// ParseSignal normalises a signal name, eg "sigterm" or "TERM"
+func ParseSignal(name string) (string, error) {
+	sig := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	switch sig {
+	case SignalTerm, SignalKill, SignalStop, SignalCont:
+		return sig, nil
+	}
+	return "", fmt.Errorf("%w: unknown signal %q, must be TERM, KILL, STOP or CONT", ErrInvalid, name)
+}
+
+// Signal sends a signal to a process
+func Signal(ctx context.Context, action Action) error {
+	sig, err := ParseSignal(action.Signal)
+	if err != nil {
+		audit(action, "signal", nil, err)
+		return err
+	}
+	action.Signal = sig
+
+	t, err := authorize(ctx, action)
+	if err != nil {
+		audit(action, "signal", t, err)
+		return err
+	}
+	if err := auditRequest(action, "signal", t); err != nil {
+		return err
+	}
+
+	switch sig {
+	case SignalTerm:
+		err = t.proc.TerminateWithContext(ctx)
+	case SignalKill:
+		err = t.proc.KillWithContext(ctx)
+	case SignalStop:
+		err = t.proc.SuspendWithContext(ctx)
+	case SignalCont:
+		err = t.proc.ResumeWithContext(ctx)
+	}
+	audit(action, "signal", t, err)
+	return err
+}
+
+// Renice changes the scheduling priority of a process
+func Renice(ctx context.Context, action Action) error {
+	if action.Nice == nil || *action.Nice < MinNice || *action.Nice > MaxNice {
+		err := fmt.Errorf("%w: nice must be between %d and %d", ErrInvalid, MinNice, MaxNice)
+		audit(action, "renice", nil, err)
+		return err
+	}
+
+	t, err := authorize(ctx, action)
+	if err != nil {
+		audit(action, "renice", t, err)
+		return err
+	}
+	if err := auditRequest(action, "renice", t); err != nil {
+		return err
+	}
+
+	err = setNice(action.Pid, *action.Nice)
+	audit(action, "renice", t, err)
+	return err
+}
+
+// authorize looks up the process and checks it against the policy
+func authorize(ctx context.Context, action Action) (*target, error) {
+	if !Enabled {
+		return nil, ErrDisabled
+	}
+	if action.Pid <= 0 {
+		return nil, fmt.Errorf("%w: invalid pid %d", ErrInvalid, action.Pid)
+	}
+	if int(action.Pid) == os.Getpid() {
+		return nil, fmt.Errorf("%w: sysmon cannot control itself", ErrDenied)
+	}
+	if action.Pid == 1 {
+		return nil, fmt.Errorf("%w: pid 1 cannot be controlled", ErrDenied)
+	}
+
+	t, err := lookup(ctx, action.Pid)
+	if err != nil {
+		return nil, err
+	}
+	if action.CreateTime != 0 && action.CreateTime != t.createTime {
+		return t, fmt.Errorf("%w: pid %d now belongs to another process", ErrNotFound, action.Pid)
+	}
+	if t.kernelThread {
+		return t, fmt.Errorf("%w: kernel threads cannot be controlled", ErrDenied)
+	}
+	if !slices.Contains(AllowUsers, t.username) {
+		return t, fmt.Errorf("%w: user %q", ErrDenied, t.username)
+	}
+	if len(AllowNames) > 0 && !slices.Contains(AllowNames, t.name) {
+		return t, fmt.Errorf("%w: name %q", ErrDenied, t.name)
+	}
+	return t, nil
+}
+
+func lookup(ctx context.Context, pid int32) (*target, error) {
+	proc := &process.Process{Pid: pid}
+	createTime, err := proc.CreateTimeWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pid %d: %w", pid, ErrNotFound)
+	}
+
+	t := &target{proc: proc, createTime: createTime}
+	if t.name, err = proc.NameWithContext(ctx); err != nil {
+		return nil, fmt.Errorf("pid %d: %w", pid, ErrNotFound)
+	}
+	if t.username, err = proc.UsernameWithContext(ctx); err != nil {
+		logging.Error(logtag, fmt.Sprintf("error reading the user of process %d", pid), err)
+	}
+
+	row := proctable.Process{Pid: pid}
+	row.ParentPid, _ = proc.PpidWithContext(ctx)
+	row.Command, _ = proc.CmdlineWithContext(ctx)
+	t.kernelThread = proctable.IsKernelThread(row)
+	return t, nil
+}
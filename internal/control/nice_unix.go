@@ -0,0 +1,9 @@
+//go:build !windows
+
+package control
+
+import "syscall"
+
+func setNice(pid int32, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, int(pid), nice)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package control
+
+import "errors"
+
+// setNice is not supported as Windows uses priority classes instead of nice
+// values
+func setNice(pid int32, nice int) error {
+	return errors.New("renice is not supported on windows")
+}
@@ -0,0 +1,143 @@
+package dashboard
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/techtacles/sysmonitoring/internal/control"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+// ControlTokenEnv holds the bearer token required by the process control
+// and process detail endpoints. It is read from the environment so it does
+// not show up in the process list.
+const ControlTokenEnv = "SYSMON_CONTROL_TOKEN"
+
+// ProcessDetail enables /api/processes/{pid}
+var ProcessDetail bool
+
+// ProcessDetailEnv adds the environment to /api/processes/{pid}, with only
+// the values of the variables in proctable.ShowEnv
+var ProcessDetailEnv bool
+
+// ActorHeader names the person behind a control request in the audit log.
+// It is supplied by the client and only as trustworthy as the token holder.
+const ActorHeader = "X-Sysmon-Actor"
+
+// maxControlBody caps the size of a control request body
+const maxControlBody = 4 << 10
+
+// controlRequest is the body of the control endpoints
+type controlRequest struct {
+	Signal     string `json:"signal,omitempty"`
+	Nice       *int   `json:"nice,omitempty"`
+	CreateTime int64  `json:"createTime,omitempty"` // from /api/processes, guards against a reused pid
+}
+
+type controlResponse struct {
+	Pid     int32  `json:"pid"`
+	Action  string `json:"action"`
+	Outcome string `json:"outcome"`
+}
+
+// controlToken returns the token required by the control and process detail
+// endpoints and checks it is set when either is enabled
+func controlToken() (string, error) {
+	if !control.Enabled && !ProcessDetail {
+		return "", nil
+	}
+	if err := control.Validate(); err != nil {
+		return "", err
+	}
+	token := os.Getenv(ControlTokenEnv)
+	if token == "" {
+		return "", fmt.Errorf("process control and process detail need a token in %s", ControlTokenEnv)
+	}
+	return token, nil
+}
+
+// handleControl serves POST /api/processes/{pid}/signal with {"signal":"TERM"}
+// and POST /api/processes/{pid}/renice with {"nice":10}. Both need an
+// "Authorization: Bearer <token>" header.
+func handleControl(action, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !control.Enabled {
+			http.Error(w, control.ErrDisabled.Error(), http.StatusForbidden)
+			return
+		}
+
+		raw := r.PathValue("pid")
+		pid, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			pid = 0
+		}
+		if !validToken(r, token) {
+			control.Deny(action, control.Action{
+				Pid:    int32(pid),
+				Actor:  r.Header.Get(ActorHeader),
+				Source: r.RemoteAddr,
+			}, control.ErrUnauthorized)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if pid <= 0 {
+			http.Error(w, fmt.Sprintf("invalid pid %q", raw), http.StatusBadRequest)
+			return
+		}
+
+		var req controlRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxControlBody)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		a := control.Action{
+			Pid:        int32(pid),
+			CreateTime: req.CreateTime,
+			Signal:     req.Signal,
+			Nice:       req.Nice,
+			Actor:      r.Header.Get(ActorHeader),
+			Source:     r.RemoteAddr,
+		}
+		if action == "renice" {
+			err = control.Renice(r.Context(), a)
+		} else {
+			err = control.Signal(r.Context(), a)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), controlStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(controlResponse{Pid: a.Pid, Action: action, Outcome: control.OutcomeOK}); err != nil {
+			logging.Error(logtag, "error encoding control response to json", err)
+		}
+	}
+}
+
+func validToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func controlStatus(err error) int {
+	switch {
+	case errors.Is(err, control.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, control.ErrDisabled), errors.Is(err, control.ErrDenied):
+		return http.StatusForbidden
+	case errors.Is(err, control.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, control.ErrAudit):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,148 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/aggregator"
+	"github.com/techtacles/sysmonitoring/internal/storage"
+)
+
+// HistoryRetention is the number of samples kept in memory per collector
+var HistoryRetention int = aggregator.DefaultHistoryRetention
+
+// DataDir is where collected metrics are persisted. Persistence is disabled
+// when empty.
+var DataDir string
+
+// StorageRetention is how long persisted metrics are kept
+var StorageRetention time.Duration = storage.DefaultRetention
+
+// StorageMaxMB caps the size of the on-disk store
+var StorageMaxMB int64 = storage.DefaultMaxBytes / 1024 / 1024
+
+// storeMaintainInterval is how often retention and compaction are applied to
+// the on-disk store, which otherwise only happens when a segment fills up
+const storeMaintainInterval = 10 * time.Minute
+
+// defaultHistoryWindow is how far back /api/history reads without a since
+const defaultHistoryWindow = time.Hour
+
+// openStore opens the on-disk store when DataDir is set
+func openStore() (*storage.Store, error) {
+	if DataDir == "" {
+		return nil, nil
+	}
+	return storage.Open(storage.Options{
+		Dir:       DataDir,
+		Retention: StorageRetention,
+		MaxBytes:  StorageMaxMB * 1024 * 1024,
+	})
+}
+
+// maintainStore runs store maintenance every storeMaintainInterval until ctx
+// is cancelled
+func maintainStore(ctx context.Context, store *storage.Store) {
+	ticker := time.NewTicker(storeMaintainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.Maintain(); err != nil {
+				logging.Error(logtag, "error running storage maintenance", err)
+			}
+		}
+	}
+}
+
+type historyResponse struct {
+	Metric string              `json:"metric"`
+	Since  time.Time           `json:"since"`
+	Step   string              `json:"step,omitempty"`
+	Points []aggregator.Sample `json:"points"`
+}
+
+// handleHistory serves /api/history?metric=cpu&since=1h&step=1m
+func handleHistory(ag *aggregator.Aggregator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		metric := query.Get("metric")
+		if metric == "" {
+			http.Error(w, "metric is required", http.StatusBadRequest)
+			return
+		}
+		if !isRegistered(ag, metric) {
+			http.Error(w, fmt.Sprintf("unknown metric %q", metric), http.StatusNotFound)
+			return
+		}
+
+		since, err := parseSince(query.Get("since"), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var step time.Duration
+		if raw := query.Get("step"); raw != "" {
+			step, err = time.ParseDuration(raw)
+			if err != nil || step < 0 {
+				http.Error(w, fmt.Sprintf("invalid step %q", raw), http.StatusBadRequest)
+				return
+			}
+		}
+
+		resp := historyResponse{
+			Metric: metric,
+			Since:  since,
+			Points: ag.History(metric, since, step),
+		}
+		if step > 0 {
+			resp.Step = step.String()
+		}
+		if resp.Points == nil {
+			resp.Points = []aggregator.Sample{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logging.Error(logtag, "error encoding history to json", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// parseSince accepts a duration relative to now (eg "15m"), an RFC3339
+// timestamp or unix seconds. An empty value means defaultHistoryWindow ago.
+func parseSince(raw string, now time.Time) (time.Time, error) {
+	if raw == "" {
+		return now.Add(-defaultHistoryWindow), nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: use a duration, RFC3339 time or unix seconds", raw)
+}
+
+func isRegistered(ag *aggregator.Aggregator, name string) bool {
+	for _, n := range ag.CollectorNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
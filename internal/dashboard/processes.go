@@ -0,0 +1,269 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/aggregator"
+	"github.com/techtacles/sysmonitoring/internal/metrics/cpu"
+	"github.com/techtacles/sysmonitoring/internal/metrics/memory"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+)
+
+// handleProcesses serves
+// /api/processes?user=postgres&name=^post&minCpu=1&minMem=0.5&state=running&sort=mem&order=desc&top=20
+// from the full process table. Every parameter is optional.
+func handleProcesses() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseProcessQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		table, err := proctable.Get(r.Context())
+		if err != nil {
+			logging.Error(logtag, "error reading process table", err)
+			http.Error(w, "error reading process table", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := q.Run(table)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			logging.Error(logtag, "error encoding processes to json", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleProcessTree serves /api/processes/tree?pid=1&name=gunicorn&sort=rss&depth=2
+// with CPU, RSS and thread totals for every subtree. Every parameter is
+// optional.
+func handleProcessTree() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		q := proctable.TreeQuery{
+			Name: values.Get("name"),
+			Sort: values.Get("sort"),
+		}
+		if raw := values.Get("pid"); raw != "" {
+			pid, err := strconv.ParseInt(raw, 10, 32)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid pid %q", raw), http.StatusBadRequest)
+				return
+			}
+			q.Pid = int32(pid)
+		}
+		if raw := values.Get("depth"); raw != "" {
+			depth, err := strconv.Atoi(raw)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid depth %q", raw), http.StatusBadRequest)
+				return
+			}
+			q.Depth = depth
+		}
+
+		table, err := proctable.Get(r.Context())
+		if err != nil {
+			logging.Error(logtag, "error reading process table", err)
+			http.Error(w, "error reading process table", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := q.Run(table)
+		if errors.Is(err, proctable.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			logging.Error(logtag, "error encoding process tree to json", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleProcess serves /api/processes/{pid} with everything known about one
+// process. It is off unless ProcessDetail is set and needs the same
+// "Authorization: Bearer <token>" header as the control endpoints, as the
+// command line, open files and sockets can reveal secrets.
+func handleProcess(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ProcessDetail {
+			http.Error(w, "process detail is disabled, start with --process-detail", http.StatusForbidden)
+			return
+		}
+		if !validToken(r, token) {
+			logging.Error(logtag, fmt.Sprintf("rejected unauthenticated process detail request from %s", r.RemoteAddr), nil)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		raw := r.PathValue("pid")
+		pid, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || pid <= 0 {
+			http.Error(w, fmt.Sprintf("invalid pid %q", raw), http.StatusBadRequest)
+			return
+		}
+
+		detail, err := proctable.Inspect(r.Context(), int32(pid), ProcessDetailEnv)
+		if errors.Is(err, proctable.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logging.Error(logtag, "error inspecting process", err)
+			http.Error(w, "error inspecting process", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(detail); err != nil {
+			logging.Error(logtag, "error encoding process to json", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func parseProcessQuery(values url.Values) (proctable.Query, error) {
+	q := proctable.Query{
+		User:  values.Get("user"),
+		Name:  values.Get("name"),
+		State: values.Get("state"),
+		Sort:  values.Get("sort"),
+		Order: values.Get("order"),
+	}
+
+	var err error
+	if raw := values.Get("minCpu"); raw != "" {
+		if q.MinCPU, err = strconv.ParseFloat(raw, 64); err != nil {
+			return q, fmt.Errorf("invalid minCpu %q", raw)
+		}
+	}
+	if raw := values.Get("minMem"); raw != "" {
+		if q.MinMem, err = strconv.ParseFloat(raw, 64); err != nil {
+			return q, fmt.Errorf("invalid minMem %q", raw)
+		}
+	}
+	if raw := values.Get("top"); raw != "" {
+		if q.Top, err = strconv.Atoi(raw); err != nil {
+			return q, fmt.Errorf("invalid top %q", raw)
+		}
+	}
+	return q, nil
+}
+
+// filterParams are the query parameters of /api/metrics that filter the cpu
+// and memory process lists
+var filterParams = []string{"includeUser", "excludeUser", "includeName", "excludeName", "minCpu", "minMem", "kernelThreads", "top"}
+
+// parseProcessFilter reads a process filter from the query, eg
+// ?excludeUser=root&includeName=^post&minMem=0.5&top=10. Lists take repeated
+// parameters. A parameter that is given replaces the configured setting, so
+// ?excludeUser=&minCpu=0&top=0 lists every process. It reports false when
+// the query has no filter parameter.
+func parseProcessFilter(values url.Values) (proctable.FilterOverride, bool, error) {
+	var o proctable.FilterOverride
+	found := false
+	for _, param := range filterParams {
+		if values.Has(param) {
+			found = true
+		}
+	}
+
+	for _, list := range []struct {
+		param string
+		dst   *[]string
+	}{
+		{"includeUser", &o.IncludeUsers},
+		{"excludeUser", &o.ExcludeUsers},
+		{"includeName", &o.IncludeNames},
+		{"excludeName", &o.ExcludeNames},
+	} {
+		if !values.Has(list.param) {
+			continue
+		}
+		// an empty parameter clears the list
+		*list.dst = []string{}
+		for _, v := range values[list.param] {
+			if v != "" {
+				*list.dst = append(*list.dst, v)
+			}
+		}
+	}
+
+	if raw := values.Get("minCpu"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return o, true, fmt.Errorf("invalid minCpu %q", raw)
+		}
+		o.MinCPU = &v
+	}
+	if raw := values.Get("minMem"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return o, true, fmt.Errorf("invalid minMem %q", raw)
+		}
+		o.MinMem = &v
+	}
+	if raw := values.Get("kernelThreads"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return o, true, fmt.Errorf("invalid kernelThreads %q", raw)
+		}
+		o.KernelThreads = &v
+	}
+	if raw := values.Get("top"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return o, true, fmt.Errorf("invalid top %q", raw)
+		}
+		o.Top = &v
+	}
+	return o, found, proctable.ListFilter.Merge(o).Validate()
+}
+
+// filterProcesses replaces the cpu and memory process lists of a snapshot
+// with fresh ones from the process table, matching the configured filter
+// with the settings of o replaced. The snapshot's metrics are shared with
+// the aggregator, so they are copied rather than modified.
+func filterProcesses(ctx context.Context, snapshot *aggregator.Snapshot, o proctable.FilterOverride) error {
+	filter := proctable.ListFilter.Merge(o)
+	if snapshot.CPU != nil {
+		processes, err := cpu.Processes(ctx, filter)
+		if err != nil {
+			return err
+		}
+		c := *snapshot.CPU
+		c.Processes = processes
+		snapshot.CPU = &c
+	}
+	if snapshot.Memory != nil {
+		processes, err := memory.Processes(ctx, filter)
+		if err != nil {
+			return err
+		}
+		m := *snapshot.Memory
+		m.ProcessInfo = processes
+		snapshot.Memory = &m
+	}
+	return nil
+}
@@ -2,86 +2,293 @@ package dashboard
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"math"
 	"net/http"
 	"sort"
 	"time"
 
 	"github.com/jung-kurt/gofpdf"
+	"github.com/techtacles/sysmonitoring/internal/config"
 	"github.com/techtacles/sysmonitoring/internal/logging"
 	"github.com/techtacles/sysmonitoring/internal/metrics/aggregator"
-	"github.com/techtacles/sysmonitoring/internal/metrics/cpu"
-	"github.com/techtacles/sysmonitoring/internal/metrics/disk"
-	"github.com/techtacles/sysmonitoring/internal/metrics/memory"
+	"github.com/techtacles/sysmonitoring/internal/metrics/cgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/pressure"
+	"github.com/techtacles/sysmonitoring/internal/metrics/procgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+	"github.com/techtacles/sysmonitoring/internal/metrics/script"
+	"github.com/techtacles/sysmonitoring/internal/metrics/sensors"
 )
 
+const logtag = "dashboard"
+
+// Server timeouts. Writes get a generous timeout as PDF reports can take a
+// while to render; /api/stream clears it for its long-lived connections.
 const (
-	logtag          = "dashboard"
-	refreshInterval = 30 * time.Second // Increased frequency for "live" feel in background
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 2 * time.Minute
+	idleTimeout       = 2 * time.Minute
 )
 
 var Port string = "8080"
 
+// CollectInterval is how often collectors run unless overridden
+var CollectInterval time.Duration = aggregator.DefaultInterval
+
+// CollectorIntervals overrides CollectInterval per collector, eg "cpu" -> "5s"
+var CollectorIntervals map[string]string
+
+// CollectorTimeout bounds a single collector run
+var CollectorTimeout time.Duration = aggregator.DefaultTimeout
+
+// CollectorTimeouts overrides CollectorTimeout per collector, eg "kubernetes" -> "1m"
+var CollectorTimeouts map[string]string
+
+// ConfigFile is the optional JSON config file, eg with script collectors
+var ConfigFile string
+
+// ProcessFilter is the process filter given as flags, merged over the one
+// of the config file
+var ProcessFilter proctable.FilterOverride
+
+// ShutdownTimeout bounds how long in-flight requests and collections may take
+// to finish once Run is cancelled
+var ShutdownTimeout time.Duration = 30 * time.Second
+
 //go:embed images
 var imagesDir embed.FS
 
-// Run starts the dashboard server
-func Run(enableDocker, enableKubernetes bool, kubeconfigPath string) error {
+type collectorInfo struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Run starts the dashboard server and blocks until ctx is cancelled or the
+// server fails. On cancellation the collection loop is stopped, in-flight
+// requests and collections are given ShutdownTimeout to finish and the
+// metric store is closed.
+func Run(ctx context.Context, enableDocker, enableKubernetes bool, kubeconfigPath string) error {
+	token, err := controlToken()
+	if err != nil {
+		return err
+	}
+
 	ag := aggregator.NewAggregator(enableDocker, enableKubernetes, kubeconfigPath)
+	cfg, err := config.Load(ConfigFile)
+	if err != nil {
+		return err
+	}
+	for _, check := range cfg.Checks() {
+		if err := ag.RegisterScript(check); err != nil {
+			return fmt.Errorf("registering script %s: %w", check.Name, err)
+		}
+	}
+	if err := ag.RegisterProcessGroups(cfg.Groups()); err != nil {
+		return fmt.Errorf("registering process groups: %w", err)
+	}
+	if err := ag.RegisterCgroups(cfg.Cgroups); err != nil {
+		return fmt.Errorf("registering cgroups: %w", err)
+	}
+	// flags given replace the settings of the config file
+	filter := proctable.DefaultFilter.Merge(cfg.Filter()).Merge(ProcessFilter)
+	if err := filter.Validate(); err != nil {
+		return fmt.Errorf("invalid process filter: %w", err)
+	}
+	proctable.ListFilter = filter
+
+	ag.SetHistoryRetention(HistoryRetention)
+	ag.SetTimeout(CollectorTimeout)
+	for name, raw := range CollectorTimeouts {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q for collector %s: %w", raw, name, err)
+		}
+		ag.SetCollectorTimeout(name, timeout)
+	}
+	ag.SetInterval(CollectInterval)
+	for name, raw := range CollectorIntervals {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid interval %q for collector %s", raw, name)
+		}
+		ag.SetCollectorInterval(name, interval)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		logging.Error(logtag, "error opening metric store", err)
+		return err
+	}
+	if store != nil {
+		defer store.Close()
+		ag.SetStore(store)
+
+		maintainCtx, cancelMaintain := context.WithCancel(ctx)
+		defer cancelMaintain()
+		go maintainStore(maintainCtx, store)
+	}
 
-	logging.Info(logtag, "performing initial metrics collection")
-	ag.CollectAllConcurrent()
+	// Collections are not cancelled with ctx so they can finish during
+	// shutdown; they are only cut off once ShutdownTimeout has passed
+	collectCtx, cancelCollect := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelCollect()
 
+	logging.Info(logtag, "starting metrics collection")
+	loopDone := make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(refreshInterval)
-		defer ticker.Stop()
-		for range ticker.C {
-			logging.Info(logtag, "performing scheduled metrics collection")
-			ag.CollectAllConcurrent()
-		}
+		defer close(loopDone)
+		ag.Schedule(ctx, collectCtx)
 	}()
 
+	streamsDone := make(chan struct{})
+	srv := &http.Server{
+		Addr:              ":" + Port,
+		Handler:           newMux(ag, streamsDone, token),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	// streams never go idle, so they are closed explicitly on shutdown
+	srv.RegisterOnShutdown(func() {
+		close(streamsDone)
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logging.Info(logtag, fmt.Sprintf("starting dashboard server on http://localhost:%s", Port))
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		logging.Error(logtag, "dashboard server failed", err)
+		cancelCollect()
+		<-loopDone
+		return err
+	case <-ctx.Done():
+	}
+
+	logging.Info(logtag, "shutting down dashboard server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		logging.Error(logtag, "error draining connections", err)
+	}
+
+	select {
+	case <-loopDone:
+	case <-shutdownCtx.Done():
+		logging.Info(logtag, "cancelling in-flight collections")
+		cancelCollect()
+		<-loopDone
+	}
+
+	logging.Info(logtag, "dashboard server stopped")
+	return err
+}
+
+// newMux registers the dashboard routes. controlToken guards the process
+// control and process detail endpoints. streamsDone is closed to end
+// /api/stream connections.
+func newMux(ag *aggregator.Aggregator, streamsDone <-chan struct{}, controlToken string) *http.ServeMux {
+	mux := http.NewServeMux()
+
 	// Serve static images
-	http.Handle("/images/", http.FileServer(http.FS(imagesDir)))
+	mux.Handle("/images/", http.FileServer(http.FS(imagesDir)))
 
 	// Serve static web assets (CSS, JS)
-	http.Handle("/web/", http.FileServer(http.FS(WebAssets)))
+	mux.Handle("/web/", http.FileServer(http.FS(WebAssets)))
 
 	// API Endpoint for raw metrics
-	http.HandleFunc("/api/metrics", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/metrics", func(w http.ResponseWriter, r *http.Request) {
+		snapshot := ag.Snapshot()
+		o, filtered, err := parseProcessFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filtered {
+			if err := filterProcesses(r.Context(), &snapshot, o); err != nil {
+				logging.Error(logtag, "error filtering processes", err)
+				http.Error(w, "error filtering processes", http.StatusInternalServerError)
+				return
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		metrics := ag.GetMetrics()
-		if err := json.NewEncoder(w).Encode(metrics); err != nil {
+		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
 			logging.Error(logtag, "error encoding metrics to json", err)
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 		}
 	})
 
+	// API Endpoint for downsampled metric history
+	mux.HandleFunc("/api/history", handleHistory(ag))
+
+	// API Endpoint for the full process table
+	mux.HandleFunc("/api/processes", handleProcesses())
+	mux.HandleFunc("/api/processes/tree", handleProcessTree())
+	mux.HandleFunc("/api/processes/{pid}", handleProcess(controlToken))
+	mux.HandleFunc("POST /api/processes/{pid}/signal", handleControl("signal", controlToken))
+	mux.HandleFunc("POST /api/processes/{pid}/renice", handleControl("renice", controlToken))
+
+	// Live push of refreshed metrics
+	mux.HandleFunc("/api/stream", handleStream(ag, streamsDone))
+
+	// API Endpoint for collector health
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ag.Statuses()); err != nil {
+			logging.Error(logtag, "error encoding status to json", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	})
+
+	// API Endpoint listing the registered collectors
+	mux.HandleFunc("/api/collectors", func(w http.ResponseWriter, r *http.Request) {
+		collectors := ag.Collectors()
+		results := make([]collectorInfo, 0, len(collectors))
+		for _, c := range collectors {
+			results = append(results, collectorInfo{Name: c.Name(), Enabled: c.Enabled()})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			logging.Error(logtag, "error encoding collectors to json", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	})
+
 	// API Endpoint for multi-format report export
-	http.HandleFunc("/api/report", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/report", func(w http.ResponseWriter, r *http.Request) {
 		format := r.URL.Query().Get("format")
 		if format == "" {
 			format = "json"
 		}
 
-		metrics := ag.GetMetrics()
+		snapshot := ag.Snapshot()
 
 		switch format {
 		case "csv":
-			generateCSVReport(w, metrics)
+			generateCSVReport(w, snapshot)
 		case "pdf":
-			generatePDFReport(w, metrics)
+			generatePDFReport(w, snapshot)
 		default: // default to json
-			generateJSONReport(w, metrics)
+			generateJSONReport(w, snapshot)
 		}
 	})
 
 	// Dashboard UI
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		t, err := template.ParseFS(WebAssets, "web/index.html")
 		if err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -94,12 +301,11 @@ func Run(enableDocker, enableKubernetes bool, kubeconfigPath string) error {
 		}
 	})
 
-	logging.Info(logtag, fmt.Sprintf("starting dashboard server on http://localhost:%s", Port))
-	return http.ListenAndServe(":"+Port, nil)
+	return mux
 }
 
-func generateJSONReport(w http.ResponseWriter, metrics map[string]interface{}) {
-	metricsJSON, err := json.MarshalIndent(metrics, "", "  ")
+func generateJSONReport(w http.ResponseWriter, snapshot aggregator.Snapshot) {
+	metricsJSON, err := json.MarshalIndent(snapshot, "", "  ")
 	if err != nil {
 		logging.Error(logtag, "error marshaling metrics for report", err)
 		http.Error(w, "error generating report", http.StatusInternalServerError)
@@ -112,7 +318,7 @@ func generateJSONReport(w http.ResponseWriter, metrics map[string]interface{}) {
 	w.Write(metricsJSON)
 }
 
-func generateCSVReport(w http.ResponseWriter, metrics map[string]interface{}) {
+func generateCSVReport(w http.ResponseWriter, snapshot aggregator.Snapshot) {
 	w.Header().Set("Content-Type", "text/csv")
 	filename := fmt.Sprintf("sysmon-report-%s.csv", time.Now().Format("2006-01-02-150405"))
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
@@ -123,37 +329,107 @@ func generateCSVReport(w http.ResponseWriter, metrics map[string]interface{}) {
 	// Write System Summary
 	writer.Write([]string{"Section", "Metric", "Value"})
 
+	writer.Write([]string{"Report", "Host", snapshot.Identity.Hostname})
+	writer.Write([]string{"Report", "Collected At", snapshot.Timestamp.Format(time.RFC3339)})
+
 	// CPU Summary
-	if cpuRaw, ok := metrics["cpu"]; ok {
-		if c, ok := cpuRaw.(cpu.CpuInfo); ok {
-			writer.Write([]string{"CPU", "Average Load", fmt.Sprintf("%.2f%%", c.AveragePercentages)})
-			writer.Write([]string{"CPU", "Physical Cores", fmt.Sprintf("%d", c.PhysicalCores)})
-			writer.Write([]string{"CPU", "Logical Cores", fmt.Sprintf("%d", c.LogicalCores)})
+	if c := snapshot.CPU; c != nil {
+		writer.Write([]string{"CPU", "Average Load", fmt.Sprintf("%.2f%%", c.AveragePercentages)})
+		writer.Write([]string{"CPU", "Physical Cores", fmt.Sprintf("%d", c.PhysicalCores)})
+		writer.Write([]string{"CPU", "Logical Cores", fmt.Sprintf("%d", c.LogicalCores)})
+		if hw := c.Hardware; hw != nil {
+			writer.Write([]string{"CPU", "Model", hw.ModelName})
+			writer.Write([]string{"CPU", "Vendor", hw.VendorID})
+			writer.Write([]string{"CPU", "Sockets", fmt.Sprintf("%d", hw.Sockets)})
+			writer.Write([]string{"CPU", "Cache", fmt.Sprintf("%d KB", hw.CacheSize)})
+		}
+		for _, f := range c.Frequencies {
+			writer.Write([]string{"CPU", f.CPU + " Frequency", fmt.Sprintf("%.0f MHz (min %.0f, max %.0f, throttled %d)", f.CurrentMHz, f.MinMHz, f.MaxMHz, f.ThrottleCount)})
+		}
+		writer.Write([]string{"CPU", "User Time", fmt.Sprintf("%.2f%%", c.TotalTimes.User)})
+		writer.Write([]string{"CPU", "System Time", fmt.Sprintf("%.2f%%", c.TotalTimes.System)})
+		writer.Write([]string{"CPU", "Nice Time", fmt.Sprintf("%.2f%%", c.TotalTimes.Nice)})
+		writer.Write([]string{"CPU", "IO Wait Time", fmt.Sprintf("%.2f%%", c.TotalTimes.Iowait)})
+		writer.Write([]string{"CPU", "IRQ Time", fmt.Sprintf("%.2f%%", c.TotalTimes.Irq)})
+		writer.Write([]string{"CPU", "SoftIRQ Time", fmt.Sprintf("%.2f%%", c.TotalTimes.Softirq)})
+		writer.Write([]string{"CPU", "Steal Time", fmt.Sprintf("%.2f%%", c.TotalTimes.Steal)})
+		writer.Write([]string{"CPU", "Idle Time", fmt.Sprintf("%.2f%%", c.TotalTimes.Idle)})
+	}
+
+	// Sensors
+	if s := snapshot.Sensors; s != nil {
+		for _, sensor := range s.Sensors {
+			writer.Write([]string{"Sensors", sensor.Name, sensorSummary(sensor)})
 		}
 	}
 
 	// Memory Summary
-	if memRaw, ok := metrics["memory"]; ok {
-		if m, ok := memRaw.(memory.MemoryInfo); ok {
-			writer.Write([]string{"Memory", "Used Percentage", fmt.Sprintf("%.2f%%", m.Vmemory.UsedPercentage)})
-			writer.Write([]string{"Memory", "Total", fmt.Sprintf("%.2f GB", float64(m.Vmemory.Total)/1024/1024/1024)})
-			writer.Write([]string{"Memory", "Used", fmt.Sprintf("%.2f GB", float64(m.Vmemory.Used)/1024/1024/1024)})
+	if m := snapshot.Memory; m != nil {
+		writer.Write([]string{"Memory", "Used Percentage", fmt.Sprintf("%.2f%%", m.Vmemory.UsedPercentage)})
+		writer.Write([]string{"Memory", "Total", fmt.Sprintf("%.2f GB", float64(m.Vmemory.Total)/1024/1024/1024)})
+		writer.Write([]string{"Memory", "Used", fmt.Sprintf("%.2f GB", float64(m.Vmemory.Used)/1024/1024/1024)})
+	}
+
+	// Cgroups
+	if cg := snapshot.Cgroups; cg != nil {
+		for _, c := range cg.Cgroups {
+			writer.Write([]string{"Cgroup", c.Name, cgroupSummary(c)})
 		}
 	}
 
 	// Disk Summary
-	if diskRaw, ok := metrics["disk"]; ok {
-		if d, ok := diskRaw.(disk.DiskInfo); ok {
-			for path, usage := range d.UsageStat {
-				usedGB := float64(usage.UsedDisk) / 1024 / 1024 / 1024
-				totalGB := float64(usage.TotalDisk) / 1024 / 1024 / 1024
-				writer.Write([]string{"Disk", path, fmt.Sprintf("%.2f GB / %.2f GB (%.1f%%)", usedGB, totalGB, usage.UsedPercent)})
+	if d := snapshot.Disk; d != nil {
+		for path, usage := range d.UsageStat {
+			usedGB := float64(usage.UsedDisk) / 1024 / 1024 / 1024
+			totalGB := float64(usage.TotalDisk) / 1024 / 1024 / 1024
+			writer.Write([]string{"Disk", path, fmt.Sprintf("%.2f GB / %.2f GB (%.1f%%)", usedGB, totalGB, usage.UsedPercent)})
+		}
+	}
+
+	// Pressure Stall Information
+	if p := snapshot.Pressure; p != nil {
+		for _, r := range p.Resources() {
+			if r.Some != nil {
+				writer.Write([]string{"Pressure", r.Name + " some", stallSummary(r.Some)})
 			}
+			if r.Full != nil {
+				writer.Write([]string{"Pressure", r.Name + " full", stallSummary(r.Full)})
+			}
+		}
+	}
+
+	// Kernel Activity
+	if k := snapshot.Kernel; k != nil {
+		rates := snapshot.Rates["kernel"]
+		writer.Write([]string{"Kernel", "Context Switches", counterSummary(k.ContextSwitches, rates, "contextSwitches")})
+		writer.Write([]string{"Kernel", "Forks", counterSummary(k.Forks, rates, "forks")})
+		writer.Write([]string{"Kernel", "Interrupts", counterSummary(k.Interrupts, rates, "interrupts")})
+		writer.Write([]string{"Kernel", "SoftIRQs", counterSummary(k.SoftIRQs, rates, "softirqs")})
+		writer.Write([]string{"Kernel", "Processes Running", fmt.Sprintf("%d", k.ProcsRunning)})
+		writer.Write([]string{"Kernel", "Processes Blocked", fmt.Sprintf("%d", k.ProcsBlocked)})
+		for _, c := range k.CPUs {
+			writer.Write([]string{"Kernel", c.CPU + " Interrupts", counterSummary(c.Interrupts, rates, c.CPU+"/interrupts")})
+		}
+	}
+
+	// Process Groups
+	if pg := snapshot.ProcessGroups; pg != nil {
+		for _, g := range pg.Groups {
+			writer.Write([]string{"Process Group", g.Name, groupSummary(g)})
+		}
+	}
+
+	// Script Checks
+	for _, name := range scriptNames(snapshot) {
+		result := snapshot.Extra[name].(*script.Result)
+		writer.Write([]string{"Script", name, scriptSummary(result)})
+		for _, p := range result.PerfData {
+			writer.Write([]string{"Script", name + "/" + p.Label, fmt.Sprintf("%g%s", p.Value, p.Unit)})
 		}
 	}
 }
 
-func generatePDFReport(w http.ResponseWriter, metrics map[string]interface{}) {
+func generatePDFReport(w http.ResponseWriter, snapshot aggregator.Snapshot) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 	pdf.SetFont("Arial", "B", 16)
@@ -162,6 +438,8 @@ func generatePDFReport(w http.ResponseWriter, metrics map[string]interface{}) {
 
 	pdf.SetFont("Arial", "", 10)
 	pdf.Cell(40, 10, fmt.Sprintf("Generated on: %s", time.Now().Format(time.RFC1123)))
+	pdf.Ln(6)
+	pdf.Cell(40, 10, fmt.Sprintf("Host: %s (%s/%s)", snapshot.Identity.Hostname, snapshot.Identity.OS, snapshot.Identity.Arch))
 	pdf.Ln(15)
 
 	// CPU Section
@@ -170,15 +448,47 @@ func generatePDFReport(w http.ResponseWriter, metrics map[string]interface{}) {
 	pdf.CellFormat(190, 8, "CPU Metrics", "1", 0, "L", true, 0, "")
 	pdf.Ln(10)
 	pdf.SetFont("Arial", "", 10)
-	if cpuRaw, ok := metrics["cpu"]; ok {
-		if c, ok := cpuRaw.(cpu.CpuInfo); ok {
-			pdf.Cell(95, 8, fmt.Sprintf("Average Load: %.2f%%", c.AveragePercentages))
+	if c := snapshot.CPU; c != nil {
+		pdf.Cell(95, 8, fmt.Sprintf("Average Load: %.2f%%", c.AveragePercentages))
+		pdf.Ln(6)
+		pdf.Cell(95, 8, fmt.Sprintf("Physical Cores: %d", c.PhysicalCores))
+		pdf.Ln(6)
+		pdf.Cell(95, 8, fmt.Sprintf("Logical Cores: %d", c.LogicalCores))
+		pdf.Ln(6)
+		if hw := c.Hardware; hw != nil {
+			pdf.Cell(190, 8, fmt.Sprintf("Model: %s (%d socket(s), %d KB cache)", hw.ModelName, hw.Sockets, hw.CacheSize))
+			pdf.Ln(6)
+		}
+		if len(c.Frequencies) > 0 {
+			var current, max float64
+			var throttled uint64
+			for _, f := range c.Frequencies {
+				current += f.CurrentMHz
+				max = math.Max(max, f.MaxMHz)
+				throttled += f.ThrottleCount
+			}
+			pdf.Cell(190, 8, fmt.Sprintf("Frequency: %.0f MHz average, %.0f MHz max, %d throttling events", current/float64(len(c.Frequencies)), max, throttled))
 			pdf.Ln(6)
-			pdf.Cell(95, 8, fmt.Sprintf("Physical Cores: %d", c.PhysicalCores))
+		}
+		t := c.TotalTimes
+		pdf.Cell(190, 8, fmt.Sprintf("Time: user %.1f%%, system %.1f%%, iowait %.1f%%, steal %.1f%%, irq %.1f%%, softirq %.1f%%, idle %.1f%%",
+			t.User, t.System, t.Iowait, t.Steal, t.Irq, t.Softirq, t.Idle))
+		pdf.Ln(10)
+	}
+
+	// Sensors Section
+	if s := snapshot.Sensors; s != nil && len(s.Sensors) > 0 {
+		// the core fonts are cp1252, which has the degree sign
+		tr := pdf.UnicodeTranslatorFromDescriptor("")
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, 8, fmt.Sprintf("Sensors (%d above high)", s.AboveHigh), "1", 0, "L", true, 0, "")
+		pdf.Ln(10)
+		pdf.SetFont("Arial", "", 10)
+		for _, sensor := range s.Sensors {
+			pdf.Cell(190, 8, tr(fmt.Sprintf("%s: %s", sensor.Name, sensorSummary(sensor))))
 			pdf.Ln(6)
-			pdf.Cell(95, 8, fmt.Sprintf("Logical Cores: %d", c.LogicalCores))
-			pdf.Ln(10)
 		}
+		pdf.Ln(4)
 	}
 
 	// Memory Section
@@ -186,13 +496,23 @@ func generatePDFReport(w http.ResponseWriter, metrics map[string]interface{}) {
 	pdf.CellFormat(190, 8, "Memory Metrics", "1", 0, "L", true, 0, "")
 	pdf.Ln(10)
 	pdf.SetFont("Arial", "", 10)
-	if memRaw, ok := metrics["memory"]; ok {
-		if m, ok := memRaw.(memory.MemoryInfo); ok {
-			usedGB := float64(m.Vmemory.Used) / 1024 / 1024 / 1024
-			totalGB := float64(m.Vmemory.Total) / 1024 / 1024 / 1024
-			pdf.Cell(95, 8, fmt.Sprintf("Usage: %.2f%% (%.2f GB / %.2f GB)", m.Vmemory.UsedPercentage, usedGB, totalGB))
-			pdf.Ln(10)
+	if m := snapshot.Memory; m != nil {
+		usedGB := float64(m.Vmemory.Used) / 1024 / 1024 / 1024
+		totalGB := float64(m.Vmemory.Total) / 1024 / 1024 / 1024
+		pdf.Cell(95, 8, fmt.Sprintf("Usage: %.2f%% (%.2f GB / %.2f GB)", m.Vmemory.UsedPercentage, usedGB, totalGB))
+		pdf.Ln(10)
+	}
+
+	// Cgroups Section
+	if cg := snapshot.Cgroups; cg != nil && len(cg.Cgroups) > 0 {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, 8, fmt.Sprintf("Cgroups (v%d)", cg.Version), "1", 0, "L", true, 0, "")
+		pdf.Ln(10)
+		pdf.SetFont("Arial", "", 10)
+		for _, c := range cg.Cgroups {
+			pdf.MultiCell(190, 6, fmt.Sprintf("%s (%s): %s", c.Name, c.Path, cgroupSummary(c)), "", "L", false)
 		}
+		pdf.Ln(4)
 	}
 
 	// Disk Section
@@ -200,22 +520,82 @@ func generatePDFReport(w http.ResponseWriter, metrics map[string]interface{}) {
 	pdf.CellFormat(190, 8, "Disk Metrics", "1", 0, "L", true, 0, "")
 	pdf.Ln(10)
 	pdf.SetFont("Arial", "", 10)
-	if diskRaw, ok := metrics["disk"]; ok {
-		if d, ok := diskRaw.(disk.DiskInfo); ok {
-			// Sort names for predictable order
-			var paths []string
-			for p := range d.UsageStat {
-				paths = append(paths, p)
-			}
-			sort.Strings(paths)
+	if d := snapshot.Disk; d != nil {
+		// Sort names for predictable order
+		var paths []string
+		for p := range d.UsageStat {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
 
-			for _, path := range paths {
-				usage := d.UsageStat[path]
-				usedGB := float64(usage.UsedDisk) / 1024 / 1024 / 1024
-				totalGB := float64(usage.TotalDisk) / 1024 / 1024 / 1024
-				pdf.Cell(190, 8, fmt.Sprintf("%s: %.2f GB / %.2f GB (%.1f%%)", path, usedGB, totalGB, usage.UsedPercent))
+		for _, path := range paths {
+			usage := d.UsageStat[path]
+			usedGB := float64(usage.UsedDisk) / 1024 / 1024 / 1024
+			totalGB := float64(usage.TotalDisk) / 1024 / 1024 / 1024
+			pdf.Cell(190, 8, fmt.Sprintf("%s: %.2f GB / %.2f GB (%.1f%%)", path, usedGB, totalGB, usage.UsedPercent))
+			pdf.Ln(6)
+		}
+	}
+
+	// Pressure Section
+	if p := snapshot.Pressure; p != nil {
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, 8, "Pressure Stall Information", "1", 0, "L", true, 0, "")
+		pdf.Ln(10)
+		pdf.SetFont("Arial", "", 10)
+		for _, r := range p.Resources() {
+			if r.Some != nil {
+				pdf.Cell(190, 8, fmt.Sprintf("%s some: %s", r.Name, stallSummary(r.Some)))
 				pdf.Ln(6)
 			}
+			if r.Full != nil {
+				pdf.Cell(190, 8, fmt.Sprintf("%s full: %s", r.Name, stallSummary(r.Full)))
+				pdf.Ln(6)
+			}
+		}
+	}
+
+	// Kernel Section
+	if k := snapshot.Kernel; k != nil {
+		rates := snapshot.Rates["kernel"]
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, 8, "Kernel Activity", "1", 0, "L", true, 0, "")
+		pdf.Ln(10)
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(190, 8, fmt.Sprintf("Context Switches: %s", counterSummary(k.ContextSwitches, rates, "contextSwitches")))
+		pdf.Ln(6)
+		pdf.Cell(190, 8, fmt.Sprintf("Forks: %s", counterSummary(k.Forks, rates, "forks")))
+		pdf.Ln(6)
+		pdf.Cell(190, 8, fmt.Sprintf("Interrupts: %s, SoftIRQs: %s", counterSummary(k.Interrupts, rates, "interrupts"), counterSummary(k.SoftIRQs, rates, "softirqs")))
+		pdf.Ln(6)
+		pdf.Cell(190, 8, fmt.Sprintf("Processes: %d running, %d blocked", k.ProcsRunning, k.ProcsBlocked))
+		pdf.Ln(6)
+	}
+
+	// Process Groups Section
+	if pg := snapshot.ProcessGroups; pg != nil && len(pg.Groups) > 0 {
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, 8, "Process Groups", "1", 0, "L", true, 0, "")
+		pdf.Ln(10)
+		pdf.SetFont("Arial", "", 10)
+		for _, g := range pg.Groups {
+			pdf.MultiCell(190, 6, fmt.Sprintf("%s: %s", g.Name, groupSummary(g)), "", "L", false)
+		}
+	}
+
+	// Script Section
+	if names := scriptNames(snapshot); len(names) > 0 {
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(190, 8, "Script Checks", "1", 0, "L", true, 0, "")
+		pdf.Ln(10)
+		pdf.SetFont("Arial", "", 10)
+		for _, name := range names {
+			result := snapshot.Extra[name].(*script.Result)
+			pdf.MultiCell(190, 6, fmt.Sprintf("%s: %s", name, scriptSummary(result)), "", "L", false)
 		}
 	}
 
@@ -232,3 +612,99 @@ func generatePDFReport(w http.ResponseWriter, metrics map[string]interface{}) {
 	}
 	w.Write(buf.Bytes())
 }
+
+// cgroupSummary renders a cgroup's usage against its limits on one line for
+// the reports
+func cgroupSummary(c cgroup.CgroupInfo) string {
+	if c.Error != "" {
+		return "error: " + c.Error
+	}
+	memory := "memory n/a"
+	if c.Available(cgroup.ControllerMemory) {
+		memory = fmt.Sprintf("memory %d MB", c.MemoryUsage/1024/1024)
+		if c.MemoryLimit > 0 {
+			memory += fmt.Sprintf(" of %d MB (%.1f%%)", c.MemoryLimit/1024/1024, c.MemoryPercent)
+		}
+	}
+	cpu := "cpu n/a"
+	if c.Available(cgroup.ControllerCPU) {
+		cpu = "cpu unlimited"
+		if c.CPULimit > 0 {
+			cpu = fmt.Sprintf("cpu limit %.2f", c.CPULimit)
+		}
+		cpu += fmt.Sprintf(", throttled %.1f%% of periods (%s)", c.ThrottledPercent,
+			(time.Duration(c.ThrottledTime) * time.Microsecond).Round(time.Millisecond))
+	}
+	pids := "pids n/a"
+	if c.Available(cgroup.ControllerPids) {
+		pids = fmt.Sprintf("%d pids", c.Pids)
+	}
+	return fmt.Sprintf("%s, %s, %s", memory, cpu, pids)
+}
+
+// sensorSummary renders a sensor reading on one line for the reports
+func sensorSummary(s sensors.Sensor) string {
+	summary := fmt.Sprintf("%.1f %s", s.Current, s.Unit)
+	if s.High > 0 {
+		summary += fmt.Sprintf(", high %.1f", s.High)
+	}
+	if s.Critical > 0 {
+		summary += fmt.Sprintf(", critical %.1f", s.Critical)
+	}
+	if s.AboveCritical {
+		summary += " (CRITICAL)"
+	} else if s.AboveHigh {
+		summary += " (HIGH)"
+	}
+	return summary
+}
+
+// counterSummary renders a cumulative counter and, once two collections
+// have happened, its rate
+func counterSummary(total uint64, rates map[string]float64, key string) string {
+	if rate, ok := rates[key]; ok {
+		return fmt.Sprintf("%d (%.1f/s)", total, rate)
+	}
+	return fmt.Sprintf("%d", total)
+}
+
+// stallSummary renders a pressure stall line on one line for the reports
+func stallSummary(s *pressure.Stall) string {
+	return fmt.Sprintf("avg10 %.2f%%, avg60 %.2f%%, avg300 %.2f%%, total %s",
+		s.Avg10, s.Avg60, s.Avg300, (time.Duration(s.Total) * time.Microsecond).Round(time.Millisecond))
+}
+
+// groupSummary renders a process group on one line for the reports
+func groupSummary(g procgroup.GroupInfo) string {
+	return fmt.Sprintf("%s, %d/%d instances, CPU %.1f%%, RSS %d MB, %d restarts",
+		g.Status, g.Instances, g.MinInstances, g.CPUPercent, g.RSS/1024/1024, g.Restarts)
+}
+
+// scriptNames returns the sorted names of the script results in a snapshot
+func scriptNames(snapshot aggregator.Snapshot) []string {
+	var names []string
+	for name, value := range snapshot.Extra {
+		if _, ok := value.(*script.Result); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scriptSummary renders a script result on one line for the reports
+func scriptSummary(result *script.Result) string {
+	if result.Format == script.FormatNagios {
+		return fmt.Sprintf("%s: %s", result.Status, result.Output)
+	}
+
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		return "unprintable output"
+	}
+	const maxLen = 200
+	if len(data) > maxLen {
+		return string(data[:maxLen]) + "..."
+	}
+	return string(data)
+}
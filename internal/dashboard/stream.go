@@ -0,0 +1,128 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/aggregator"
+)
+
+const (
+	// streamCoalesce is how long the stream waits for further collector runs
+	// so that a collection round is pushed as one update
+	streamCoalesce = 250 * time.Millisecond
+	// streamHeartbeat keeps idle connections open through proxies
+	streamHeartbeat = 15 * time.Second
+)
+
+// handleStream serves /api/stream as Server-Sent Events. A client first
+// receives a "snapshot" event with every metric and a "status" event. After
+// every collection round it receives an "update" event holding only the
+// collectors that were refreshed, followed by a fresh "status" event. The
+// stream ends when the client goes away or done is closed.
+func handleStream(ag *aggregator.Aggregator, done <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		// the server write timeout would otherwise cut off the stream
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			logging.Error(logtag, "error clearing write deadline for event stream", err)
+		}
+
+		events, cancel := ag.Subscribe(0)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		if err := writeEvent(w, "snapshot", ag.Snapshot()); err != nil {
+			return
+		}
+		if err := writeEvent(w, "status", ag.Statuses()); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			logging.Error(logtag, "error flushing event stream", err)
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-done:
+				return
+			case <-heartbeat.C:
+				if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+
+				changed := coalesce(r, ev, events)
+				if len(changed) > 0 {
+					if err := writeEvent(w, "update", ag.Snapshot(changed...)); err != nil {
+						return
+					}
+				}
+				if err := writeEvent(w, "status", ag.Statuses()); err != nil {
+					return
+				}
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// coalesce gathers the events arriving shortly after first and returns the
+// names of the collectors that produced a new result
+func coalesce(r *http.Request, first aggregator.Event, events <-chan aggregator.Event) []string {
+	seen := make(map[string]bool)
+	var changed []string
+	add := func(ev aggregator.Event) {
+		if ev.Err == nil && !seen[ev.Name] {
+			seen[ev.Name] = true
+			changed = append(changed, ev.Name)
+		}
+	}
+	add(first)
+
+	timer := time.NewTimer(streamCoalesce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return changed
+		case <-timer.C:
+			return changed
+		case ev, ok := <-events:
+			if !ok {
+				return changed
+			}
+			add(ev)
+		}
+	}
+}
+
+// writeEvent writes one Server-Sent Event with a JSON payload
+func writeEvent(w io.Writer, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logging.Error(logtag, fmt.Sprintf("error encoding %s event to json", event), err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
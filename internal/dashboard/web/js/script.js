@@ -4,6 +4,7 @@ let topCpuChart, topMemChart, topThreadsChart;
 let netConnChart, userProcChart;
 let memVmsRssChart, memHeapStackChart;
 let dockerContainerChart, dockerDiskChart;
+let historyChart;
 
 function initCharts() {
     const commonOptions = {
@@ -74,6 +75,44 @@ function initCharts() {
         }
     });
 
+    // History Chart
+    historyChart = new Chart(document.getElementById('historyChart').getContext('2d'), {
+        type: 'line',
+        data: {
+            labels: [],
+            datasets: [{
+                label: 'CPU %',
+                data: [],
+                borderColor: '#0ea5e9',
+                backgroundColor: 'rgba(14, 165, 233, 0.15)',
+                fill: true,
+                tension: 0.3,
+                pointRadius: 0
+            }, {
+                label: 'Memory %',
+                data: [],
+                borderColor: '#a78bfa',
+                backgroundColor: 'rgba(167, 139, 250, 0.15)',
+                fill: true,
+                tension: 0.3,
+                pointRadius: 0
+            }]
+        },
+        options: {
+            ...commonOptions,
+            // the series are plotted against their own timestamps, in
+            // milliseconds, as they need not have the same points
+            plugins: {
+                ...commonOptions.plugins,
+                tooltip: { callbacks: { title: items => items.length ? formatTime(items[0].parsed.x) : '' } }
+            },
+            scales: {
+                y: { beginAtZero: true, max: 100, grid: { color: '#334155' }, ticks: { color: '#94a3b8' } },
+                x: { type: 'linear', grid: { color: '#334155' }, ticks: { color: '#94a3b8', maxTicksLimit: 12, callback: formatTime } }
+            }
+        }
+    });
+
     // Disk Chart
     diskChart = new Chart(document.getElementById('diskChart').getContext('2d'), {
         type: 'bar',
@@ -261,26 +300,50 @@ function formatBytes(bytes) {
     return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
 }
 
+// latest holds the full snapshot that stream updates are merged into
+let latest = {};
+
 async function updateData() {
     try {
         const response = await fetch('/api/metrics');
-        const data = await response.json();
+        latest = await response.json();
+        render(latest);
+    } catch (err) {
+        console.error("Error fetching metrics:", err);
+    }
+}
+
+// applyUpdate merges a partial snapshot holding only the refreshed collectors
+function applyUpdate(partial) {
+    const merged = ['collectedAt', 'rates', 'extra'];
+    Object.keys(partial).forEach(key => {
+        if (merged.includes(key)) {
+            latest[key] = Object.assign(latest[key] || {}, partial[key]);
+        } else {
+            latest[key] = partial[key];
+        }
+    });
+    render(latest);
+}
+
+function render(data) {
+    try {
 
         // Update System Info
         if (data.user) {
             document.getElementById('system-info').innerHTML =
-                '<div class="info-row"><span class="info-label">User</span><span>' + data.user.Username + '</span></div>' +
-                '<div class="info-row"><span class="info-label">Hostname</span><span>' + (data.user.FullName || 'N/A') + '</span></div>';
+                '<div class="info-row"><span class="info-label">User</span><span>' + data.user.username + '</span></div>' +
+                '<div class="info-row"><span class="info-label">Hostname</span><span>' + (data.user.fullName || 'N/A') + '</span></div>';
         }
 
         if (data.host) {
             document.getElementById('host-info').innerHTML =
-                '<div class="info-row"><span class="info-label">OS</span><span>' + data.host.OS + ' ' + (data.host.PlatformVer || '') + '</span></div>' +
-                '<div class="info-row"><span class="info-label">Kernel</span><span>' + data.host.KernelVersion + '</span></div>' +
-                '<div class="info-row"><span class="info-label">Platform</span><span>' + data.host.Platform + '</span></div>';
+                '<div class="info-row"><span class="info-label">OS</span><span>' + data.host.os + ' ' + (data.host.platformVer || '') + '</span></div>' +
+                '<div class="info-row"><span class="info-label">Kernel</span><span>' + data.host.kernelVersion + '</span></div>' +
+                '<div class="info-row"><span class="info-label">Platform</span><span>' + data.host.platform + '</span></div>';
 
             // Uptime
-            const uptimeSec = data.host.Uptime;
+            const uptimeSec = data.host.uptime;
             const days = Math.floor(uptimeSec / 86400);
             const hours = Math.floor((uptimeSec % 86400) / 3600);
             const minutes = Math.floor((uptimeSec % 3600) / 60);
@@ -295,41 +358,41 @@ async function updateData() {
 
             // Load Avg
             document.getElementById('load-avg').textContent =
-                data.host.LoadAvg1.toFixed(2) + ' | ' +
-                data.host.LoadAvg5.toFixed(2) + ' | ' +
-                data.host.LoadAvg15.toFixed(2);
+                data.host.loadAvg1.toFixed(2) + ' | ' +
+                data.host.loadAvg5.toFixed(2) + ' | ' +
+                data.host.loadAvg15.toFixed(2);
         }
 
         // Update CPU & Cores
         if (data.cpu) {
-            document.getElementById('cpu-total').textContent = data.cpu.AveragePercentages.toFixed(1) + '%';
-            document.getElementById('cpu-physical').textContent = data.cpu.PhysicalCores;
-            document.getElementById('cpu-logical').textContent = data.cpu.LogicalCores;
-            document.getElementById('proc-count').textContent = data.cpu.Processes ? data.cpu.Processes.length : 0;
-
-            if (data.cpu.Percentages) {
-                cpuCoreChart.data.labels = data.cpu.Percentages.map((_, i) => 'Core ' + i);
-                cpuCoreChart.data.datasets[0].data = data.cpu.Percentages;
+            document.getElementById('cpu-total').textContent = data.cpu.averagePercentages.toFixed(1) + '%';
+            document.getElementById('cpu-physical').textContent = data.cpu.physicalCores;
+            document.getElementById('cpu-logical').textContent = data.cpu.logicalCores;
+            document.getElementById('proc-count').textContent = data.cpu.processes ? data.cpu.processes.length : 0;
+
+            if (data.cpu.percentages) {
+                cpuCoreChart.data.labels = data.cpu.percentages.map((_, i) => 'Core ' + i);
+                cpuCoreChart.data.datasets[0].data = data.cpu.percentages;
                 cpuCoreChart.update();
             }
 
-            if (data.cpu.Processes && data.cpu.Processes.length > 0) {
+            if (data.cpu.processes && data.cpu.processes.length > 0) {
                 // Top CPU
-                const sortedByCpu = [...data.cpu.Processes].sort((a, b) => b.CpuPercent - a.CpuPercent).slice(0, 5);
-                topCpuChart.data.labels = sortedByCpu.map(p => p.ProcessName || p.Pid);
-                topCpuChart.data.datasets[0].data = sortedByCpu.map(p => p.CpuPercent);
+                const sortedByCpu = [...data.cpu.processes].sort((a, b) => b.cpuPercent - a.cpuPercent).slice(0, 5);
+                topCpuChart.data.labels = sortedByCpu.map(p => p.processName || p.pid);
+                topCpuChart.data.datasets[0].data = sortedByCpu.map(p => p.cpuPercent);
                 topCpuChart.update();
 
                 // Top Threads
-                const sortedByThreads = [...data.cpu.Processes].sort((a, b) => b.NumThreads - a.NumThreads).slice(0, 5);
-                topThreadsChart.data.labels = sortedByThreads.map(p => p.ProcessName || p.Pid);
-                topThreadsChart.data.datasets[0].data = sortedByThreads.map(p => p.NumThreads);
+                const sortedByThreads = [...data.cpu.processes].sort((a, b) => b.numThreads - a.numThreads).slice(0, 5);
+                topThreadsChart.data.labels = sortedByThreads.map(p => p.processName || p.pid);
+                topThreadsChart.data.datasets[0].data = sortedByThreads.map(p => p.numThreads);
                 topThreadsChart.update();
 
                 // User Processes Distribution
                 const userCounts = {};
-                data.cpu.Processes.forEach(p => {
-                    const u = p.Username || 'unknown';
+                data.cpu.processes.forEach(p => {
+                    const u = p.username || 'unknown';
                     userCounts[u] = (userCounts[u] || 0) + 1;
                 });
                 userProcChart.data.labels = Object.keys(userCounts);
@@ -338,54 +401,84 @@ async function updateData() {
             }
         }
 
+        // Update Sensors, hidden on machines without any
+        const sensorsSection = document.getElementById('sensors-section');
+        if (data.sensors && data.sensors.sensors && data.sensors.sensors.length > 0) {
+            sensorsSection.style.display = 'grid';
+            const reading = (value, unit) => value ? value.toFixed(1) + ' ' + unit : '-';
+            // names come from hwmon label files, so cells are set as text
+            const rows = data.sensors.sensors.map(s => {
+                const status = s.aboveCritical ? 'Critical' : (s.aboveHigh ? 'High' : 'OK');
+                const row = document.createElement('tr');
+                [s.name, reading(s.current, s.unit), reading(s.high, s.unit), reading(s.critical, s.unit)].forEach((text, i) => {
+                    const cell = row.insertCell();
+                    cell.textContent = text;
+                    if (i > 0) cell.className = 'text-right';
+                });
+                const badge = document.createElement('span');
+                badge.className = 'badge';
+                badge.style.background = getSensorStatusColor(status);
+                badge.textContent = status;
+                row.insertCell().appendChild(badge);
+                return row;
+            });
+            document.getElementById('sensors-table-body').replaceChildren(...rows);
+
+            const badge = document.getElementById('sensors-status');
+            badge.textContent = data.sensors.aboveHigh > 0 ? data.sensors.aboveHigh + ' above high' : 'OK';
+            badge.style.background = data.sensors.aboveHigh > 0 ? '#b91c1c' : '#059669';
+        } else if (data.sensors) {
+            sensorsSection.style.display = 'none';
+        }
+
         // Update Memory, Swap & Details
         if (data.memory) {
-            if (data.memory.Vmemory) {
-                const vmem = data.memory.Vmemory;
-                document.getElementById('mem-total-percent').textContent = vmem.UsedPercentage.toFixed(1) + '%';
+            if (data.memory.vmemory) {
+                const vmem = data.memory.vmemory;
+                document.getElementById('mem-total-percent').textContent = vmem.usedPercentage.toFixed(1) + '%';
 
-                const used = (vmem.Used / 1024 / 1024 / 1024).toFixed(2);
-                const free = (vmem.Free / 1024 / 1024 / 1024).toFixed(2);
-                const other = ((vmem.Total - vmem.Used - vmem.Free) / 1024 / 1024 / 1024).toFixed(2);
+                const used = (vmem.used / 1024 / 1024 / 1024).toFixed(2);
+                const free = (vmem.free / 1024 / 1024 / 1024).toFixed(2);
+                const other = ((vmem.total - vmem.used - vmem.free) / 1024 / 1024 / 1024).toFixed(2);
 
                 memoryChart.data.datasets[0].data = [used, free, other];
                 memoryChart.update();
             }
 
-            const swapUsed = (data.memory.SwapMemoryUsed / 1024 / 1024 / 1024).toFixed(2);
-            const swapFree = (data.memory.SwapMemoryFree / 1024 / 1024 / 1024).toFixed(2);
-            document.getElementById('swap-total-percent').textContent = data.memory.SwapMemoryUsedPercent.toFixed(1) + '%';
+            const swapUsed = (data.memory.swapMemoryUsed / 1024 / 1024 / 1024).toFixed(2);
+            const swapFree = (data.memory.swapMemoryFree / 1024 / 1024 / 1024).toFixed(2);
+            document.getElementById('swap-total-percent').textContent = data.memory.swapMemoryUsedPercent.toFixed(1) + '%';
             swapChart.data.datasets[0].data = [swapUsed, swapFree];
             swapChart.update();
 
-            if (data.memory.ProcessInfo && data.memory.ProcessInfo.length > 0) {
-                const sortedProcs = [...data.memory.ProcessInfo].sort((a, b) => b.MemPercent - a.MemPercent).slice(0, 5);
-                topMemChart.data.labels = sortedProcs.map(p => p.ProcessName || p.Pid);
-                topMemChart.data.datasets[0].data = sortedProcs.map(p => p.MemPercent);
+            if (data.memory.processInfo && data.memory.processInfo.length > 0) {
+                const sortedProcs = [...data.memory.processInfo].sort((a, b) => b.memPercent - a.memPercent).slice(0, 5);
+                topMemChart.data.labels = sortedProcs.map(p => p.processName || p.pid);
+                topMemChart.data.datasets[0].data = sortedProcs.map(p => p.memPercent);
                 topMemChart.update();
 
                 // Update Memory Deep Dive Charts
-                memVmsRssChart.data.labels = sortedProcs.map(p => p.ProcessName || p.Pid);
-                memVmsRssChart.data.datasets[0].data = sortedProcs.map(p => (p.VirtualMemorySize / 1024 / 1024).toFixed(2));
-                memVmsRssChart.data.datasets[1].data = sortedProcs.map(p => (p.PhysicalMemorySize / 1024 / 1024).toFixed(2));
+                memVmsRssChart.data.labels = sortedProcs.map(p => p.processName || p.pid);
+                memVmsRssChart.data.datasets[0].data = sortedProcs.map(p => (p.virtualMemorySize / 1024 / 1024).toFixed(2));
+                memVmsRssChart.data.datasets[1].data = sortedProcs.map(p => (p.physicalMemorySize / 1024 / 1024).toFixed(2));
                 memVmsRssChart.update();
 
-                memHeapStackChart.data.labels = sortedProcs.map(p => p.ProcessName || p.Pid);
-                memHeapStackChart.data.datasets[0].data = sortedProcs.map(p => (p.MemoryUsedByHeap / 1024 / 1024).toFixed(2));
-                memHeapStackChart.data.datasets[1].data = sortedProcs.map(p => (p.MemoryUsedByStack / 1024 / 1024).toFixed(2));
+                memHeapStackChart.data.labels = sortedProcs.map(p => p.processName || p.pid);
+                memHeapStackChart.data.datasets[0].data = sortedProcs.map(p => (p.memoryUsedByHeap / 1024 / 1024).toFixed(2));
+                memHeapStackChart.data.datasets[1].data = sortedProcs.map(p => (p.memoryUsedByStack / 1024 / 1024).toFixed(2));
                 memHeapStackChart.update();
             }
         }
 
         // Update Disk
-        if (data.disk && data.disk.UsageStat) {
+        if (data.disk && data.disk.usageStat) {
             const paths = [];
             const used = [];
             const free = [];
-            for (const [path, stat] of Object.entries(data.disk.UsageStat)) {
+            for (const [path, stat] of Object.entries(data.disk.usageStat)) {
                 paths.push(path);
-                used.push((stat.UsedDisk / 1024 / 1024 / 1024).toFixed(2));
-                free.push((stat.FreeDisk / 1024 / 1024 / 1024).toFixed(2));
+                used.push((stat.usedDisk / 1024 / 1024 / 1024).toFixed(2));
+                free.push((stat.freeDisk / 1024 / 1024 / 1024).toFixed(2));
             }
             diskChart.data.labels = paths;
             diskChart.data.datasets[0].data = used;
@@ -396,13 +489,21 @@ async function updateData() {
         // Update Network & Tables
         if (data.network) {
             // IO Stats Table
-            if (data.network.IOStats) {
+            if (data.network.ioStats) {
+                // rates are only available from the second collection onwards
+                const rates = (data.rates && data.rates.network) || {};
+                const rate = (name, counter) => {
+                    const r = rates[name + '/' + counter];
+                    return r === undefined ? '-' : formatBytes(Math.round(r)) + '/s';
+                };
                 let html = '';
-                data.network.IOStats.forEach(stat => {
+                data.network.ioStats.forEach(stat => {
                     html += '<tr>' +
                         '<td><span class="badge">' + (stat.name || 'unknown') + '</span></td>' +
                         '<td class="text-right">' + formatBytes(stat.bytesSent || 0) + '</td>' +
                         '<td class="text-right">' + formatBytes(stat.bytesRecv || 0) + '</td>' +
+                        '<td class="text-right">' + rate(stat.name, 'bytesSent') + '</td>' +
+                        '<td class="text-right">' + rate(stat.name, 'bytesRecv') + '</td>' +
                         '<td class="text-right">' + (stat.packetsSent || 0).toLocaleString() + '</td>' +
                         '<td class="text-right">' + (stat.packetsRecv || 0).toLocaleString() + '</td>' +
                         '<td class="text-right">' + (stat.errin || 0).toLocaleString() + '</td>' +
@@ -413,24 +514,24 @@ async function updateData() {
             }
 
             // Connections
-            const totalConn = data.network.NumTotalConnections || 0;
-            const estConn = data.network.NumEstablishedConnections || 0;
+            const totalConn = data.network.numTotalConnections || 0;
+            const estConn = data.network.numEstablishedConnections || 0;
             const otherConn = totalConn - estConn;
             netConnChart.data.datasets[0].data = [estConn, otherConn];
             netConnChart.update();
             document.getElementById('total-conn-count').innerText = totalConn;
 
             // Connections Table
-            if (data.network.Connections) {
+            if (data.network.connections) {
                 let connHtml = '';
                 // Limit to top 50 for performance
-                data.network.Connections.slice(0, 50).forEach(c => {
-                    if (!c.LocalAddr || !c.RemoteAddr) return;
+                data.network.connections.slice(0, 50).forEach(c => {
+                    if (!c.localAddr || !c.remoteAddr) return;
                     connHtml += '<tr>' +
-                        '<td>' + c.LocalAddr.ip + ':' + c.LocalAddr.port + '</td>' +
-                        '<td>' + c.RemoteAddr.ip + ':' + c.RemoteAddr.port + '</td>' +
-                        '<td>' + c.Pid + '</td>' +
-                        '<td><span class="badge">' + (c.Status || 'UNKNOWN') + '</span></td>' +
+                        '<td>' + c.localAddr.ip + ':' + c.localAddr.port + '</td>' +
+                        '<td>' + c.remoteAddr.ip + ':' + c.remoteAddr.port + '</td>' +
+                        '<td>' + c.pid + '</td>' +
+                        '<td><span class="badge">' + (c.status || 'UNKNOWN') + '</span></td>' +
                         '</tr>';
                 });
                 document.getElementById('conn-table-body').innerHTML = connHtml || '<tr><td colspan="4">No visible established connections</td></tr>';
@@ -443,80 +544,80 @@ async function updateData() {
             dockerSection.style.display = 'block';
             const d = data.docker;
 
-            document.getElementById('docker-version').textContent = 'v' + d.APIVersion;
-            document.getElementById('docker-platform').textContent = d.OS + ' / ' + d.Arch;
-            document.getElementById('docker-images').textContent = d.TotalImages;
-            document.getElementById('docker-volumes').textContent = d.TotalVolumes;
-            document.getElementById('docker-ncpu').textContent = d.NCpu || '0';
-            document.getElementById('docker-mem-total').textContent = d.MemTotal ? (d.MemTotal / 1024 / 1024 / 1024).toFixed(2) + ' GB' : '0 GB';
+            document.getElementById('docker-version').textContent = 'v' + d.apiVersion;
+            document.getElementById('docker-platform').textContent = d.os + ' / ' + d.arch;
+            document.getElementById('docker-images').textContent = d.totalImages;
+            document.getElementById('docker-volumes').textContent = d.totalVolumes;
+            document.getElementById('docker-ncpu').textContent = d.nCpu || '0';
+            document.getElementById('docker-mem-total').textContent = d.memTotal ? (d.memTotal / 1024 / 1024 / 1024).toFixed(2) + ' GB' : '0 GB';
 
-            dockerContainerChart.data.datasets[0].data = [d.ContainersRunning, d.ContainersPaused, d.ContainersStopped];
+            dockerContainerChart.data.datasets[0].data = [d.containersRunning, d.containersPaused, d.containersStopped];
             dockerContainerChart.update();
 
             dockerDiskChart.data.datasets[0].data = [
-                (d.ContainersDiskUsage / 1024 / 1024 / 1024).toFixed(2),
-                (d.ImagesDiskUsage / 1024 / 1024 / 1024).toFixed(2),
-                (d.BuildCacheDiskUsage / 1024 / 1024 / 1024).toFixed(2)
+                (d.containersDiskUsage / 1024 / 1024 / 1024).toFixed(2),
+                (d.imagesDiskUsage / 1024 / 1024 / 1024).toFixed(2),
+                (d.buildCacheDiskUsage / 1024 / 1024 / 1024).toFixed(2)
             ];
             dockerDiskChart.update();
 
-            if (d.ContainerStats) {
+            if (d.containerStats) {
                 let html = '';
-                d.ContainerStats.forEach(c => {
+                d.containerStats.forEach(c => {
                     let ports = '';
-                    if (c.ContainerPorts) {
-                        ports = c.ContainerPorts.map(p => (p.PublicPort ? p.PublicPort + ':' : '') + p.PrivatePort + '/' + p.Type).join(', ');
+                    if (c.containerPorts) {
+                        ports = c.containerPorts.map(p => (p.PublicPort ? p.PublicPort + ':' : '') + p.PrivatePort + '/' + p.Type).join(', ');
                     }
 
                     html += '<tr>' +
-                        '<td>' + (c.ContainerNames ? c.ContainerNames.join(', ') : 'unknown') + '</td>' +
-                        '<td>' + c.ImageName + '</td>' +
-                        '<td><span class="badge" style="background:' + (c.ContainerState === 'running' ? '#059669' : '#b91c1c') + '">' + c.ContainerState + '</span></td>' +
-                        '<td>' + formatBytes(c.ContainerRootSizeInBytes || 0) + '</td>' +
+                        '<td>' + (c.containerNames ? c.containerNames.join(', ') : 'unknown') + '</td>' +
+                        '<td>' + c.imageName + '</td>' +
+                        '<td><span class="badge" style="background:' + (c.containerState === 'running' ? '#059669' : '#b91c1c') + '">' + c.containerState + '</span></td>' +
+                        '<td>' + formatBytes(c.containerRootSizeInBytes || 0) + '</td>' +
                         '<td>' + ports + '</td>' +
                         '</tr>';
                 });
                 document.getElementById('docker-container-body').innerHTML = html;
             }
 
-            if (d.ImageStats) {
+            if (d.imageStats) {
                 let html = '';
-                d.ImageStats.forEach(img => {
+                d.imageStats.forEach(img => {
                     html += '<tr>' +
-                        '<td>' + (img.ImageNames ? img.ImageNames.join('<br>') : 'unnamed') + '</td>' +
-                        '<td title="' + img.ID + '"><code>' + img.ID.substring(7, 19) + '</code></td>' +
-                        '<td>' + formatBytes(img.ImageSize || 0) + '</td>' +
-                        '<td>' + img.CreatedDate + '</td>' +
-                        '<td>' + (img.NumberOfContainersUsingThisImage === -1 ? 'unknown' : img.NumberOfContainersUsingThisImage) + '</td>' +
+                        '<td>' + (img.imageNames ? img.imageNames.join('<br>') : 'unnamed') + '</td>' +
+                        '<td title="' + img.id + '"><code>' + img.id.substring(7, 19) + '</code></td>' +
+                        '<td>' + formatBytes(img.imageSize || 0) + '</td>' +
+                        '<td>' + img.createdDate + '</td>' +
+                        '<td>' + (img.numberOfContainersUsingThisImage === -1 ? 'unknown' : img.numberOfContainersUsingThisImage) + '</td>' +
                         '</tr>';
                 });
                 document.getElementById('docker-images-body').innerHTML = html || '<tr><td colspan="5">No images found</td></tr>';
             }
 
-            if (d.VolumeStats) {
+            if (d.volumeStats) {
                 let html = '';
-                d.VolumeStats.forEach(vol => {
+                d.volumeStats.forEach(vol => {
                     html += '<tr>' +
-                        '<td>' + vol.VolumeName + '</td>' +
-                        '<td>' + vol.Driver + '</td>' +
-                        '<td>' + vol.Scope + '</td>' +
-                        '<td style="font-size: 0.8em; word-break: break-all;">' + vol.MountPoint + '</td>' +
-                        '<td>' + formatBytes(vol.VolumeSize || 0) + '</td>' +
+                        '<td>' + vol.volumeName + '</td>' +
+                        '<td>' + vol.driver + '</td>' +
+                        '<td>' + vol.scope + '</td>' +
+                        '<td style="font-size: 0.8em; word-break: break-all;">' + vol.mountPoint + '</td>' +
+                        '<td>' + formatBytes(vol.volumeSize || 0) + '</td>' +
                         '</tr>';
                 });
                 document.getElementById('docker-volumes-body').innerHTML = html || '<tr><td colspan="5">No volumes found</td></tr>';
             }
 
-            if (d.ContainerCpuMemoryCollection) {
+            if (d.containerCpuMemoryCollection) {
                 let html = '';
-                for (const [id, stats] of Object.entries(d.ContainerCpuMemoryCollection)) {
+                for (const [id, stats] of Object.entries(d.containerCpuMemoryCollection)) {
                     html += '<tr>' +
-                        '<td>' + (stats.Name || id.substring(0, 12)) + '</td>' +
-                        '<td class="text-right">' + (stats.CPUPercentage || 0).toFixed(2) + '%</td>' +
-                        '<td class="text-right">' + (stats.CPUTime || 0).toLocaleString() + '</td>' +
-                        '<td class="text-right">' + formatBytes(stats.Memory || 0) + '</td>' +
-                        '<td class="text-right">' + formatBytes(stats.ReadSize || 0) + '</td>' +
-                        '<td class="text-right">' + formatBytes(stats.WriteSize || 0) + '</td>' +
+                        '<td>' + (stats.name || id.substring(0, 12)) + '</td>' +
+                        '<td class="text-right">' + (stats.cpuPercentage || 0).toFixed(2) + '%</td>' +
+                        '<td class="text-right">' + (stats.cpuTime || 0).toLocaleString() + '</td>' +
+                        '<td class="text-right">' + formatBytes(stats.memory || 0) + '</td>' +
+                        '<td class="text-right">' + formatBytes(stats.readSize || 0) + '</td>' +
+                        '<td class="text-right">' + formatBytes(stats.writeSize || 0) + '</td>' +
                         '</tr>';
                 }
                 document.getElementById('docker-utilization-body').innerHTML = html || '<tr><td colspan="5">No utilization data available</td></tr>';
@@ -533,46 +634,46 @@ async function updateData() {
             const k = data.kubernetes;
 
             // Nodes
-            if (k.NodeStats) {
+            if (k.nodeStats) {
                 let html = '';
-                k.NodeStats.forEach(n => {
-                    const addresses = n.Addressed ? n.Addressed.map(a => `<span class="badge">${a.Type}: ${a.Address}</span>`).join(' ') : 'none';
+                k.nodeStats.forEach(n => {
+                    const addresses = n.addressed ? n.addressed.map(a => `<span class="badge">${a.type}: ${a.address}</span>`).join(' ') : 'none';
                     html += `<tr>
-                        <td>${n.Name}</td>
-                        <td><span class="badge" style="background:${n.Unschedulable ? '#b91c1c' : '#059669'}">${n.Unschedulable ? 'Unschedulable' : 'Ready'}</span></td>
+                        <td>${n.name}</td>
+                        <td><span class="badge" style="background:${n.unschedulable ? '#b91c1c' : '#059669'}">${n.unschedulable ? 'Unschedulable' : 'Ready'}</span></td>
                         <td>${addresses}</td>
-                        <td>${formatAge(n.CreationTimestamp)}</td>
+                        <td>${formatAge(n.creationTimestamp)}</td>
                     </tr>`;
                 });
                 document.getElementById('k8s-nodes-body').innerHTML = html || '<tr><td colspan="4">No nodes found</td></tr>';
             }
 
             // Pods
-            if (k.PodStats) {
+            if (k.podStats) {
                 let html = '';
-                k.PodStats.forEach(p => {
+                k.podStats.forEach(p => {
                     html += `<tr>
-                        <td><span class="badge">${p.Namespace}</span></td>
-                        <td>${p.Name}</td>
-                        <td><span class="badge" style="background:${getPodPhaseColor(p.Phase)}">${p.Phase}</span></td>
-                        <td>${p.PodIP || 'N/A'}</td>
-                        <td>${p.NodeName || 'N/A'}</td>
-                        <td>${formatAge(p.CreationTimestamp)}</td>
+                        <td><span class="badge">${p.namespace}</span></td>
+                        <td>${p.name}</td>
+                        <td><span class="badge" style="background:${getPodPhaseColor(p.phase)}">${p.phase}</span></td>
+                        <td>${p.podIP || 'N/A'}</td>
+                        <td>${p.nodeName || 'N/A'}</td>
+                        <td>${formatAge(p.creationTimestamp)}</td>
                     </tr>`;
                 });
                 document.getElementById('k8s-pods-body').innerHTML = html || '<tr><td colspan="6">No pods found</td></tr>';
             }
 
             // Services
-            if (k.ServiceStats) {
+            if (k.serviceStats) {
                 let html = '';
-                k.ServiceStats.forEach(s => {
-                    const ports = s.Port ? `${s.Port}/${s.Protocol}${s.NodePort ? ` (NodePort: ${s.NodePort})` : ''}` : 'N/A';
+                k.serviceStats.forEach(s => {
+                    const ports = s.port ? `${s.port}/${s.protocol}${s.nodePort ? ` (NodePort: ${s.nodePort})` : ''}` : 'N/A';
                     html += `<tr>
-                        <td><span class="badge">${s.Namespace}</span></td>
-                        <td>${s.Name}</td>
-                        <td>${s.Type}</td>
-                        <td>${s.ClusterIp || 'N/A'}</td>
+                        <td><span class="badge">${s.namespace}</span></td>
+                        <td>${s.name}</td>
+                        <td>${s.type}</td>
+                        <td>${s.clusterIp || 'N/A'}</td>
                         <td>${ports}</td>
                     </tr>`;
                 });
@@ -580,46 +681,46 @@ async function updateData() {
             }
 
             // Deployments
-            if (k.DeploymentStats) {
+            if (k.deploymentStats) {
                 let html = '';
-                k.DeploymentStats.forEach(d => {
+                k.deploymentStats.forEach(d => {
                     html += `<tr>
-                        <td><span class="badge">${d.Namespace}</span></td>
-                        <td>${d.Name}</td>
-                        <td>${d.UpdatedReplicas} / ${d.TotalReplicas}</td>
+                        <td><span class="badge">${d.namespace}</span></td>
+                        <td>${d.name}</td>
+                        <td>${d.updatedReplicas} / ${d.totalReplicas}</td>
                     </tr>`;
                 });
                 document.getElementById('k8s-deployments-body').innerHTML = html || '<tr><td colspan="3">No deployments found</td></tr>';
             }
 
             // Persistent Volumes
-            if (k.PersistentVolumeStats) {
+            if (k.persistentVolumeStats) {
                 let html = '';
-                k.PersistentVolumeStats.forEach(pv => {
-                    const capacity = pv.Capacity ? Object.entries(pv.Capacity).map(([k, v]) => `${v.string}`).join(', ') : 'N/A';
+                k.persistentVolumeStats.forEach(pv => {
+                    const capacity = pv.capacity ? Object.entries(pv.capacity).map(([k, v]) => `${v.string}`).join(', ') : 'N/A';
                     html += `<tr>
-                        <td>${pv.Name}</td>
+                        <td>${pv.name}</td>
                         <td>${capacity}</td>
-                        <td>${pv.AccessModes ? pv.AccessModes.join(', ') : 'N/A'}</td>
-                        <td>${pv.ReclaimPolicy}</td>
-                        <td><span class="badge" style="background:${getPVStatusColor(pv.Status)}">${pv.Status}</span></td>
+                        <td>${pv.accessModes ? pv.accessModes.join(', ') : 'N/A'}</td>
+                        <td>${pv.reclaimPolicy}</td>
+                        <td><span class="badge" style="background:${getPVStatusColor(pv.status)}">${pv.status}</span></td>
                         <td>${pv.ClaimRef ? `${pv.ClaimRef.namespace}/${pv.ClaimRef.name}` : 'N/A'}</td>
-                        <td>${pv.StorageClassName || 'N/A'}</td>
+                        <td>${pv.storageClassName || 'N/A'}</td>
                     </tr>`;
                 });
                 document.getElementById('k8s-pvs-body').innerHTML = html || '<tr><td colspan="7">No PVs found</td></tr>';
             }
 
             // Persistent Volume Claims
-            if (k.PersistentVolumeClaimStats) {
+            if (k.persistentVolumeClaimStats) {
                 let html = '';
-                k.PersistentVolumeClaimStats.forEach(pvc => {
-                    const capacity = pvc.Capacity ? Object.entries(pvc.Capacity).map(([k, v]) => `${v.string}`).join(', ') : 'N/A';
+                k.persistentVolumeClaimStats.forEach(pvc => {
+                    const capacity = pvc.capacity ? Object.entries(pvc.capacity).map(([k, v]) => `${v.string}`).join(', ') : 'N/A';
                     html += `<tr>
-                        <td><span class="badge">${pvc.Namespace}</span></td>
-                        <td>${pvc.Name}</td>
-                        <td><span class="badge" style="background:${getPVStatusColor(pvc.Status)}">${pvc.Status}</span></td>
-                        <td>${pvc.VolumeName || 'N/A'}</td>
+                        <td><span class="badge">${pvc.namespace}</span></td>
+                        <td>${pvc.name}</td>
+                        <td><span class="badge" style="background:${getPVStatusColor(pvc.status)}">${pvc.status}</span></td>
+                        <td>${pvc.volumeName || 'N/A'}</td>
                         <td>${capacity}</td>
                     </tr>`;
                 });
@@ -631,10 +732,90 @@ async function updateData() {
         }
 
     } catch (err) {
-        console.error("Error fetching metrics:", err);
+        console.error("Error rendering metrics:", err);
     }
 }
 
+async function updateHistory() {
+    try {
+        const [cpuRes, memRes] = await Promise.all([
+            fetch('/api/history?metric=cpu&since=1h&step=1m'),
+            fetch('/api/history?metric=memory&since=1h&step=1m')
+        ]);
+        const cpuHistory = await cpuRes.json();
+        const memHistory = await memRes.json();
+
+        historyChart.data.datasets[0].data = cpuHistory.points.map(p => ({
+            x: new Date(p.timestamp).getTime(),
+            y: p.value.averagePercentages.toFixed(2)
+        }));
+        historyChart.data.datasets[1].data = memHistory.points.map(p => ({
+            x: new Date(p.timestamp).getTime(),
+            y: p.value.vmemory.usedPercentage.toFixed(2)
+        }));
+        historyChart.update();
+    } catch (err) {
+        console.error("Error fetching history:", err);
+    }
+}
+
+function formatTime(ts) {
+    return new Date(ts).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+}
+
+function hasRun(timestamp) {
+    return timestamp && new Date(timestamp).getFullYear() > 1;
+}
+
+async function updateStatus() {
+    try {
+        const response = await fetch('/api/status');
+        renderStatus(await response.json());
+    } catch (err) {
+        console.error("Error fetching collector status:", err);
+    }
+}
+
+function renderStatus(statuses) {
+    const byName = {};
+    statuses.forEach(s => { byName[s.name] = s; });
+
+    document.querySelectorAll('.collector-status').forEach(el => {
+        const relevant = el.dataset.collectors.split(',')
+            .map(name => byName[name])
+            .filter(s => s && s.enabled);
+        if (relevant.length === 0) {
+            el.style.display = 'none';
+            return;
+        }
+
+        const failing = relevant.filter(s => hasRun(s.lastAttempt) && !s.healthy);
+        const pending = relevant.filter(s => !hasRun(s.lastAttempt));
+
+        el.style.display = 'inline-block';
+        el.className = 'collector-status';
+        if (failing.length > 0) {
+            const timedOut = failing.every(s => s.timedOut);
+            el.classList.add(timedOut ? 'timed-out' : 'failing');
+            el.textContent = failing.map(s => s.name).join(', ') + (timedOut ? ' timed out' : ' failing');
+            el.title = failing.map(s =>
+                s.name + ': ' + s.consecutiveFailures + ' consecutive failure(s), last success ' +
+                (hasRun(s.lastSuccess) ? new Date(s.lastSuccess).toLocaleString() : 'never') +
+                '\n' + (s.lastError || '')
+            ).join('\n\n');
+        } else if (pending.length > 0) {
+            el.classList.add('pending');
+            el.textContent = 'Pending';
+            el.title = 'Waiting for first collection of ' + pending.map(s => s.name).join(', ');
+        } else {
+            el.textContent = 'OK';
+            el.title = relevant.map(s =>
+                s.name + ': collected ' + new Date(s.lastSuccess).toLocaleTimeString() + ' in ' + s.durationMs.toFixed(0) + 'ms'
+            ).join('\n');
+        }
+    });
+}
+
 function formatAge(timestamp) {
     if (!timestamp) return 'N/A';
     const start = new Date(timestamp);
@@ -661,6 +842,15 @@ function getPodPhaseColor(phase) {
     }
 }
 
+function getSensorStatusColor(status) {
+    switch (status) {
+        case 'OK': return '#059669';
+        case 'High': return '#f59e0b';
+        case 'Critical': return '#b91c1c';
+        default: return '#6b7280';
+    }
+}
+
 function getPVStatusColor(status) {
     switch (status) {
         case 'Bound': return '#059669';
@@ -708,6 +898,35 @@ document.getElementById('export-img').addEventListener('click', (e) => {
     });
 });
 
+function refresh() {
+    updateData();
+    updateStatus();
+}
+
+// the history chart shows an hour at one point a minute, so it is refreshed
+// on its own timer rather than on every pushed update
+const historyInterval = 60000;
+
+// connect subscribes to pushed updates, falling back to polling in browsers
+// without EventSource. EventSource reconnects on its own and the server
+// starts every connection with a full snapshot.
+function connect() {
+    if (!window.EventSource) {
+        refresh();
+        setInterval(refresh, 5000);
+        return;
+    }
+
+    const source = new EventSource('/api/stream');
+    source.addEventListener('snapshot', e => {
+        latest = JSON.parse(e.data);
+        render(latest);
+    });
+    source.addEventListener('update', e => applyUpdate(JSON.parse(e.data)));
+    source.addEventListener('status', e => renderStatus(JSON.parse(e.data)));
+}
+
 initCharts();
-updateData();
-setInterval(updateData, 5000);
+connect();
+updateHistory();
+setInterval(updateHistory, historyInterval);
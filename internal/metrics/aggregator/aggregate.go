@@ -1,276 +1,453 @@
 package aggregator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/cgroup"
 	"github.com/techtacles/sysmonitoring/internal/metrics/cpu"
 	"github.com/techtacles/sysmonitoring/internal/metrics/disk"
 	"github.com/techtacles/sysmonitoring/internal/metrics/docker"
 	"github.com/techtacles/sysmonitoring/internal/metrics/host"
+	"github.com/techtacles/sysmonitoring/internal/metrics/kernel"
 	"github.com/techtacles/sysmonitoring/internal/metrics/kubernetes"
 	"github.com/techtacles/sysmonitoring/internal/metrics/memory"
 	"github.com/techtacles/sysmonitoring/internal/metrics/network"
+	"github.com/techtacles/sysmonitoring/internal/metrics/pressure"
+	"github.com/techtacles/sysmonitoring/internal/metrics/procgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/script"
+	"github.com/techtacles/sysmonitoring/internal/metrics/sensors"
 	"github.com/techtacles/sysmonitoring/internal/metrics/user"
+	"github.com/techtacles/sysmonitoring/internal/storage"
 )
 
 const logtag = "aggregator"
 
+// DefaultTimeout bounds a single collector run unless overridden
+const DefaultTimeout = 20 * time.Second
+
+var (
+	// ErrTimeout is returned when a collector does not finish within its timeout
+	ErrTimeout = errors.New("collector timed out")
+	// ErrBusy is returned when a collector abandoned after a timeout is still running
+	ErrBusy = errors.New("previous run of collector still in progress")
+	// ErrPanic is returned when a collector panics. The stack trace is logged.
+	ErrPanic = errors.New("collector panicked")
+)
+
 type Aggregator struct {
 	mu               sync.RWMutex
 	allMetrics       map[string]interface{}
+	registry         *Registry
+	history          *History
+	store            *storage.Store
+	timeout          time.Duration
+	timeouts         map[string]time.Duration
+	interval         time.Duration
+	intervals        map[string]time.Duration
+	running          map[string]bool
+	status           map[string]*Status
+	collectedAt      map[string]time.Time
+	counters         map[string]counterReading
+	rates            map[string]map[string]float64
+	subMu            sync.Mutex
+	subscribers      map[chan Event]struct{}
+	identity         Identity
+	identityOnce     sync.Once
 	enableDocker     bool
 	enableKubernetes bool
 	kubeconfigPath   string
 }
 
 func NewAggregator(enableDocker, enableKubernetes bool, kubeconfigPath string) *Aggregator {
-	return &Aggregator{
+	a := &Aggregator{
 		allMetrics:       make(map[string]interface{}),
+		registry:         NewRegistry(),
+		history:          NewHistory(DefaultHistoryRetention),
+		timeout:          DefaultTimeout,
+		timeouts:         make(map[string]time.Duration),
+		interval:         DefaultInterval,
+		intervals:        make(map[string]time.Duration),
+		running:          make(map[string]bool),
+		status:           make(map[string]*Status),
+		collectedAt:      make(map[string]time.Time),
+		counters:         make(map[string]counterReading),
+		rates:            make(map[string]map[string]float64),
+		subscribers:      make(map[chan Event]struct{}),
 		enableDocker:     enableDocker,
 		enableKubernetes: enableKubernetes,
 		kubeconfigPath:   kubeconfigPath,
 	}
+	a.registerBuiltins()
+	return a
 }
 
-// CollectAll collects all metrics sequentially and returns any errors encountered
-func (a *Aggregator) CollectAll() map[string]error {
-	errors := make(map[string]error)
-
-	if err := a.CollectCPU(); err != nil {
-		errors["cpu"] = err
-	}
-	if err := a.CollectMemory(); err != nil {
-		errors["memory"] = err
-	}
-	if err := a.CollectDisk(); err != nil {
-		errors["disk"] = err
+// registerBuiltins registers the collectors shipped with sysmon
+func (a *Aggregator) registerBuiltins() {
+	builtins := []Collector{
+		NewCollector("cpu", nil, func(ctx context.Context) (interface{}, error) {
+			c := &cpu.CpuInfo{}
+			err := c.Collect(ctx)
+			return c, err
+		}),
+		NewCollector("sensors", sensors.Available, func(ctx context.Context) (interface{}, error) {
+			s := &sensors.SensorsInfo{}
+			err := s.Collect(ctx)
+			return s, err
+		}),
+		NewCollector("memory", nil, func(ctx context.Context) (interface{}, error) {
+			m := &memory.MemoryInfo{}
+			err := m.Collect(ctx)
+			return m, err
+		}),
+		NewCollector("disk", nil, func(ctx context.Context) (interface{}, error) {
+			d := &disk.DiskInfo{}
+			err := d.Collect(ctx)
+			return d, err
+		}),
+		NewCollector("network", nil, func(ctx context.Context) (interface{}, error) {
+			n := &network.NetworkInfo{}
+			err := n.Collect(ctx)
+			return n, err
+		}),
+		NewCollector("user", nil, func(ctx context.Context) (interface{}, error) {
+			u := &user.UserInfo{}
+			err := u.Collect(ctx)
+			return u, err
+		}),
+		NewCollector("host", nil, func(ctx context.Context) (interface{}, error) {
+			h := &host.HostInfo{}
+			err := h.Collect(ctx)
+			return h, err
+		}),
+		NewCollector("pressure", pressure.Available, func(ctx context.Context) (interface{}, error) {
+			p := &pressure.PressureInfo{}
+			err := p.Collect(ctx)
+			return p, err
+		}),
+		NewCollector("kernel", kernel.Available, func(ctx context.Context) (interface{}, error) {
+			k := &kernel.KernelInfo{}
+			err := k.Collect(ctx)
+			return k, err
+		}),
+		NewCollector("docker", func() bool { return a.enableDocker }, func(ctx context.Context) (interface{}, error) {
+			d := &docker.DockerInfo{}
+			err := d.Collect(ctx)
+			return d, err
+		}),
+		NewCollector("kubernetes", func() bool { return a.enableKubernetes }, func(ctx context.Context) (interface{}, error) {
+			kubernetes.ExplicitKubeconfigPath = a.kubeconfigPath
+			k := &kubernetes.KubeInfo{}
+			err := k.Collect(ctx)
+			return k, err
+		}),
 	}
-	if err := a.CollectNetwork(); err != nil {
-		errors["network"] = err
-	}
-	if err := a.CollectUser(); err != nil {
-		errors["user"] = err
-	}
-	if err := a.CollectHost(); err != nil {
-		errors["host"] = err
-	}
-	if a.enableDocker {
-		if err := a.CollectDocker(); err != nil {
-			errors["docker"] = err
-		}
-	}
-	if a.enableKubernetes {
-		if err := a.CollectKubernetes(); err != nil {
-			errors["kubernetes"] = err
+
+	for _, c := range builtins {
+		if err := a.registry.Register(c); err != nil {
+			logging.Error(logtag, "error registering builtin collector", err)
 		}
 	}
 
-	if len(errors) > 0 {
-		return errors
-	}
-	return nil
+	// listing every object cluster-wide is expensive
+	a.intervals["kubernetes"] = 2 * time.Minute
 }
 
-// CollectAllConcurrent collects all metrics concurrently for better performance
-func (a *Aggregator) CollectAllConcurrent() map[string]error {
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	errors := make(map[string]error)
+// Register adds a collector to the aggregator so it is picked up by
+// CollectAll, the get_metrics command and the dashboard
+func (a *Aggregator) Register(c Collector) error {
+	return a.registry.Register(c)
+}
 
-	collectors := []struct {
-		name string
-		fn   func() error
-	}{
-		{"cpu", a.CollectCPU},
-		{"memory", a.CollectMemory},
-		{"disk", a.CollectDisk},
-		{"network", a.CollectNetwork},
-		{"user", a.CollectUser},
-		{"host", a.CollectHost},
+// RegisterScript registers an external check as a collector. Its result is
+// published under the check name, it runs on the check interval and each run
+// is bounded by the check timeout.
+func (a *Aggregator) RegisterScript(check script.Check) error {
+	c := NewCollector(check.Name, nil, func(ctx context.Context) (interface{}, error) {
+		return check.Run(ctx)
+	})
+	if err := a.registry.Register(c); err != nil {
+		return err
 	}
 
-	if a.enableDocker {
-		collectors = append(collectors, struct {
-			name string
-			fn   func() error
-		}{"docker", a.CollectDocker})
+	if check.Timeout > 0 {
+		a.SetCollectorTimeout(check.Name, check.Timeout)
 	}
-
-	if a.enableKubernetes {
-		collectors = append(collectors, struct {
-			name string
-			fn   func() error
-		}{"kubernetes", a.CollectKubernetes})
+	if check.Interval > 0 {
+		a.SetCollectorInterval(check.Name, check.Interval)
 	}
+	return nil
+}
 
-	for _, collector := range collectors {
-		wg.Add(1)
-		go func(name string, fn func() error) {
-			defer wg.Done()
-			if err := fn(); err != nil {
-				mu.Lock()
-				errors[name] = err
-				mu.Unlock()
-			}
-		}(collector.name, collector.fn)
+// RegisterProcessGroups registers a collector watching the given process
+// groups under procgroup.CollectorName. Nothing is registered without groups.
+func (a *Aggregator) RegisterProcessGroups(groups []procgroup.Group) error {
+	if len(groups) == 0 {
+		return nil
 	}
+	watcher := procgroup.NewWatcher(groups)
+	return a.registry.Register(NewCollector(procgroup.CollectorName, nil, func(ctx context.Context) (interface{}, error) {
+		return watcher.Collect(ctx)
+	}))
+}
 
-	wg.Wait()
+// RegisterCgroups registers a collector for sysmon's own cgroup and the
+// given cgroup paths under cgroup.CollectorName. It is only enabled where
+// cgroups exist, ie on Linux.
+func (a *Aggregator) RegisterCgroups(paths []string) error {
+	return a.registry.Register(NewCollector(cgroup.CollectorName, cgroup.Available, func(ctx context.Context) (interface{}, error) {
+		return cgroup.Collect(ctx, paths)
+	}))
+}
 
-	if len(errors) > 0 {
-		return errors
-	}
-	return nil
+// Collectors returns every registered collector in registration order
+func (a *Aggregator) Collectors() []Collector {
+	return a.registry.All()
 }
 
-func (a *Aggregator) CollectCPU() error {
-	logging.Info(logtag, "collecting cpu metrics")
+// CollectorNames returns the names of every registered collector
+func (a *Aggregator) CollectorNames() []string {
+	return a.registry.Names()
+}
 
-	c := cpu.CpuInfo{}
-	if err := c.Collect(); err != nil {
-		logging.Error(logtag, "error collecting cpu metrics", err)
-		return err
+// enabledCollectors returns the registered collectors that are switched on
+func (a *Aggregator) enabledCollectors() []Collector {
+	all := a.registry.All()
+	results := make([]Collector, 0, len(all))
+	for _, c := range all {
+		if c.Enabled() {
+			results = append(results, c)
+		}
 	}
+	return results
+}
 
+// SetTimeout sets the default timeout for a single collector run
+func (a *Aggregator) SetTimeout(timeout time.Duration) {
 	a.mu.Lock()
-	a.allMetrics["cpu"] = c
-	a.mu.Unlock()
+	defer a.mu.Unlock()
 
-	logging.Info(logtag, "successfully collected cpu metrics")
-	return nil
+	a.timeout = timeout
 }
 
-func (a *Aggregator) CollectMemory() error {
-	logging.Info(logtag, "collecting memory metrics")
-
-	m := memory.MemoryInfo{}
-	if err := m.Collect(); err != nil {
-		logging.Error(logtag, "error collecting memory metrics", err)
-		return err
-	}
-
+// SetCollectorTimeout overrides the timeout for one collector
+func (a *Aggregator) SetCollectorTimeout(name string, timeout time.Duration) {
 	a.mu.Lock()
-	a.allMetrics["memory"] = m
-	a.mu.Unlock()
+	defer a.mu.Unlock()
 
-	logging.Info(logtag, "successfully collected memory metrics")
-	return nil
+	a.timeouts[name] = timeout
 }
 
-func (a *Aggregator) CollectDisk() error {
-	logging.Info(logtag, "collecting disk metrics")
+func (a *Aggregator) timeoutFor(name string) time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	d := disk.DiskInfo{}
-	if err := d.Collect(); err != nil {
-		logging.Error(logtag, "error collecting disk metrics", err)
-		return err
+	if t, ok := a.timeouts[name]; ok && t > 0 {
+		return t
 	}
+	return a.timeout
+}
 
-	a.mu.Lock()
-	a.allMetrics["disk"] = d
-	a.mu.Unlock()
+// CollectAll collects all metrics sequentially and returns any errors encountered
+func (a *Aggregator) CollectAll(ctx context.Context) map[string]error {
+	errors := make(map[string]error)
+
+	for _, c := range a.enabledCollectors() {
+		if err := a.run(ctx, c); err != nil {
+			errors[c.Name()] = err
+		}
+	}
 
-	logging.Info(logtag, "successfully collected disk metrics")
+	if len(errors) > 0 {
+		return errors
+	}
 	return nil
 }
 
-func (a *Aggregator) CollectNetwork() error {
-	logging.Info(logtag, "collecting network metrics")
+// CollectAllConcurrent collects all metrics concurrently for better performance.
+// Every collector is bounded by its timeout, so a hung collector cannot stall
+// the whole run.
+func (a *Aggregator) CollectAllConcurrent(ctx context.Context) map[string]error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errors := make(map[string]error)
 
-	n := network.NetworkInfo{}
-	if err := n.Collect(); err != nil {
-		logging.Error(logtag, "error collecting network metrics", err)
-		return err
+	for _, collector := range a.enabledCollectors() {
+		wg.Add(1)
+		go func(c Collector) {
+			defer wg.Done()
+			if err := a.run(ctx, c); err != nil {
+				mu.Lock()
+				errors[c.Name()] = err
+				mu.Unlock()
+			}
+		}(collector)
 	}
 
-	a.mu.Lock()
-	a.allMetrics["network"] = n
-	a.mu.Unlock()
+	wg.Wait()
 
-	logging.Info(logtag, "successfully collected network metrics")
+	if len(errors) > 0 {
+		return errors
+	}
 	return nil
 }
 
-func (a *Aggregator) CollectUser() error {
-	logging.Info(logtag, "collecting user metrics")
-
-	u := user.UserInfo{}
-	if err := u.Collect(); err != nil {
-		logging.Error(logtag, "error collecting user metrics", err)
-		return err
+// Collect runs a single collector by name, whether or not it is enabled
+func (a *Aggregator) Collect(ctx context.Context, name string) error {
+	c, ok := a.registry.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown metric %q", name)
 	}
+	return a.run(ctx, c)
+}
 
-	a.mu.Lock()
-	a.allMetrics["user"] = u
-	a.mu.Unlock()
-
-	logging.Info(logtag, "successfully collected user metrics")
-	return nil
+type outcome struct {
+	result interface{}
+	err    error
 }
 
-func (a *Aggregator) CollectHost() error {
-	logging.Info(logtag, "collecting host metrics")
+// run executes a collector under its timeout and stores its result. A
+// collector that overruns is abandoned: its goroutine is left to finish in
+// the background, its result is discarded, and further runs are refused with
+// ErrBusy until it returns. A collector that panics fails with ErrPanic
+// instead of taking down the process.
+func (a *Aggregator) run(ctx context.Context, c Collector) (err error) {
+	name := c.Name()
+	start := time.Now()
+	defer func() {
+		a.recordAttempt(name, start, err)
+		a.publish(Event{Name: name, Timestamp: time.Now(), Err: err})
+	}()
 
-	h := host.HostInfo{}
-	if err := h.Collect(); err != nil {
-		logging.Error(logtag, "error collecting host metrics", err)
+	a.mu.Lock()
+	if a.running[name] {
+		a.mu.Unlock()
+		err = fmt.Errorf("%s: %w", name, ErrBusy)
+		logging.Error(logtag, fmt.Sprintf("skipping %s metrics", name), err)
 		return err
 	}
-
-	a.mu.Lock()
-	a.allMetrics["host"] = h
+	a.running[name] = true
 	a.mu.Unlock()
 
-	logging.Info(logtag, "successfully collected host metrics")
-	return nil
-}
-
-func (a *Aggregator) CollectDocker() error {
-	logging.Info(logtag, "collecting docker metrics")
-
-	d := docker.DockerInfo{}
-	if err := d.Collect(); err != nil {
-		logging.Error(logtag, "error collecting docker metrics", err)
+	timeout := a.timeoutFor(name)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logging.Info(logtag, fmt.Sprintf("collecting %s metrics", name))
+
+	done := make(chan outcome, 1)
+	go func() {
+		// deferred first so the collector is no longer marked running when
+		// the panic is reported
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("%s: %w: %v", name, ErrPanic, r)
+				logging.Error(logtag, fmt.Sprintf("recovered from panic in %s collector\n%s", name, debug.Stack()), err)
+				done <- outcome{err: err}
+			}
+		}()
+		defer func() {
+			a.mu.Lock()
+			delete(a.running, name)
+			a.mu.Unlock()
+		}()
+
+		result, err := c.Collect(ctx)
+		done <- outcome{result: result, err: err}
+	}()
+
+	var result interface{}
+	select {
+	case out := <-done:
+		if out.err != nil {
+			logging.Error(logtag, fmt.Sprintf("error collecting %s metrics", name), out.err)
+			return out.err
+		}
+		result = out.result
+	case <-ctx.Done():
+		err = ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%s after %s: %w", name, timeout, ErrTimeout)
+		}
+		logging.Error(logtag, fmt.Sprintf("abandoned %s metrics collection", name), err)
 		return err
 	}
 
+	now := time.Now()
+
 	a.mu.Lock()
-	a.allMetrics["docker"] = d
+	a.allMetrics[name] = result
+	a.collectedAt[name] = now
+	a.updateRates(name, now, result)
+	history := a.history
+	store := a.store
 	a.mu.Unlock()
 
-	logging.Info(logtag, "successfully collected docker metrics")
+	history.Add(name, Sample{Timestamp: now, Value: result})
+	if store != nil {
+		if err := store.Append(name, now, result); err != nil {
+			logging.Error(logtag, fmt.Sprintf("error persisting %s metrics", name), err)
+		}
+	}
+
+	logging.Info(logtag, fmt.Sprintf("successfully collected %s metrics", name))
 	return nil
 }
 
-func (a *Aggregator) CollectKubernetes() error {
-	logging.Info(logtag, "collecting kubernetes metrics")
+// SetHistoryRetention sets how many samples are kept per collector. Samples
+// collected so far are discarded.
+func (a *Aggregator) SetHistoryRetention(samples int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	kubernetes.ExplicitKubeconfigPath = a.kubeconfigPath
-	k := kubernetes.KubeInfo{}
-	if err := k.Collect(); err != nil {
-		logging.Error(logtag, "error collecting kubernetes metrics", err)
-		return err
-	}
+	a.history = NewHistory(samples)
+}
 
+// SetStore persists every collected result to an on-disk store so history
+// survives restarts. A nil store disables persistence.
+func (a *Aggregator) SetStore(store *storage.Store) {
 	a.mu.Lock()
-	a.allMetrics["kubernetes"] = k
-	a.mu.Unlock()
+	defer a.mu.Unlock()
 
-	logging.Info(logtag, "successfully collected kubernetes metrics")
-	return nil
+	a.store = store
 }
 
-// GetMetrics returns a copy of all collected metrics (thread-safe)
-func (a *Aggregator) GetMetrics() map[string]interface{} {
+// History returns the samples for a collector since the given time, keeping
+// at most one sample per step. Samples older than the in-memory buffer are
+// read from the on-disk store when one is configured.
+func (a *Aggregator) History(name string, since time.Time, step time.Duration) []Sample {
 	a.mu.RLock()
-	defer a.mu.RUnlock()
+	history := a.history
+	store := a.store
+	a.mu.RUnlock()
+
+	samples := history.Since(name, since)
+	if store != nil && (len(samples) == 0 || samples[0].Timestamp.After(since)) {
+		var until time.Time
+		if len(samples) > 0 {
+			until = samples[0].Timestamp
+		}
+
+		records, err := store.Query(name, since, until)
+		if err != nil {
+			logging.Error(logtag, fmt.Sprintf("error reading %s history from store", name), err)
+		}
 
-	metrics := make(map[string]interface{}, len(a.allMetrics))
-	for k, v := range a.allMetrics {
-		metrics[k] = v
+		if len(records) > 0 {
+			merged := make([]Sample, 0, len(records)+len(samples))
+			for _, rec := range records {
+				merged = append(merged, Sample{Timestamp: rec.Timestamp, Value: rec.Value})
+			}
+			samples = append(merged, samples...)
+		}
 	}
-	return metrics
+
+	return Downsample(samples, step)
 }
 
 // GetMetric returns a specific metric by name (thread-safe)
@@ -287,5 +464,8 @@ func (a *Aggregator) Clear() {
 	defer a.mu.Unlock()
 
 	a.allMetrics = make(map[string]interface{})
+	a.collectedAt = make(map[string]time.Time)
+	a.counters = make(map[string]counterReading)
+	a.rates = make(map[string]map[string]float64)
 	logging.Info(logtag, "cleared all metrics")
 }
@@ -0,0 +1,106 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Collector is a single source of metrics that the aggregator can run.
+// Built-in collectors wrap the metric packages; in-house collectors can be
+// added with Aggregator.Register.
+type Collector interface {
+	// Name is the key the result is stored under, eg "cpu"
+	Name() string
+	// Collect gathers a fresh result
+	Collect(ctx context.Context) (interface{}, error)
+	// Enabled reports whether the collector takes part in CollectAll runs
+	Enabled() bool
+}
+
+type funcCollector struct {
+	name    string
+	enabled func() bool
+	collect func(ctx context.Context) (interface{}, error)
+}
+
+// NewCollector builds a Collector from plain functions. A nil enabled func
+// means the collector is always enabled.
+func NewCollector(name string, enabled func() bool, collect func(ctx context.Context) (interface{}, error)) Collector {
+	return &funcCollector{name: name, enabled: enabled, collect: collect}
+}
+
+func (f *funcCollector) Name() string {
+	return f.name
+}
+
+func (f *funcCollector) Collect(ctx context.Context) (interface{}, error) {
+	return f.collect(ctx)
+}
+
+func (f *funcCollector) Enabled() bool {
+	if f.enabled == nil {
+		return true
+	}
+	return f.enabled()
+}
+
+// Registry keeps collectors in registration order
+type Registry struct {
+	mu         sync.RWMutex
+	order      []string
+	collectors map[string]Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]Collector),
+	}
+}
+
+// Register adds a collector. Names must be unique.
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := c.Name()
+	if name == "" {
+		return fmt.Errorf("collector name must not be empty")
+	}
+	if _, exists := r.collectors[name]; exists {
+		return fmt.Errorf("collector %q is already registered", name)
+	}
+
+	r.collectors[name] = c
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Get returns the collector registered under name
+func (r *Registry) Get(name string) (Collector, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.collectors[name]
+	return c, ok
+}
+
+// All returns every registered collector in registration order
+func (r *Registry) All() []Collector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := make([]Collector, 0, len(r.order))
+	for _, name := range r.order {
+		results = append(results, r.collectors[name])
+	}
+	return results
+}
+
+// Names returns the names of every registered collector in registration order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.order...)
+}
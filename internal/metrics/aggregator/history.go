@@ -0,0 +1,117 @@
+package aggregator
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultHistoryRetention is the number of samples kept per collector.
+// At the default 30s refresh this covers six hours.
+const DefaultHistoryRetention = 720
+
+// Sample is a collector result stamped with the time it was collected
+type Sample struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Value     interface{} `json:"value"`
+}
+
+// ring is a fixed size circular buffer of samples
+type ring struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func newRing(capacity int) *ring {
+	return &ring{samples: make([]Sample, capacity)}
+}
+
+func (r *ring) add(s Sample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// ordered returns the samples from oldest to newest
+func (r *ring) ordered() []Sample {
+	if !r.full {
+		return append([]Sample(nil), r.samples[:r.next]...)
+	}
+	results := make([]Sample, 0, len(r.samples))
+	results = append(results, r.samples[r.next:]...)
+	return append(results, r.samples[:r.next]...)
+}
+
+// History keeps a bounded ring buffer of samples per collector
+type History struct {
+	mu       sync.RWMutex
+	capacity int
+	series   map[string]*ring
+}
+
+// NewHistory creates a history that retains capacity samples per collector
+func NewHistory(capacity int) *History {
+	if capacity <= 0 {
+		capacity = DefaultHistoryRetention
+	}
+	return &History{
+		capacity: capacity,
+		series:   make(map[string]*ring),
+	}
+}
+
+// Add records a sample for the named collector, evicting the oldest one once
+// the buffer is full
+func (h *History) Add(name string, s Sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.series[name]
+	if !ok {
+		r = newRing(h.capacity)
+		h.series[name] = r
+	}
+	r.add(s)
+}
+
+// Since returns the samples for name collected at or after since, oldest first
+func (h *History) Since(name string, since time.Time) []Sample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r, ok := h.series[name]
+	if !ok {
+		return nil
+	}
+
+	all := r.ordered()
+	for i, s := range all {
+		if !s.Timestamp.Before(since) {
+			return all[i:]
+		}
+	}
+	return nil
+}
+
+// Downsample keeps the latest sample in each step-wide time bucket. Samples
+// must be ordered oldest first. A non-positive step returns samples unchanged.
+func Downsample(samples []Sample, step time.Duration) []Sample {
+	if step <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	results := make([]Sample, 0, len(samples))
+	var lastBucket int64
+	for i, s := range samples {
+		bucket := s.Timestamp.UnixNano() / int64(step)
+		if i > 0 && bucket == lastBucket {
+			results[len(results)-1] = s
+			continue
+		}
+		results = append(results, s)
+		lastBucket = bucket
+	}
+	return results
+}
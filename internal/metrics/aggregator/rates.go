@@ -0,0 +1,68 @@
+package aggregator
+
+import (
+	"time"
+)
+
+// CounterSource is implemented by collector results that carry monotonic
+// counters, such as bytes sent per network interface. The aggregator keeps
+// the previous reading of every counter and publishes per-second rates.
+type CounterSource interface {
+	// Counters returns the cumulative counters keyed by a stable name, eg
+	// "eth0/bytesSent"
+	Counters() map[string]uint64
+}
+
+// counterReading is the previous reading of a collector's counters
+type counterReading struct {
+	at     time.Time
+	values map[string]uint64
+}
+
+// updateRates derives per-second rates from the counters in result and
+// remembers them for the next run. Callers must hold a.mu.
+//
+// A counter that went backwards was reset, eg after a reboot or because the
+// interface was re-created, so it gets no rate until the next reading. The
+// same applies to counters seen for the first time.
+func (a *Aggregator) updateRates(name string, at time.Time, result interface{}) {
+	source, ok := result.(CounterSource)
+	if !ok {
+		return
+	}
+
+	current := counterReading{at: at, values: source.Counters()}
+	previous, seen := a.counters[name]
+	a.counters[name] = current
+
+	rates := make(map[string]float64, len(current.values))
+	elapsed := current.at.Sub(previous.at).Seconds()
+	if seen && elapsed > 0 {
+		for key, value := range current.values {
+			last, ok := previous.values[key]
+			if !ok || value < last {
+				continue
+			}
+			rates[key] = float64(value-last) / elapsed
+		}
+	}
+	a.rates[name] = rates
+}
+
+// Rates returns the per-second rates of a collector's counters as of its
+// last two runs
+func (a *Aggregator) Rates(name string) map[string]float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	rates, ok := a.rates[name]
+	if !ok {
+		return nil
+	}
+
+	results := make(map[string]float64, len(rates))
+	for key, rate := range rates {
+		results[key] = rate
+	}
+	return results
+}
@@ -0,0 +1,105 @@
+package aggregator
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInterval is how often a collector runs unless overridden
+	DefaultInterval = 30 * time.Second
+	// Jitter moves every run by up to this fraction of the interval either
+	// way, so collectors sharing an interval do not all fire at once
+	Jitter = 0.1
+	// MinGap is the minimum pause between the end of a run and the start of
+	// the next run of the same collector
+	MinGap = time.Second
+)
+
+// SetInterval sets how often collectors run unless overridden
+func (a *Aggregator) SetInterval(interval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.interval = interval
+}
+
+// SetCollectorInterval overrides the interval for one collector
+func (a *Aggregator) SetCollectorInterval(name string, interval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.intervals[name] = interval
+}
+
+func (a *Aggregator) intervalFor(name string) time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if i, ok := a.intervals[name]; ok && i > 0 {
+		return i
+	}
+	return a.interval
+}
+
+// Schedule runs every registered collector on its own interval until ctx is
+// cancelled and returns once every collector has stopped. Runs use
+// collectCtx, so an in-flight run is allowed to finish after ctx is cancelled
+// unless collectCtx is cancelled too. Collectors registered after Schedule is
+// called are not scheduled.
+func (a *Aggregator) Schedule(ctx, collectCtx context.Context) {
+	var wg sync.WaitGroup
+	for _, collector := range a.registry.All() {
+		wg.Add(1)
+		go func(c Collector) {
+			defer wg.Done()
+			a.schedule(ctx, collectCtx, c)
+		}(collector)
+	}
+	wg.Wait()
+}
+
+// schedule runs one collector until ctx or collectCtx is cancelled. Runs of
+// the same collector never overlap as the next run is only planned once the
+// previous one has returned. Disabled collectors are checked again every
+// interval.
+func (a *Aggregator) schedule(ctx, collectCtx context.Context, c Collector) {
+	name := c.Name()
+	for {
+		start := time.Now()
+		if c.Enabled() {
+			// failures are logged and recorded in the status by run
+			a.run(collectCtx, c)
+		}
+
+		next := nextRun(start, time.Now(), a.intervalFor(name))
+		a.recordNextRun(name, next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-collectCtx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// nextRun picks the start of the next run: one interval after the previous
+// start give or take Jitter, but never sooner than MinGap after it ended
+func nextRun(start, end time.Time, interval time.Duration) time.Time {
+	next := start.Add(interval)
+	if spread := int64(float64(interval) * Jitter); spread > 0 {
+		next = next.Add(time.Duration(rand.Int64N(2*spread+1) - spread))
+	}
+
+	if earliest := end.Add(MinGap); next.Before(earliest) {
+		next = earliest
+	}
+	return next
+}
@@ -0,0 +1,159 @@
+package aggregator
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	gohost "github.com/shirou/gopsutil/v4/host"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/cgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/cpu"
+	"github.com/techtacles/sysmonitoring/internal/metrics/disk"
+	"github.com/techtacles/sysmonitoring/internal/metrics/docker"
+	"github.com/techtacles/sysmonitoring/internal/metrics/host"
+	"github.com/techtacles/sysmonitoring/internal/metrics/kernel"
+	"github.com/techtacles/sysmonitoring/internal/metrics/kubernetes"
+	"github.com/techtacles/sysmonitoring/internal/metrics/memory"
+	"github.com/techtacles/sysmonitoring/internal/metrics/network"
+	"github.com/techtacles/sysmonitoring/internal/metrics/pressure"
+	"github.com/techtacles/sysmonitoring/internal/metrics/procgroup"
+	"github.com/techtacles/sysmonitoring/internal/metrics/sensors"
+	"github.com/techtacles/sysmonitoring/internal/metrics/user"
+)
+
+// SnapshotVersion is bumped whenever a field of Snapshot, or of one of the
+// metric types it holds, is renamed or removed. Adding fields does not bump it.
+const SnapshotVersion = 1
+
+// Identity identifies the machine a snapshot was taken on
+type Identity struct {
+	Hostname string `json:"hostname"`
+	HostID   string `json:"hostId,omitempty"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+}
+
+// Snapshot is the latest result of every collector. Metrics that have not
+// been collected yet are nil and omitted from the JSON. Results of
+// collectors without a dedicated field, such as in-house collectors, are
+// kept in Extra under their collector name.
+type Snapshot struct {
+	Version       int                    `json:"version"`
+	Timestamp     time.Time              `json:"timestamp"`
+	Identity      Identity               `json:"identity"`
+	CollectedAt   map[string]time.Time   `json:"collectedAt"`
+	CPU           *cpu.CpuInfo           `json:"cpu,omitempty"`
+	Sensors       *sensors.SensorsInfo   `json:"sensors,omitempty"`
+	Memory        *memory.MemoryInfo     `json:"memory,omitempty"`
+	Cgroups       *cgroup.CgroupsInfo    `json:"cgroups,omitempty"`
+	Disk          *disk.DiskInfo         `json:"disk,omitempty"`
+	Network       *network.NetworkInfo   `json:"network,omitempty"`
+	Host          *host.HostInfo         `json:"host,omitempty"`
+	Pressure      *pressure.PressureInfo `json:"pressure,omitempty"`
+	Kernel        *kernel.KernelInfo     `json:"kernel,omitempty"`
+	User          *user.UserInfo         `json:"user,omitempty"`
+	Docker        *docker.DockerInfo     `json:"docker,omitempty"`
+	Kubernetes    *kubernetes.KubeInfo   `json:"kubernetes,omitempty"`
+	ProcessGroups *procgroup.GroupsInfo  `json:"processGroups,omitempty"`
+	Extra         map[string]interface{} `json:"extra,omitempty"`
+	// Rates holds per-second rates of cumulative counters by collector, eg
+	// Rates["network"]["eth0/bytesRecv"]
+	Rates map[string]map[string]float64 `json:"rates,omitempty"`
+}
+
+// set places a collector result in its typed field, falling back to Extra
+func (s *Snapshot) set(name string, value interface{}) {
+	switch v := value.(type) {
+	case *cpu.CpuInfo:
+		s.CPU = v
+	case *sensors.SensorsInfo:
+		s.Sensors = v
+	case *memory.MemoryInfo:
+		s.Memory = v
+	case *cgroup.CgroupsInfo:
+		s.Cgroups = v
+	case *disk.DiskInfo:
+		s.Disk = v
+	case *network.NetworkInfo:
+		s.Network = v
+	case *host.HostInfo:
+		s.Host = v
+	case *pressure.PressureInfo:
+		s.Pressure = v
+	case *kernel.KernelInfo:
+		s.Kernel = v
+	case *user.UserInfo:
+		s.User = v
+	case *docker.DockerInfo:
+		s.Docker = v
+	case *kubernetes.KubeInfo:
+		s.Kubernetes = v
+	case *procgroup.GroupsInfo:
+		s.ProcessGroups = v
+	default:
+		if s.Extra == nil {
+			s.Extra = make(map[string]interface{})
+		}
+		s.Extra[name] = value
+	}
+}
+
+// newIdentity looks up the identity of the current machine
+func newIdentity() Identity {
+	id := Identity{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		id.Hostname = hostname
+	} else {
+		logging.Error(logtag, "error getting hostname", err)
+	}
+
+	if hostID, err := gohost.HostID(); err == nil {
+		id.HostID = hostID
+	}
+	return id
+}
+
+// Snapshot returns the latest result of every collector (thread-safe). When
+// names are given only those collectors are included.
+func (a *Aggregator) Snapshot(names ...string) Snapshot {
+	a.identityOnce.Do(func() {
+		a.identity = newIdentity()
+	})
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	s := Snapshot{
+		Version:     SnapshotVersion,
+		Identity:    a.identity,
+		CollectedAt: make(map[string]time.Time, len(a.allMetrics)),
+	}
+	only := make(map[string]bool, len(names))
+	for _, name := range names {
+		only[name] = true
+	}
+
+	for name, value := range a.allMetrics {
+		if len(only) > 0 && !only[name] {
+			continue
+		}
+		s.set(name, value)
+		if rates := a.rates[name]; len(rates) > 0 {
+			if s.Rates == nil {
+				s.Rates = make(map[string]map[string]float64)
+			}
+			s.Rates[name] = rates
+		}
+		at := a.collectedAt[name]
+		s.CollectedAt[name] = at
+		if at.After(s.Timestamp) {
+			s.Timestamp = at
+		}
+	}
+	return s
+}
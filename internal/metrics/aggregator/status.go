@@ -0,0 +1,86 @@
+package aggregator
+
+import (
+	"errors"
+	"time"
+)
+
+// Status describes the health of a single collector
+type Status struct {
+	Name                string    `json:"name"`
+	Enabled             bool      `json:"enabled"`
+	Healthy             bool      `json:"healthy"`
+	Running             bool      `json:"running"`
+	TimedOut            bool      `json:"timedOut"`
+	LastAttempt         time.Time `json:"lastAttempt"`
+	LastSuccess         time.Time `json:"lastSuccess"`
+	DurationMs          float64   `json:"durationMs"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	NextRun             time.Time `json:"nextRun"`
+}
+
+// recordAttempt updates the status of a collector after a run
+func (a *Aggregator) recordAttempt(name string, start time.Time, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.status[name]
+	if !ok {
+		st = &Status{Name: name}
+		a.status[name] = st
+	}
+
+	st.LastAttempt = start
+	st.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	st.TimedOut = errors.Is(err, ErrTimeout) || errors.Is(err, ErrBusy)
+
+	if err != nil {
+		st.ConsecutiveFailures++
+		st.LastError = err.Error()
+		return
+	}
+
+	st.LastSuccess = start
+	st.ConsecutiveFailures = 0
+	st.LastError = ""
+}
+
+// recordNextRun records when the scheduler will next run a collector
+func (a *Aggregator) recordNextRun(name string, next time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.status[name]
+	if !ok {
+		st = &Status{Name: name}
+		a.status[name] = st
+	}
+	st.NextRun = next
+}
+
+// Statuses returns the health of every registered collector in registration
+// order. Collectors that have not run yet have zero attempt times.
+func (a *Aggregator) Statuses() []Status {
+	collectors := a.registry.All()
+	enabled := make([]bool, len(collectors))
+	for i, c := range collectors {
+		enabled[i] = c.Enabled()
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	results := make([]Status, 0, len(collectors))
+	for i, c := range collectors {
+		st := Status{Name: c.Name()}
+		if recorded, ok := a.status[c.Name()]; ok {
+			st = *recorded
+		}
+		st.Enabled = enabled[i]
+		st.Running = a.running[c.Name()]
+		st.Healthy = !st.LastAttempt.IsZero() && st.ConsecutiveFailures == 0
+		results = append(results, st)
+	}
+	return results
+}
@@ -0,0 +1,56 @@
+package aggregator
+
+import (
+	"time"
+)
+
+// DefaultSubscriptionBuffer is how many events a subscriber may fall behind
+// before further events are dropped for it
+const DefaultSubscriptionBuffer = 64
+
+// Event is published after every completed collector run
+type Event struct {
+	Name      string    `json:"name"`
+	Timestamp time.Time `json:"timestamp"`
+	// Err is set when the run failed or timed out; the previous result of the
+	// collector is then still the latest one
+	Err error `json:"-"`
+}
+
+// Subscribe returns a channel receiving an Event after every collector run,
+// and a function that cancels the subscription and closes the channel.
+// Events are never blocked on: a subscriber whose buffer is full misses them.
+func (a *Aggregator) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	ch := make(chan Event, buffer)
+
+	a.subMu.Lock()
+	a.subscribers[ch] = struct{}{}
+	a.subMu.Unlock()
+
+	cancel := func() {
+		a.subMu.Lock()
+		defer a.subMu.Unlock()
+
+		if _, ok := a.subscribers[ch]; ok {
+			delete(a.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish sends an event to every subscriber without blocking
+func (a *Aggregator) publish(ev Event) {
+	a.subMu.Lock()
+	defer a.subMu.Unlock()
+
+	for ch := range a.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,461 @@
+// Package cgroup reports resource usage against the limits of control
+// groups, so that sysmon running in a container or a systemd slice shows
+// the limits that actually apply instead of the host totals. It reads
+// cgroup v2 and falls back to the v1 controller hierarchies.
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+const logtag string = "cgroup"
+
+// CollectorName is the name the cgroups are collected under
+const CollectorName = "cgroups"
+
+// SelfName names the cgroup sysmon itself runs in
+const SelfName = "self"
+
+// Root is where the cgroup filesystem is mounted
+var Root = "/sys/fs/cgroup"
+
+// ProcfsRoot is where procfs is mounted. sysmon's own cgroup is read from
+// self/cgroup below it.
+var ProcfsRoot = "/proc"
+
+// unlimitedV1 is the smallest value cgroup v1 uses for "no memory limit",
+// which is the largest page counter rounded down to the page size
+const unlimitedV1 = 1 << 62
+
+// CgroupsInfo holds sysmon's own cgroup followed by the configured ones
+type CgroupsInfo struct {
+	Version int          `json:"version"` // 1 or 2
+	Cgroups []CgroupInfo `json:"cgroups"`
+}
+
+// Controllers listed in CgroupInfo.Unavailable. The v1 cpuacct and blkio
+// controllers are reported as cpu and io.
+const (
+	ControllerMemory = "memory"
+	ControllerCPU    = "cpu"
+	ControllerIO     = "io"
+	ControllerPids   = "pids"
+)
+
+// CgroupInfo is the usage and limits of one cgroup. Limits are 0 when
+// unlimited. The fields of a controller that is not enabled for the cgroup,
+// or whose files cannot be read, are 0 and the controller is listed in
+// Unavailable.
+type CgroupInfo struct {
+	Name             string   `json:"name"`
+	Path             string   `json:"path"` // relative to Root, eg "/system.slice/nginx.service"
+	Error            string   `json:"error,omitempty"`
+	Unavailable      []string `json:"unavailable,omitempty"`
+	MemoryUsage      uint64   `json:"memoryUsage"`
+	MemoryLimit      uint64   `json:"memoryLimit"`
+	MemoryPercent    float64  `json:"memoryPercent"` // of MemoryLimit
+	CPULimit         float64  `json:"cpuLimit"`      // in cpus, eg 0.5
+	CPUUsage         uint64   `json:"cpuUsageUsec"`  // since the cgroup was created
+	Periods          uint64   `json:"periods"`       // cpu quota enforcement periods
+	ThrottledPeriods uint64   `json:"throttledPeriods"`
+	ThrottledPercent float64  `json:"throttledPercent"` // of Periods
+	ThrottledTime    uint64   `json:"throttledUsec"`
+	IO               []IOStat `json:"io"`
+	Pids             uint64   `json:"pids"`
+	PidsLimit        uint64   `json:"pidsLimit"`
+}
+
+// IOStat is the I/O of a cgroup on one block device
+type IOStat struct {
+	Device     string `json:"device"` // eg "sda", or "8:0" when the name is unknown
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+	ReadOps    uint64 `json:"readOps"`
+	WriteOps   uint64 `json:"writeOps"`
+}
+
+// Available reports whether cgroups can be read, ie on Linux
+func Available() bool {
+	if _, err := os.Stat(filepath.Join(ProcfsRoot, "self", "cgroup")); err != nil {
+		return false
+	}
+	_, err := os.Stat(Root)
+	return err == nil
+}
+
+// Collect reads sysmon's own cgroup and the given cgroup paths, eg
+// "system.slice/nginx.service" or "user.slice". A path that does not exist
+// is reported with an error instead of failing the collection.
+func Collect(ctx context.Context, paths []string) (*CgroupsInfo, error) {
+	logging.Info(logtag, "collecting cgroup usage")
+
+	version := 1
+	if _, err := os.Stat(filepath.Join(Root, "cgroup.controllers")); err == nil {
+		version = 2
+	}
+
+	self, err := selfPaths()
+	if err != nil {
+		logging.Error(logtag, "error reading the cgroup of sysmon", err)
+		return nil, err
+	}
+
+	devices := deviceNames()
+	info := &CgroupsInfo{Version: version, Cgroups: make([]CgroupInfo, 0, len(paths)+1)}
+
+	// v2 has a single hierarchy, v1 places a process in one cgroup per
+	// controller
+	selfPath := selfDir(Root, self[""])
+	if version == 1 {
+		selfPath = selfDir(filepath.Join(Root, "memory"), self["memory"])
+	}
+	info.Cgroups = append(info.Cgroups, read(version, SelfName, selfPath, func(controller string) string {
+		return selfDir(filepath.Join(Root, controller), self[controller])
+	}, devices))
+
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// cleaning a rooted path drops any ".." that would leave Root
+		path = filepath.Clean("/" + path)
+		info.Cgroups = append(info.Cgroups, read(version, strings.TrimPrefix(path, "/"), path, func(string) string {
+			return path
+		}, devices))
+	}
+
+	logging.Info(logtag, "successfully collected cgroup usage")
+	return info, nil
+}
+
+// Counters exposes the cumulative counters so the aggregator publishes them
+// as rates, eg "self/cpuUsageUsec", "self/throttledUsec" and
+// "self/sda/readBytes"
+func (c *CgroupsInfo) Counters() map[string]uint64 {
+	counters := make(map[string]uint64)
+	for _, cg := range c.Cgroups {
+		if cg.Error != "" {
+			continue
+		}
+		if cg.Available(ControllerCPU) {
+			counters[cg.Name+"/cpuUsageUsec"] = cg.CPUUsage
+			counters[cg.Name+"/throttledUsec"] = cg.ThrottledTime
+		}
+		for _, io := range cg.IO {
+			counters[cg.Name+"/"+io.Device+"/readBytes"] = io.ReadBytes
+			counters[cg.Name+"/"+io.Device+"/writeBytes"] = io.WriteBytes
+		}
+	}
+	return counters
+}
+
+// Available reports whether a controller could be read for the cgroup
+func (c CgroupInfo) Available(controller string) bool {
+	return !slices.Contains(c.Unavailable, controller)
+}
+
+// selfDir returns the path of sysmon's cgroup below root. Without a cgroup
+// namespace, eg in a Docker container on a v1 host, /proc/self/cgroup shows
+// the path on the host, eg "/docker/<id>", while root is already the
+// container's own cgroup, so a path that does not exist falls back to root.
+func selfDir(root, path string) string {
+	if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+		return "/"
+	}
+	return path
+}
+
+// read reads one cgroup. pathOf returns the path of the cgroup in the
+// hierarchy of a v1 controller.
+func read(version int, name, path string, pathOf func(controller string) string, devices map[string]string) CgroupInfo {
+	cg := CgroupInfo{Name: name, Path: path, IO: []IOStat{}}
+
+	var err error
+	if version == 2 {
+		err = cg.readV2(filepath.Join(Root, path))
+	} else {
+		err = cg.readV1(func(controller string) string {
+			return filepath.Join(Root, controller, pathOf(controller))
+		})
+	}
+	if err != nil {
+		logging.Error(logtag, fmt.Sprintf("error reading cgroup %s", path), err)
+		cg.Error = err.Error()
+		return cg
+	}
+
+	if cg.MemoryLimit > 0 {
+		cg.MemoryPercent = float64(cg.MemoryUsage) / float64(cg.MemoryLimit) * 100
+	}
+	if cg.Periods > 0 {
+		cg.ThrottledPercent = float64(cg.ThrottledPeriods) / float64(cg.Periods) * 100
+	}
+	for i, io := range cg.IO {
+		if device, ok := devices[io.Device]; ok {
+			cg.IO[i].Device = device
+		}
+	}
+	sort.Slice(cg.IO, func(i, j int) bool { return cg.IO[i].Device < cg.IO[j].Device })
+	return cg
+}
+
+// readV2 reads the interface files of a cgroup v2 directory
+func (cg *CgroupInfo) readV2(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("cgroup not found: %w", err)
+	}
+
+	// a controller's files only exist when it is enabled for the cgroup. A
+	// missing limit means none applies, eg in the root cgroup.
+	var err error
+	if cg.MemoryUsage, err = readUint(filepath.Join(dir, "memory.current")); err != nil {
+		cg.unavailable(ControllerMemory)
+	} else {
+		cg.MemoryLimit, _ = readUint(filepath.Join(dir, "memory.max"))
+	}
+	if cg.Pids, err = readUint(filepath.Join(dir, "pids.current")); err != nil {
+		cg.unavailable(ControllerPids)
+	} else {
+		cg.PidsLimit, _ = readUint(filepath.Join(dir, "pids.max"))
+	}
+
+	// cpu.max is "$MAX $PERIOD", where $MAX is "max" without a limit
+	if fields := strings.Fields(readString(filepath.Join(dir, "cpu.max"))); len(fields) == 2 && fields[0] != "max" {
+		quota, _ := strconv.ParseFloat(fields[0], 64)
+		period, _ := strconv.ParseFloat(fields[1], 64)
+		if period > 0 {
+			cg.CPULimit = quota / period
+		}
+	}
+
+	stat := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if _, ok := stat["usage_usec"]; !ok {
+		cg.unavailable(ControllerCPU)
+	}
+	cg.CPUUsage = stat["usage_usec"]
+	cg.Periods = stat["nr_periods"]
+	cg.ThrottledPeriods = stat["nr_throttled"]
+	cg.ThrottledTime = stat["throttled_usec"]
+
+	// io.stat has one line per device:
+	// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+	if _, err := os.Stat(filepath.Join(dir, "io.stat")); err != nil {
+		cg.unavailable(ControllerIO)
+	}
+	for _, line := range readLines(filepath.Join(dir, "io.stat")) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		io := IOStat{Device: fields[0]}
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			n, _ := strconv.ParseUint(value, 10, 64)
+			switch key {
+			case "rbytes":
+				io.ReadBytes = n
+			case "wbytes":
+				io.WriteBytes = n
+			case "rios":
+				io.ReadOps = n
+			case "wios":
+				io.WriteOps = n
+			}
+		}
+		cg.IO = append(cg.IO, io)
+	}
+	return nil
+}
+
+// readV1 reads the files of the v1 controllers. dirOf returns the
+// directory of the cgroup in the hierarchy of a controller.
+func (cg *CgroupInfo) readV1(dirOf func(controller string) string) error {
+	found := false
+	for _, controller := range []string{"memory", "cpu", "cpuacct", "blkio", "pids"} {
+		if _, err := os.Stat(dirOf(controller)); err == nil {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("cgroup not found in any controller hierarchy")
+	}
+
+	memory := dirOf("memory")
+	var err error
+	if cg.MemoryUsage, err = readUint(filepath.Join(memory, "memory.usage_in_bytes")); err != nil {
+		cg.unavailable(ControllerMemory)
+	} else if limit, _ := readUint(filepath.Join(memory, "memory.limit_in_bytes")); limit < unlimitedV1 {
+		cg.MemoryLimit = limit
+	}
+
+	pids := dirOf("pids")
+	if cg.Pids, err = readUint(filepath.Join(pids, "pids.current")); err != nil {
+		cg.unavailable(ControllerPids)
+	} else {
+		cg.PidsLimit, _ = readUint(filepath.Join(pids, "pids.max"))
+	}
+
+	// the quota is -1 without a limit
+	cpu := dirOf("cpu")
+	quota, err := strconv.ParseInt(readString(filepath.Join(cpu, "cpu.cfs_quota_us")), 10, 64)
+	period, _ := readUint(filepath.Join(cpu, "cpu.cfs_period_us"))
+	if err == nil && quota > 0 && period > 0 {
+		cg.CPULimit = float64(quota) / float64(period)
+	}
+
+	// v1 counts cpu time in nanoseconds
+	stat := readKeyValues(filepath.Join(cpu, "cpu.stat"))
+	cg.Periods = stat["nr_periods"]
+	cg.ThrottledPeriods = stat["nr_throttled"]
+	cg.ThrottledTime = stat["throttled_time"] / 1000
+	usage, err := readUint(filepath.Join(dirOf("cpuacct"), "cpuacct.usage"))
+	if err != nil {
+		cg.unavailable(ControllerCPU)
+	}
+	cg.CPUUsage = usage / 1000
+
+	// the blkio files have one line per device and operation, eg
+	// "8:0 Read 1459200", and a final "Total" line
+	blkio := dirOf("blkio")
+	if _, err := os.Stat(filepath.Join(blkio, "blkio.throttle.io_service_bytes")); err != nil {
+		cg.unavailable(ControllerIO)
+	}
+	byDevice := make(map[string]*IOStat)
+	for file, isBytes := range map[string]bool{"blkio.throttle.io_service_bytes": true, "blkio.throttle.io_serviced": false} {
+		for _, line := range readLines(filepath.Join(blkio, file)) {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			io, ok := byDevice[fields[0]]
+			if !ok {
+				io = &IOStat{Device: fields[0]}
+				byDevice[fields[0]] = io
+			}
+			n, _ := strconv.ParseUint(fields[2], 10, 64)
+			switch {
+			case fields[1] == "Read" && isBytes:
+				io.ReadBytes = n
+			case fields[1] == "Write" && isBytes:
+				io.WriteBytes = n
+			case fields[1] == "Read":
+				io.ReadOps = n
+			case fields[1] == "Write":
+				io.WriteOps = n
+			}
+		}
+	}
+	for _, io := range byDevice {
+		cg.IO = append(cg.IO, *io)
+	}
+	return nil
+}
+
+// unavailable records that a controller could not be read
+func (cg *CgroupInfo) unavailable(controller string) {
+	if !slices.Contains(cg.Unavailable, controller) {
+		cg.Unavailable = append(cg.Unavailable, controller)
+	}
+}
+
+// selfPaths reads the cgroups of sysmon from /proc/self/cgroup, keyed by v1
+// controller. The v2 cgroup is keyed by "".
+//
+//	4:memory:/docker/0123abcd
+//	2:cpu,cpuacct:/docker/0123abcd
+//	0::/system.slice/sysmon.service
+func selfPaths() (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(ProcfsRoot, "self", "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			paths[""] = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths, nil
+}
+
+// deviceNames maps "major:minor" to block device names from /proc/partitions
+func deviceNames() map[string]string {
+	names := make(map[string]string)
+	for _, line := range readLines(filepath.Join(ProcfsRoot, "partitions")) {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue // the header
+		}
+		names[fields[0]+":"+fields[1]] = fields[3]
+	}
+	return names
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readUint reads a single number. "max", used by v2 for no limit, reads
+// as 0.
+func readUint(path string) (uint64, error) {
+	value := readString(path)
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// readKeyValues reads a flat keyed file such as cpu.stat
+func readKeyValues(path string) map[string]uint64 {
+	values := make(map[string]uint64)
+	for _, line := range readLines(path) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = n
+		}
+	}
+	return values
+}
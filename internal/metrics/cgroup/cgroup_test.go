@@ -0,0 +1,388 @@
+package cgroup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree writes files relative to root, creating their directories.
+// A name ending in "/" is created as an empty directory.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// useRoots points Root and ProcfsRoot at fresh directories for one test
+func useRoots(t *testing.T) (string, string) {
+	t.Helper()
+	oldRoot, oldProcfs := Root, ProcfsRoot
+	t.Cleanup(func() { Root, ProcfsRoot = oldRoot, oldProcfs })
+	Root, ProcfsRoot = t.TempDir(), t.TempDir()
+	return Root, ProcfsRoot
+}
+
+func TestReadV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    CgroupInfo
+		wantErr bool
+	}{
+		{
+			name: "limited",
+			files: map[string]string{
+				"memory.current": "104857600\n",
+				"memory.max":     "209715200\n",
+				"pids.current":   "12\n",
+				"pids.max":       "100\n",
+				"cpu.max":        "50000 100000\n",
+				"cpu.stat":       "usage_usec 5000\nuser_usec 3000\nsystem_usec 2000\nnr_periods 40\nnr_throttled 10\nthrottled_usec 750\n",
+				"io.stat":        "8:0 rbytes=1024 wbytes=2048 rios=3 wios=4 dbytes=0 dios=0\n",
+			},
+			want: CgroupInfo{
+				MemoryUsage:      104857600,
+				MemoryLimit:      209715200,
+				Pids:             12,
+				PidsLimit:        100,
+				CPULimit:         0.5,
+				CPUUsage:         5000,
+				Periods:          40,
+				ThrottledPeriods: 10,
+				ThrottledTime:    750,
+				IO:               []IOStat{{Device: "8:0", ReadBytes: 1024, WriteBytes: 2048, ReadOps: 3, WriteOps: 4}},
+			},
+		},
+		{
+			name: "unlimited",
+			files: map[string]string{
+				"memory.current": "4096\n",
+				"memory.max":     "max\n",
+				"pids.current":   "1\n",
+				"pids.max":       "max\n",
+				"cpu.max":        "max 100000\n",
+				"cpu.stat":       "usage_usec 10\n",
+				"io.stat":        "",
+			},
+			want: CgroupInfo{MemoryUsage: 4096, Pids: 1, CPUUsage: 10},
+		},
+		{
+			// only cpu.stat is always there; the other controllers are not
+			// enabled for the cgroup
+			name: "controllers not enabled",
+			files: map[string]string{
+				"cgroup.procs": "",
+				"cpu.stat":     "usage_usec 10\nuser_usec 5\nsystem_usec 5\n",
+			},
+			want: CgroupInfo{CPUUsage: 10, Unavailable: []string{ControllerMemory, ControllerPids, ControllerIO}},
+		},
+		{
+			name: "unparseable usage",
+			files: map[string]string{
+				"memory.current": "lots\n",
+				"pids.current":   "1\n",
+				"cpu.stat":       "usage_usec 10\n",
+				"io.stat":        "",
+			},
+			want: CgroupInfo{Pids: 1, CPUUsage: 10, Unavailable: []string{ControllerMemory}},
+		},
+		{
+			name:    "missing cgroup",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "app.slice")
+			if tt.files != nil {
+				writeTree(t, dir, tt.files)
+			}
+
+			var cg CgroupInfo
+			err := cg.readV2(dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("readV2() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readV2() error = %v", err)
+			}
+			if cg.IO == nil {
+				cg.IO = tt.want.IO
+			}
+			if !reflect.DeepEqual(cg, tt.want) {
+				t.Errorf("readV2() = %+v, want %+v", cg, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadV1(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    CgroupInfo
+		wantErr bool
+	}{
+		{
+			name: "limited",
+			files: map[string]string{
+				"memory/memory.usage_in_bytes":          "1048576\n",
+				"memory/memory.limit_in_bytes":          "2097152\n",
+				"pids/pids.current":                     "3\n",
+				"pids/pids.max":                         "max\n",
+				"cpu/cpu.cfs_quota_us":                  "150000\n",
+				"cpu/cpu.cfs_period_us":                 "100000\n",
+				"cpu/cpu.stat":                          "nr_periods 20\nnr_throttled 5\nthrottled_time 3000000\n",
+				"cpuacct/cpuacct.usage":                 "7000000\n",
+				"blkio/blkio.throttle.io_service_bytes": "8:0 Read 4096\n8:0 Write 8192\n8:0 Sync 0\n8:0 Total 12288\nTotal 12288\n",
+				"blkio/blkio.throttle.io_serviced":      "8:0 Read 2\n8:0 Write 6\n8:0 Total 8\nTotal 8\n",
+			},
+			want: CgroupInfo{
+				MemoryUsage:      1048576,
+				MemoryLimit:      2097152,
+				Pids:             3,
+				CPULimit:         1.5,
+				CPUUsage:         7000,
+				Periods:          20,
+				ThrottledPeriods: 5,
+				ThrottledTime:    3000,
+				IO:               []IOStat{{Device: "8:0", ReadBytes: 4096, WriteBytes: 8192, ReadOps: 2, WriteOps: 6}},
+			},
+		},
+		{
+			// v1 reports no memory limit as the largest page counter and no
+			// cpu limit as a quota of -1
+			name: "unlimited",
+			files: map[string]string{
+				"memory/memory.usage_in_bytes":          "4096\n",
+				"memory/memory.limit_in_bytes":          "9223372036854771712\n",
+				"pids/pids.current":                     "1\n",
+				"cpu/cpu.cfs_quota_us":                  "-1\n",
+				"cpu/cpu.cfs_period_us":                 "100000\n",
+				"cpuacct/cpuacct.usage":                 "1000\n",
+				"blkio/blkio.throttle.io_service_bytes": "Total 0\n",
+			},
+			want: CgroupInfo{MemoryUsage: 4096, Pids: 1, CPUUsage: 1},
+		},
+		{
+			// a hybrid host with only some controllers mounted
+			name: "missing controllers",
+			files: map[string]string{
+				"memory/memory.usage_in_bytes": "4096\n",
+				"memory/memory.limit_in_bytes": "8192\n",
+			},
+			want: CgroupInfo{MemoryUsage: 4096, MemoryLimit: 8192, Unavailable: []string{ControllerPids, ControllerCPU, ControllerIO}},
+		},
+		{
+			name:    "missing cgroup",
+			files:   map[string]string{"unified/": ""},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			// the cgroup is "/app" in every controller hierarchy
+			files := make(map[string]string, len(tt.files))
+			for name, content := range tt.files {
+				dir, file := filepath.Split(name)
+				if file == "" {
+					files[name] = content
+					continue
+				}
+				files[filepath.Join(dir, "app", file)] = content
+			}
+			writeTree(t, root, files)
+
+			var cg CgroupInfo
+			err := cg.readV1(func(controller string) string {
+				return filepath.Join(root, controller, "app")
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("readV1() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readV1() error = %v", err)
+			}
+			if cg.IO == nil {
+				cg.IO = tt.want.IO
+			}
+			if !reflect.DeepEqual(cg, tt.want) {
+				t.Errorf("readV1() = %+v, want %+v", cg, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelfPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{
+			name:    "v2",
+			content: "0::/system.slice/sysmon.service\n",
+			want:    map[string]string{"": "/system.slice/sysmon.service"},
+		},
+		{
+			name: "hybrid",
+			content: "12:pids:/docker/0123abcd\n" +
+				"4:memory:/docker/0123abcd\n" +
+				"2:cpu,cpuacct:/docker/0123abcd\n" +
+				"1:name=systemd:/docker/0123abcd\n" +
+				"0::/docker/0123abcd\n",
+			want: map[string]string{
+				"pids":         "/docker/0123abcd",
+				"memory":       "/docker/0123abcd",
+				"cpu":          "/docker/0123abcd",
+				"cpuacct":      "/docker/0123abcd",
+				"name=systemd": "/docker/0123abcd",
+				"":             "/docker/0123abcd",
+			},
+		},
+		{
+			name:    "path with a colon",
+			content: "0::/user.slice/app:1.scope\n",
+			want:    map[string]string{"": "/user.slice/app:1.scope"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, procfs := useRoots(t)
+			writeTree(t, procfs, map[string]string{"self/cgroup": tt.content})
+
+			got, err := selfPaths()
+			if err != nil {
+				t.Fatalf("selfPaths() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selfPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectV1ContainerWithoutNamespace(t *testing.T) {
+	// /proc/self/cgroup shows the path on the host while every controller
+	// root is already the container's own cgroup
+	root, procfs := useRoots(t)
+	writeTree(t, procfs, map[string]string{
+		"self/cgroup": "4:memory:/docker/0123abcd\n2:cpu,cpuacct:/docker/0123abcd\n",
+		"partitions":  "major minor  #blocks  name\n\n   8        0  1048576 sda\n",
+	})
+	writeTree(t, root, map[string]string{
+		"memory/memory.usage_in_bytes":          "4096\n",
+		"memory/memory.limit_in_bytes":          "8192\n",
+		"cpuacct/cpuacct.usage":                 "2000\n",
+		"cpu/cpu.cfs_quota_us":                  "-1\n",
+		"blkio/blkio.throttle.io_service_bytes": "8:0 Read 10\n8:0 Write 20\n",
+	})
+
+	info, err := Collect(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if info.Version != 1 || len(info.Cgroups) != 1 {
+		t.Fatalf("Collect() = %+v, want one v1 cgroup", info)
+	}
+	self := info.Cgroups[0]
+	if self.Error != "" {
+		t.Fatalf("self error = %q, want the controller roots to be read", self.Error)
+	}
+	if self.Path != "/" || self.MemoryUsage != 4096 || self.MemoryPercent != 50 || self.CPUUsage != 2 {
+		t.Errorf("self = %+v, want / with 4096 of 8192 bytes and 2µs of cpu", self)
+	}
+	if want := []IOStat{{Device: "sda", ReadBytes: 10, WriteBytes: 20}}; !reflect.DeepEqual(self.IO, want) {
+		t.Errorf("self IO = %+v, want %+v", self.IO, want)
+	}
+}
+
+func TestCollectV2(t *testing.T) {
+	root, procfs := useRoots(t)
+	writeTree(t, procfs, map[string]string{"self/cgroup": "0::/system.slice/sysmon.service\n"})
+	writeTree(t, root, map[string]string{
+		"cgroup.controllers":                        "cpu io memory pids\n",
+		"cpu.stat":                                  "usage_usec 1\n",
+		"system.slice/sysmon.service/cpu.stat":      "usage_usec 5\n",
+		"system.slice/nginx.service/memory.current": "100\n",
+		"system.slice/nginx.service/memory.max":     "400\n",
+		"system.slice/nginx.service/cpu.stat":       "usage_usec 9\n",
+	})
+
+	info, err := Collect(context.Background(), []string{"system.slice/nginx.service", "../../etc", "missing.slice"})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if info.Version != 2 || len(info.Cgroups) != 4 {
+		t.Fatalf("Collect() = %+v, want four v2 cgroups", info)
+	}
+
+	tests := []struct {
+		name, path string
+		cpuUsage   uint64
+		memPercent float64
+		wantErr    bool
+	}{
+		{name: SelfName, path: "/system.slice/sysmon.service", cpuUsage: 5},
+		{name: "system.slice/nginx.service", path: "/system.slice/nginx.service", cpuUsage: 9, memPercent: 25},
+		// ".." cannot leave Root
+		{name: "etc", path: "/etc", wantErr: true},
+		{name: "missing.slice", path: "/missing.slice", wantErr: true},
+	}
+	for i, tt := range tests {
+		cg := info.Cgroups[i]
+		if cg.Name != tt.name || cg.Path != tt.path || (cg.Error != "") != tt.wantErr {
+			t.Errorf("cgroup %d = %s at %s with error %q, want %s at %s", i, cg.Name, cg.Path, cg.Error, tt.name, tt.path)
+			continue
+		}
+		if cg.CPUUsage != tt.cpuUsage || cg.MemoryPercent != tt.memPercent {
+			t.Errorf("%s = %+v, want %dµs of cpu and %.0f%% of memory", tt.name, cg, tt.cpuUsage, tt.memPercent)
+		}
+	}
+}
+
+func TestCollectV2SelfFallsBackToRoot(t *testing.T) {
+	root, procfs := useRoots(t)
+	writeTree(t, procfs, map[string]string{"self/cgroup": "0::/kubepods/pod1234/0123abcd\n"})
+	writeTree(t, root, map[string]string{
+		"cgroup.controllers": "cpu memory\n",
+		"memory.current":     "2048\n",
+		"memory.max":         "4096\n",
+		"cpu.stat":           "usage_usec 3\n",
+	})
+
+	info, err := Collect(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	self := info.Cgroups[0]
+	if self.Error != "" || self.Path != "/" || self.MemoryPercent != 50 {
+		t.Errorf("self = %+v, want the root cgroup at 50%% of its memory limit", self)
+	}
+}
@@ -0,0 +1,156 @@
+package cpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+// SysfsRoot is where sysfs is mounted, eg "/host/sys" when sysmon runs in a
+// container watching its host
+var SysfsRoot = "/sys"
+
+// HardwareInfo describes the processor model. It is read once and reused as
+// it does not change while sysmon runs.
+type HardwareInfo struct {
+	ModelName string   `json:"modelName"`
+	VendorID  string   `json:"vendorId"`
+	Family    string   `json:"family"`
+	Model     string   `json:"model"`
+	Stepping  int32    `json:"stepping"`
+	Microcode string   `json:"microcode"`
+	CacheSize int32    `json:"cacheSizeKb"`
+	Sockets   int      `json:"sockets"`
+	Flags     []string `json:"flags"`
+}
+
+// CoreFrequency is the clock speed of one logical core. Min and max come
+// from cpufreq on Linux and are zero where the platform does not report
+// them. ThrottleCount is the number of thermal throttling events since boot
+// and is only reported on Linux x86.
+type CoreFrequency struct {
+	CPU           string  `json:"cpu"`
+	CurrentMHz    float64 `json:"currentMhz"`
+	MinMHz        float64 `json:"minMhz"`
+	MaxMHz        float64 `json:"maxMhz"`
+	ThrottleCount uint64  `json:"throttleCount"`
+}
+
+var (
+	hardwareMu sync.Mutex
+	hardware   *HardwareInfo
+)
+
+// collectHardware fills in Hardware and Frequencies. The inventory cannot
+// be read everywhere, eg in some containers and VMs, so a failure is only
+// logged and leaves both empty rather than failing the cpu metric.
+func (c *CpuInfo) collectHardware(ctx context.Context) {
+	logging.Info(logtag, "collecting cpu hardware info")
+	infos, err := cpu.InfoWithContext(ctx)
+	if err != nil {
+		logging.Error(logtag, "error collecting cpu hardware info", err)
+		return
+	}
+	if len(infos) == 0 {
+		return
+	}
+
+	hardwareMu.Lock()
+	if hardware == nil {
+		hardware = newHardwareInfo(infos)
+	}
+	c.Hardware = hardware
+	hardwareMu.Unlock()
+
+	c.Frequencies = readFrequencies(infos)
+}
+
+func newHardwareInfo(infos []cpu.InfoStat) *HardwareInfo {
+	first := infos[0]
+	sockets := make(map[string]bool)
+	for _, info := range infos {
+		sockets[info.PhysicalID] = true
+	}
+
+	return &HardwareInfo{
+		ModelName: first.ModelName,
+		VendorID:  first.VendorID,
+		Family:    first.Family,
+		Model:     first.Model,
+		Stepping:  first.Stepping,
+		Microcode: first.Microcode,
+		CacheSize: first.CacheSize,
+		Sockets:   len(sockets),
+		Flags:     first.Flags,
+	}
+}
+
+// readFrequencies reads the cpufreq files of every core. Where cpufreq is
+// not available, eg in most VMs or on other platforms, it falls back to the
+// single speed reported by cpu.Info: the current speed on Linux and the
+// maximum speed elsewhere.
+func readFrequencies(infos []cpu.InfoStat) []CoreFrequency {
+	dirs, _ := filepath.Glob(filepath.Join(SysfsRoot, "devices/system/cpu/cpu[0-9]*"))
+	sort.Slice(dirs, func(i, j int) bool {
+		return cpuIndex(dirs[i]) < cpuIndex(dirs[j])
+	})
+
+	var results []CoreFrequency
+	for _, dir := range dirs {
+		cur, err := readKHz(filepath.Join(dir, "cpufreq/scaling_cur_freq"))
+		if err != nil {
+			continue
+		}
+		f := CoreFrequency{CPU: filepath.Base(dir), CurrentMHz: cur}
+		f.MinMHz, _ = readKHz(filepath.Join(dir, "cpufreq/cpuinfo_min_freq"))
+		f.MaxMHz, _ = readKHz(filepath.Join(dir, "cpufreq/cpuinfo_max_freq"))
+		f.ThrottleCount, _ = readUint(filepath.Join(dir, "thermal_throttle/core_throttle_count"))
+		results = append(results, f)
+	}
+	if len(results) > 0 {
+		return results
+	}
+
+	results = make([]CoreFrequency, 0, len(infos))
+	for _, info := range infos {
+		f := CoreFrequency{CPU: fmt.Sprintf("cpu%d", info.CPU)}
+		if runtime.GOOS == "linux" {
+			f.CurrentMHz = info.Mhz
+		} else {
+			f.MaxMHz = info.Mhz
+		}
+		results = append(results, f)
+	}
+	return results
+}
+
+func cpuIndex(dir string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "cpu"))
+	return i
+}
+
+// readKHz reads a cpufreq file, which holds a frequency in kHz, as MHz
+func readKHz(path string) (float64, error) {
+	v, err := readUint(path)
+	if err != nil {
+		return 0, err
+	}
+	return float64(v) / 1000, nil
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -1,37 +1,49 @@
 package cpu
 
 import (
+	"context"
 	"time"
 
 	"github.com/shirou/gopsutil/v4/cpu"
-	"github.com/shirou/gopsutil/v4/process"
 	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
 )
 
 const logtag string = "cpu"
 
 type CpuInfo struct {
-	PhysicalCores      int
-	LogicalCores       int
-	Percentages        []float64
-	AveragePercentages float64
-	Processes          []ProcessInfo
+	PhysicalCores      int             `json:"physicalCores"`
+	LogicalCores       int             `json:"logicalCores"`
+	Hardware           *HardwareInfo   `json:"hardware,omitempty"`
+	Frequencies        []CoreFrequency `json:"frequencies"`
+	Percentages        []float64       `json:"percentages"`
+	AveragePercentages float64         `json:"averagePercentages"`
+	TotalTimes         CpuTimes        `json:"totalTimes"`
+	CoreTimes          []CpuTimes      `json:"coreTimes"`
+	TimesWindow        float64         `json:"timesWindowSeconds"` // time covered by TotalTimes and CoreTimes
+	Processes          []ProcessInfo   `json:"processes"`
 }
 
 type ProcessInfo struct {
-	Pid          int
-	CpuPercent   float64
-	ChildrenPids []int32
-	ParentPid    int32
-	IsChild      bool
-	IsRunning    bool
-	ProcessName  string
-	NumThreads   int32
-	Username     string
+	Pid          int     `json:"pid"`
+	CpuPercent   float64 `json:"cpuPercent"`
+	ChildrenPids []int32 `json:"childrenPids"`
+	ParentPid    int32   `json:"parentPid"`
+	IsChild      bool    `json:"isChild"`
+	IsRunning    bool    `json:"isRunning"`
+	ProcessName  string  `json:"processName"`
+	NumThreads   int32   `json:"numThreads"`
+	Username     string  `json:"username"`
 }
 
-func (c *CpuInfo) Collect() error {
-	all_processes, err := getProcesses()
+func (c *CpuInfo) Collect(ctx context.Context) error {
+	start, err := readTimes(ctx)
+	if err != nil {
+		logging.Error(logtag, "error collecting cpu times", err)
+		return err
+	}
+
+	all_processes, err := Processes(ctx, proctable.ListFilter)
 
 	if err != nil {
 		logging.Error(logtag, "error instantiating processes", err)
@@ -39,25 +51,34 @@ func (c *CpuInfo) Collect() error {
 	}
 	c.Processes = all_processes
 
-	c.collectCores()
-	c.collectPercentages(0)
+	// a failed part would otherwise be reported as zero readings
+	if err := c.collectCores(ctx); err != nil {
+		return err
+	}
+	c.collectHardware(ctx)
+	if err := c.collectPercentages(ctx, 0); err != nil {
+		return err
+	}
+	if err := c.collectTimes(ctx, start); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-func (c *CpuInfo) collectCores() error {
+func (c *CpuInfo) collectCores(ctx context.Context) error {
 	logging.Info(logtag, "collecting cpu cores")
-	physical_core_count, err := cpu.Counts(false)
+	physical_core_count, err := cpu.CountsWithContext(ctx, false)
 
 	if err != nil {
 		logging.Error(logtag, "error retrieving cpu cores", err)
 		return err
 	}
 
-	logical_core_count, err := cpu.Counts(true)
+	logical_core_count, err := cpu.CountsWithContext(ctx, true)
 
 	if err != nil {
-
+		logging.Error(logtag, "error retrieving logical cpu cores", err)
 		return err
 	}
 
@@ -68,8 +89,8 @@ func (c *CpuInfo) collectCores() error {
 
 }
 
-func (c *CpuInfo) collectPercentages(interval time.Duration) error {
-	percentages, err := cpu.Percent(interval, true)
+func (c *CpuInfo) collectPercentages(ctx context.Context, interval time.Duration) error {
+	percentages, err := cpu.PercentWithContext(ctx, interval, true)
 
 	if err != nil {
 		logging.Error(logtag, "error collecting percentages", err)
@@ -91,57 +112,31 @@ func (c *CpuInfo) collectPercentages(interval time.Duration) error {
 	return nil
 }
 
-func getProcesses() ([]ProcessInfo, error) {
-	procs, err := process.Processes()
+// Processes returns the processes matching filter, busiest first
+func Processes(ctx context.Context, filter proctable.Filter) ([]ProcessInfo, error) {
+	table, err := proctable.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	processes, err := filter.Apply(table.Processes, proctable.SortCPU)
 	if err != nil {
 		return nil, err
 	}
-	time.Sleep(500 * time.Millisecond)
-
-	results := make([]ProcessInfo, 0, len(procs))
-
-	for _, proc := range procs {
-		running, err := proc.IsRunning()
-		if err != nil || !running {
-			continue
-		}
-
-		cpuusage, err := proc.CPUPercent()
-		if err != nil || cpuusage <= 0.9 {
-			continue
-		}
-
-		p := ProcessInfo{
-			Pid:        int(proc.Pid),
-			CpuPercent: cpuusage,
-			IsRunning:  true,
-		}
-
-		if threads, err := proc.NumThreads(); err == nil {
-			p.NumThreads = threads
-		}
-
-		if name, err := proc.Name(); err == nil {
-			p.ProcessName = name
-		}
-
-		if user, err := proc.Username(); err == nil {
-			p.Username = user
-		}
-
-		if ppid, err := proc.Ppid(); err == nil {
-			p.ParentPid = ppid
-			p.IsChild = ppid != 0
-		}
-
-		if children, err := proc.Children(); err == nil {
-			p.ChildrenPids = make([]int32, 0, len(children))
-			for _, child := range children {
-				p.ChildrenPids = append(p.ChildrenPids, child.Pid)
-			}
-		}
-
-		results = append(results, p)
+
+	results := make([]ProcessInfo, 0, len(processes))
+	for _, proc := range processes {
+		results = append(results, ProcessInfo{
+			Pid:          int(proc.Pid),
+			CpuPercent:   proc.CPUPercent,
+			ChildrenPids: proc.ChildrenPids,
+			ParentPid:    proc.ParentPid,
+			IsChild:      proc.ParentPid != 0,
+			IsRunning:    true,
+			ProcessName:  proc.Name,
+			NumThreads:   proc.NumThreads,
+			Username:     proc.Username,
+		})
 	}
 
 	return results, nil
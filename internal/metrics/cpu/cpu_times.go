@@ -0,0 +1,135 @@
+package cpu
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+// CpuTimes splits the time of one core, or of all cores for "cpu-total", by
+// mode. Values are percentages of the time elapsed between two readings.
+// Guest time is also counted in User and Nice, as reported by the kernel.
+type CpuTimes struct {
+	CPU       string  `json:"cpu"`
+	User      float64 `json:"user"`
+	System    float64 `json:"system"`
+	Idle      float64 `json:"idle"`
+	Nice      float64 `json:"nice"`
+	Iowait    float64 `json:"iowait"`
+	Irq       float64 `json:"irq"`
+	Softirq   float64 `json:"softirq"`
+	Steal     float64 `json:"steal"`
+	Guest     float64 `json:"guest"`
+	GuestNice float64 `json:"guestNice"`
+}
+
+// timesReading is a snapshot of the cumulative cpu times
+type timesReading struct {
+	at    time.Time
+	total cpu.TimesStat
+	cores []cpu.TimesStat
+}
+
+// lastReading is kept between collections so the breakdown covers the whole
+// time since the previous collection rather than a short sampling window
+var (
+	lastReadingMu sync.Mutex
+	lastReading   *timesReading
+)
+
+func readTimes(ctx context.Context) (*timesReading, error) {
+	total, err := cpu.TimesWithContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	cores, err := cpu.TimesWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	reading := &timesReading{at: time.Now(), cores: cores}
+	if len(total) > 0 {
+		reading.total = total[0]
+	}
+	return reading, nil
+}
+
+// collectTimes fills in the breakdown since the previous collection, or
+// since start for the first collection
+func (c *CpuInfo) collectTimes(ctx context.Context, start *timesReading) error {
+	logging.Info(logtag, "collecting cpu times")
+	end, err := readTimes(ctx)
+	if err != nil {
+		logging.Error(logtag, "error collecting cpu times", err)
+		return err
+	}
+
+	lastReadingMu.Lock()
+	base := lastReading
+	lastReading = end
+	lastReadingMu.Unlock()
+
+	if base == nil || !base.at.Before(start.at) {
+		base = start
+	}
+
+	c.TimesWindow = end.at.Sub(base.at).Seconds()
+	c.TotalTimes = timesDelta(base.total, end.total)
+
+	previous := make(map[string]cpu.TimesStat, len(base.cores))
+	for _, t := range base.cores {
+		previous[t.CPU] = t
+	}
+	c.CoreTimes = make([]CpuTimes, 0, len(end.cores))
+	for _, t := range end.cores {
+		// cores brought online since the previous reading have no baseline
+		if p, ok := previous[t.CPU]; ok {
+			c.CoreTimes = append(c.CoreTimes, timesDelta(p, t))
+		}
+	}
+	return nil
+}
+
+// timesDelta converts the difference between two readings into percentages
+func timesDelta(before, after cpu.TimesStat) CpuTimes {
+	d := func(a, b float64) float64 {
+		if a < b {
+			return 0
+		}
+		return a - b
+	}
+
+	user := d(after.User, before.User)
+	system := d(after.System, before.System)
+	idle := d(after.Idle, before.Idle)
+	nice := d(after.Nice, before.Nice)
+	iowait := d(after.Iowait, before.Iowait)
+	irq := d(after.Irq, before.Irq)
+	softirq := d(after.Softirq, before.Softirq)
+	steal := d(after.Steal, before.Steal)
+	guest := d(after.Guest, before.Guest)
+	guestNice := d(after.GuestNice, before.GuestNice)
+
+	times := CpuTimes{CPU: after.CPU}
+	// guest time is already part of user and nice
+	total := user + system + idle + nice + iowait + irq + softirq + steal
+	if total <= 0 {
+		return times
+	}
+
+	pct := func(v float64) float64 { return v / total * 100 }
+	times.User = pct(user)
+	times.System = pct(system)
+	times.Idle = pct(idle)
+	times.Nice = pct(nice)
+	times.Iowait = pct(iowait)
+	times.Irq = pct(irq)
+	times.Softirq = pct(softirq)
+	times.Steal = pct(steal)
+	times.Guest = pct(guest)
+	times.GuestNice = pct(guestNice)
+	return times
+}
@@ -1,6 +1,8 @@
 package disk
 
 import (
+	"context"
+
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/techtacles/sysmonitoring/internal/logging"
 )
@@ -8,27 +10,27 @@ import (
 const logtag string = "disk"
 
 type DiskInfo struct {
-	PartitionInfo []Partitions
-	UsageStat     map[string]UsagePerPath
+	PartitionInfo []Partitions            `json:"partitionInfo"`
+	UsageStat     map[string]UsagePerPath `json:"usageStat"`
 }
 
 type Partitions struct {
-	Device     string
-	MountPoint string
-	Fstype     string
-	Opts       []string
+	Device     string   `json:"device"`
+	MountPoint string   `json:"mountPoint"`
+	Fstype     string   `json:"fstype"`
+	Opts       []string `json:"opts"`
 }
 
 type UsagePerPath struct {
-	Path        string
-	TotalDisk   uint64
-	FreeDisk    uint64
-	UsedDisk    uint64
-	UsedPercent float64
+	Path        string  `json:"path"`
+	TotalDisk   uint64  `json:"totalDisk"`
+	FreeDisk    uint64  `json:"freeDisk"`
+	UsedDisk    uint64  `json:"usedDisk"`
+	UsedPercent float64 `json:"usedPercent"`
 }
 
-func (d *DiskInfo) Collect() error {
-	part, err := getPartitions()
+func (d *DiskInfo) Collect(ctx context.Context) error {
+	part, err := getPartitions(ctx)
 	if err != nil {
 		logging.Error(logtag, "error instantiating partitions ", err)
 		return err
@@ -36,7 +38,7 @@ func (d *DiskInfo) Collect() error {
 
 	d.PartitionInfo = part
 
-	usage_path, err := extractDiskInfo()
+	usage_path, err := extractDiskInfo(ctx)
 
 	if err != nil {
 		logging.Error(logtag, "error instantiating usage path metrics", err)
@@ -50,9 +52,9 @@ func (d *DiskInfo) Collect() error {
 
 }
 
-func getPartitions() ([]Partitions, error) {
+func getPartitions(ctx context.Context) ([]Partitions, error) {
 
-	partition_stat, err := disk.Partitions(true)
+	partition_stat, err := disk.PartitionsWithContext(ctx, true)
 
 	if err != nil {
 		logging.Error(logtag, "error getting disk partitions", err)
@@ -77,8 +79,8 @@ func getPartitions() ([]Partitions, error) {
 
 }
 
-func extractDiskInfo() (map[string]UsagePerPath, error) {
-	disk_info, err := getPartitions()
+func extractDiskInfo(ctx context.Context) (map[string]UsagePerPath, error) {
+	disk_info, err := getPartitions(ctx)
 
 	if err != nil {
 		logging.Error(logtag, "error extracting disk partitions while extracting disk info", err)
@@ -87,7 +89,13 @@ func extractDiskInfo() (map[string]UsagePerPath, error) {
 	results := make(map[string]UsagePerPath, len(disk_info))
 
 	for _, v := range disk_info {
-		usage_stat, err := disk.Usage(v.MountPoint)
+		// statfs on a stale mount cannot be interrupted, so stop before
+		// starting the next one once the caller has given up
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		usage_stat, err := disk.UsageWithContext(ctx, v.MountPoint)
 
 		if err != nil {
 			logging.Error(logtag, "error getting usage stat", err)
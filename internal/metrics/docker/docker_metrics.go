@@ -15,85 +15,91 @@ const logtag = "docker"
 var prevCPUStats = make(map[string]container.StatsResponse)
 
 type DockerInfo struct {
-	DockerEnv                    string // eg docker-desktop
-	ContainersRunning            int
-	ContainersPaused             int
-	ContainersStopped            int
-	NCpu                         int
-	MemTotal                     int64
-	ContainersDiskUsage          int64
-	ImagesDiskUsage              int64
-	BuildCacheDiskUsage          int64
-	PlatformName                 string
-	APIVersion                   string
-	OS                           string
-	Arch                         string
-	TotalImages                  int
-	TotalContainers              int
-	TotalVolumes                 int
-	ContainerCpuMemoryCollection map[string]ContainerMetrics
-	ContainerStats               []Containers
-	ImageStats                   []Images
-	VolumeStats                  []Volumes
+	DockerEnv                    string                      `json:"dockerEnv"` // eg docker-desktop
+	ContainersRunning            int                         `json:"containersRunning"`
+	ContainersPaused             int                         `json:"containersPaused"`
+	ContainersStopped            int                         `json:"containersStopped"`
+	NCpu                         int                         `json:"nCpu"`
+	MemTotal                     int64                       `json:"memTotal"`
+	ContainersDiskUsage          int64                       `json:"containersDiskUsage"`
+	ImagesDiskUsage              int64                       `json:"imagesDiskUsage"`
+	BuildCacheDiskUsage          int64                       `json:"buildCacheDiskUsage"`
+	PlatformName                 string                      `json:"platformName"`
+	APIVersion                   string                      `json:"apiVersion"`
+	OS                           string                      `json:"os"`
+	Arch                         string                      `json:"arch"`
+	TotalImages                  int                         `json:"totalImages"`
+	TotalContainers              int                         `json:"totalContainers"`
+	TotalVolumes                 int                         `json:"totalVolumes"`
+	ContainerCpuMemoryCollection map[string]ContainerMetrics `json:"containerCpuMemoryCollection"`
+	ContainerStats               []Containers                `json:"containerStats"`
+	ImageStats                   []Images                    `json:"imageStats"`
+	VolumeStats                  []Volumes                   `json:"volumeStats"`
 }
 
 type Containers struct {
-	ID                       string
-	ImageName                string
-	ContainerNames           []string
-	ContainerPorts           []container.PortSummary
-	ContainerState           container.ContainerState
-	ContainerRootSizeInBytes int64
+	ID                       string                   `json:"id"`
+	ImageName                string                   `json:"imageName"`
+	ContainerNames           []string                 `json:"containerNames"`
+	ContainerPorts           []container.PortSummary  `json:"containerPorts"`
+	ContainerState           container.ContainerState `json:"containerState"`
+	ContainerRootSizeInBytes int64                    `json:"containerRootSizeInBytes"`
 }
 
 type ContainerMetrics struct {
-	CPUTime       uint64
-	CPUPercentage float64
-	Memory        uint64
-	Name          string
-	ReadSize      uint64
-	WriteSize     uint64
+	CPUTime       uint64  `json:"cpuTime"`
+	CPUPercentage float64 `json:"cpuPercentage"`
+	Memory        uint64  `json:"memory"`
+	Name          string  `json:"name"`
+	ReadSize      uint64  `json:"readSize"`
+	WriteSize     uint64  `json:"writeSize"`
 }
 
 type Images struct {
-	ID                               string
-	ImageNames                       []string
-	CreatedDate                      string
-	NumberOfContainersUsingThisImage int64
-	ImageSize                        int64
+	ID                               string   `json:"id"`
+	ImageNames                       []string `json:"imageNames"`
+	CreatedDate                      string   `json:"createdDate"`
+	NumberOfContainersUsingThisImage int64    `json:"numberOfContainersUsingThisImage"`
+	ImageSize                        int64    `json:"imageSize"`
 }
 
 type Volumes struct {
-	VolumeName string
-	MountPoint string
-	Scope      string
-	Driver     string
-	VolumeSize int64
-	Created    string
+	VolumeName string `json:"volumeName"`
+	MountPoint string `json:"mountPoint"`
+	Scope      string `json:"scope"`
+	Driver     string `json:"driver"`
+	VolumeSize int64  `json:"volumeSize"`
+	Created    string `json:"created"`
 }
 
-func (d *DockerInfo) Collect() error {
-	d.getSystemInfo()
-	d.getContainerDiskUsage()
-	d.getPlatformInfo()
-	container_stats, err := listAllContainers()
+func (d *DockerInfo) Collect(ctx context.Context) error {
+	if err := d.getSystemInfo(ctx); err != nil {
+		return err
+	}
+	if err := d.getContainerDiskUsage(ctx); err != nil {
+		return err
+	}
+	if err := d.getPlatformInfo(ctx); err != nil {
+		return err
+	}
+	container_stats, err := listAllContainers(ctx)
 	if err != nil {
 		return err
 	}
 	d.ContainerStats = container_stats
 
-	image_stats, err := listAllImages()
+	image_stats, err := listAllImages(ctx)
 	if err != nil {
 		return err
 	}
 	d.ImageStats = image_stats
 
-	volume_stats, err := listAllVolumes()
+	volume_stats, err := listAllVolumes(ctx)
 	if err != nil {
 		return err
 	}
 
-	container_collection, err := getContainerCollectionMetrics()
+	container_collection, err := getContainerCollectionMetrics(ctx)
 	if err != nil {
 		return err
 	}
@@ -107,18 +113,11 @@ func (d *DockerInfo) Collect() error {
 	return nil
 }
 
-func getContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 10*time.Second)
-}
-
 func getClient() (*client.Client, error) {
 	return client.New(client.FromEnv)
 }
 
-func listAllContainers() ([]Containers, error) {
-	ctx, cancel := getContext()
-	defer cancel()
-
+func listAllContainers(ctx context.Context) ([]Containers, error) {
 	api_client, err := getClient()
 	if err != nil {
 		logging.Error(logtag, "failed to create docker client", err)
@@ -151,17 +150,13 @@ func listAllContainers() ([]Containers, error) {
 	return list_of_containers, nil
 }
 
-func listAllImages() ([]Images, error) {
-	ctx, cancel := getContext()
-	defer cancel()
-
+func listAllImages(ctx context.Context) ([]Images, error) {
 	api_client, err := getClient()
-	defer api_client.Close()
-
 	if err != nil {
 		logging.Error(logtag, "failed to create docker client", err)
 		return nil, err
 	}
+	defer api_client.Close()
 
 	image_list_result, err := api_client.ImageList(ctx, client.ImageListOptions{All: true})
 	if err != nil {
@@ -217,18 +212,14 @@ func calculateCPUPercent(
 	return 0.0
 }
 
-func getContainerCollectionMetrics() (map[string]ContainerMetrics, error) {
-	ctx, cancel := getContext()
-	defer cancel()
-
+func getContainerCollectionMetrics(ctx context.Context) (map[string]ContainerMetrics, error) {
 	api_client, err := getClient()
-	defer api_client.Close()
-
 	if err != nil {
 		logging.Error(logtag, "failed to create docker client", err)
 		return nil, err
 	}
-	all_containers, err := listAllContainers()
+	defer api_client.Close()
+	all_containers, err := listAllContainers(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -272,17 +263,13 @@ func getContainerCollectionMetrics() (map[string]ContainerMetrics, error) {
 
 }
 
-func listAllVolumes() ([]Volumes, error) {
-	ctx, cancel := getContext()
-	defer cancel()
-
+func listAllVolumes(ctx context.Context) ([]Volumes, error) {
 	api_client, err := getClient()
-	defer api_client.Close()
-
 	if err != nil {
 		logging.Error(logtag, "failed to create docker client", err)
 		return nil, err
 	}
+	defer api_client.Close()
 
 	volume_list_result, err := api_client.VolumeList(ctx, client.VolumeListOptions{})
 	if err != nil {
@@ -307,17 +294,13 @@ func listAllVolumes() ([]Volumes, error) {
 
 }
 
-func (d *DockerInfo) getContainerDiskUsage() error {
-	ctx, cancel := getContext()
-	defer cancel()
-
+func (d *DockerInfo) getContainerDiskUsage(ctx context.Context) error {
 	api_client, err := getClient()
-	defer api_client.Close()
-
 	if err != nil {
 		logging.Error(logtag, "failed to create docker client", err)
 		return err
 	}
+	defer api_client.Close()
 
 	disk_usage_result, err := api_client.DiskUsage(ctx, client.DiskUsageOptions{
 		Containers: true,
@@ -325,29 +308,24 @@ func (d *DockerInfo) getContainerDiskUsage() error {
 		BuildCache: true,
 		Volumes:    true,
 	})
-
-	d.ContainersDiskUsage = disk_usage_result.Containers.TotalSize
-	d.ImagesDiskUsage = disk_usage_result.Images.TotalSize
-	d.BuildCacheDiskUsage = disk_usage_result.BuildCache.TotalSize
-
 	if err != nil {
 		logging.Error(logtag, "unable to calculate disk usage", err)
 		return err
 	}
+
+	d.ContainersDiskUsage = disk_usage_result.Containers.TotalSize
+	d.ImagesDiskUsage = disk_usage_result.Images.TotalSize
+	d.BuildCacheDiskUsage = disk_usage_result.BuildCache.TotalSize
 	return nil
 }
 
-func (d *DockerInfo) getSystemInfo() error {
-	ctx, cancel := getContext()
-	defer cancel()
-
+func (d *DockerInfo) getSystemInfo(ctx context.Context) error {
 	api_client, err := getClient()
-	defer api_client.Close()
-
 	if err != nil {
 		logging.Error(logtag, "failed to create docker client", err)
 		return err
 	}
+	defer api_client.Close()
 
 	system_info, err := api_client.Info(ctx, client.InfoOptions{})
 	if err != nil {
@@ -364,17 +342,13 @@ func (d *DockerInfo) getSystemInfo() error {
 	return nil
 }
 
-func (d *DockerInfo) getPlatformInfo() error {
-	ctx, cancel := getContext()
-	defer cancel()
-
+func (d *DockerInfo) getPlatformInfo(ctx context.Context) error {
 	api_client, err := getClient()
-	defer api_client.Close()
-
 	if err != nil {
 		logging.Error(logtag, "failed to create docker client", err)
 		return err
 	}
+	defer api_client.Close()
 
 	server_version, err := api_client.ServerVersion(ctx, client.ServerVersionOptions{})
 	if err != nil {
@@ -1,6 +1,8 @@
 package host
 
 import (
+	"context"
+
 	"github.com/shirou/gopsutil/v4/host"
 	"github.com/shirou/gopsutil/v4/load"
 	"github.com/techtacles/sysmonitoring/internal/logging"
@@ -9,25 +11,25 @@ import (
 const logtag string = "host"
 
 type HostInfo struct {
-	Uptime        uint64
-	BootTime      uint64
-	LoadAvg1      float64
-	LoadAvg5      float64
-	LoadAvg15     float64
-	OS            string
-	Platform      string
-	PlatformVer   string
-	KernelVersion string
+	Uptime        uint64  `json:"uptime"`
+	BootTime      uint64  `json:"bootTime"`
+	LoadAvg1      float64 `json:"loadAvg1"`
+	LoadAvg5      float64 `json:"loadAvg5"`
+	LoadAvg15     float64 `json:"loadAvg15"`
+	OS            string  `json:"os"`
+	Platform      string  `json:"platform"`
+	PlatformVer   string  `json:"platformVer"`
+	KernelVersion string  `json:"kernelVersion"`
 }
 
-func (h *HostInfo) Collect() error {
-	hostStat, err := host.Info()
+func (h *HostInfo) Collect(ctx context.Context) error {
+	hostStat, err := host.InfoWithContext(ctx)
 	if err != nil {
 		logging.Error(logtag, "error getting host info", err)
 		return err
 	}
 
-	loadStat, err := load.Avg()
+	loadStat, err := load.AvgWithContext(ctx)
 	if err != nil {
 		logging.Error(logtag, "error getting load average", err)
 		return err
@@ -0,0 +1,226 @@
+package kernel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+const logtag string = "kernel"
+
+// ProcfsRoot is where procfs is mounted, eg "/host/proc" when sysmon runs in
+// a container watching its host, or a directory of fixture files
+var ProcfsRoot = "/proc"
+
+// KernelInfo holds the kernel activity counters of /proc/stat,
+// /proc/interrupts and /proc/softirqs. The counters are cumulative since
+// boot; the aggregator turns them into per-second rates.
+type KernelInfo struct {
+	ContextSwitches uint64    `json:"contextSwitches"`
+	Forks           uint64    `json:"forks"`
+	Interrupts      uint64    `json:"interrupts"`
+	SoftIRQs        uint64    `json:"softirqs"`
+	ProcsRunning    uint64    `json:"procsRunning"`
+	ProcsBlocked    uint64    `json:"procsBlocked"` // waiting for I/O
+	IRQs            []IRQ     `json:"irqs"`
+	SoftIRQTypes    []IRQ     `json:"softirqTypes"`
+	CPUs            []CPUIRQs `json:"cpus"` // interrupt distribution across cpus
+}
+
+// IRQ is one line of /proc/interrupts or /proc/softirqs. PerCPU is empty for
+// counters the kernel does not split by cpu, such as ERR.
+type IRQ struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"` // controller and device, eg "IO-APIC 1-edge i8042"
+	Total       uint64   `json:"total"`
+	PerCPU      []uint64 `json:"perCpu,omitempty"`
+}
+
+// CPUIRQs is the number of interrupts and softirqs handled by one cpu
+type CPUIRQs struct {
+	CPU        string `json:"cpu"`
+	Interrupts uint64 `json:"interrupts"`
+	SoftIRQs   uint64 `json:"softirqs"`
+}
+
+// Available reports whether the kernel counters can be read, ie on Linux
+func Available() bool {
+	_, err := os.Stat(filepath.Join(ProcfsRoot, "stat"))
+	return err == nil
+}
+
+func (k *KernelInfo) Collect(ctx context.Context) error {
+	logging.Info(logtag, "collecting kernel activity counters")
+	if err := k.readStat(); err != nil {
+		logging.Error(logtag, "error reading /proc/stat", err)
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	cpus, irqs, err := readIRQs(filepath.Join(ProcfsRoot, "interrupts"))
+	if err != nil {
+		logging.Error(logtag, "error reading /proc/interrupts", err)
+		return err
+	}
+	k.IRQs = irqs
+	k.CPUs = make([]CPUIRQs, len(cpus))
+	for i, name := range cpus {
+		k.CPUs[i].CPU = name
+	}
+	for _, irq := range irqs {
+		for i, count := range irq.PerCPU {
+			k.CPUs[i].Interrupts += count
+		}
+	}
+
+	// /proc/softirqs lists the same cpus as /proc/interrupts, unless one
+	// went on or offline in between
+	softCPUs, softirqs, err := readIRQs(filepath.Join(ProcfsRoot, "softirqs"))
+	if err != nil {
+		logging.Error(logtag, "error reading /proc/softirqs", err)
+		return err
+	}
+	k.SoftIRQTypes = softirqs
+	if len(softCPUs) == len(cpus) {
+		for _, irq := range softirqs {
+			for i, count := range irq.PerCPU {
+				k.CPUs[i].SoftIRQs += count
+			}
+		}
+	}
+
+	logging.Info(logtag, "successfully collected kernel activity counters")
+	return nil
+}
+
+// Counters exposes the cumulative counters so the aggregator publishes them
+// as rates, eg "contextSwitches", "irq/24", "softirq/NET_RX" and
+// "cpu0/interrupts"
+func (k *KernelInfo) Counters() map[string]uint64 {
+	counters := map[string]uint64{
+		"contextSwitches": k.ContextSwitches,
+		"forks":           k.Forks,
+		"interrupts":      k.Interrupts,
+		"softirqs":        k.SoftIRQs,
+	}
+	for _, irq := range k.IRQs {
+		counters["irq/"+irq.Name] = irq.Total
+	}
+	for _, irq := range k.SoftIRQTypes {
+		counters["softirq/"+irq.Name] = irq.Total
+	}
+	for _, c := range k.CPUs {
+		counters[c.CPU+"/interrupts"] = c.Interrupts
+		counters[c.CPU+"/softirqs"] = c.SoftIRQs
+	}
+	return counters
+}
+
+// readStat reads the system wide counters of /proc/stat, eg
+//
+//	intr 1651278 0 0 ...
+//	ctxt 3483752
+//	processes 32883
+//	procs_running 2
+//	procs_blocked 0
+//	softirq 306786 0 139384 ...
+func (k *KernelInfo) readStat() error {
+	data, err := os.ReadFile(filepath.Join(ProcfsRoot, "stat"))
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// the intr line lists every irq number and can exceed the default
+	// buffer on large machines
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		var target *uint64
+		switch fields[0] {
+		case "intr":
+			target = &k.Interrupts
+		case "ctxt":
+			target = &k.ContextSwitches
+		case "processes":
+			target = &k.Forks
+		case "procs_running":
+			target = &k.ProcsRunning
+		case "procs_blocked":
+			target = &k.ProcsBlocked
+		case "softirq":
+			target = &k.SoftIRQs
+		default:
+			continue
+		}
+		if *target, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return fmt.Errorf("malformed %s line: %w", fields[0], err)
+		}
+	}
+	return scanner.Err()
+}
+
+// readIRQs parses /proc/interrupts or /proc/softirqs: a header naming the
+// cpus, then one line per interrupt with a count per cpu and, for
+// /proc/interrupts, a description
+//
+//	           CPU0       CPU1
+//	  0:         44          0   IO-APIC   2-edge      timer
+//	NMI:          0          0   Non-maskable interrupts
+//	ERR:          0
+func readIRQs(path string) ([]string, []IRQ, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	if !scanner.Scan() {
+		return nil, nil, fmt.Errorf("%s is empty", path)
+	}
+	var cpus []string
+	for _, field := range strings.Fields(scanner.Text()) {
+		cpus = append(cpus, strings.ToLower(field))
+	}
+
+	var irqs []IRQ
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		irq := IRQ{Name: strings.TrimSuffix(fields[0], ":")}
+		counts := fields[1:]
+		var perCPU []uint64
+		for len(perCPU) < len(cpus) && len(counts) > 0 {
+			count, err := strconv.ParseUint(counts[0], 10, 64)
+			if err != nil {
+				break
+			}
+			perCPU = append(perCPU, count)
+			irq.Total += count
+			counts = counts[1:]
+		}
+		if len(perCPU) == len(cpus) {
+			irq.PerCPU = perCPU
+		}
+		irq.Description = strings.Join(counts, " ")
+		irqs = append(irqs, irq)
+	}
+	return cpus, irqs, scanner.Err()
+}
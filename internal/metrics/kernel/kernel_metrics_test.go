@@ -0,0 +1,182 @@
+package kernel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadIRQs(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantCPUs []string
+		wantIRQs []IRQ
+		wantErr  bool
+	}{
+		{
+			name: "interrupts",
+			content: "           CPU0       CPU1       \n" +
+				"  0:         44          0   IO-APIC   2-edge      timer\n" +
+				"  1:          9          3   IO-APIC   1-edge      i8042\n" +
+				"NMI:          1          2   Non-maskable interrupts\n" +
+				"ERR:          0\n" +
+				"MIS:          5\n",
+			wantCPUs: []string{"cpu0", "cpu1"},
+			wantIRQs: []IRQ{
+				{Name: "0", Description: "IO-APIC 2-edge timer", Total: 44, PerCPU: []uint64{44, 0}},
+				{Name: "1", Description: "IO-APIC 1-edge i8042", Total: 12, PerCPU: []uint64{9, 3}},
+				{Name: "NMI", Description: "Non-maskable interrupts", Total: 3, PerCPU: []uint64{1, 2}},
+				{Name: "ERR", Total: 0},
+				{Name: "MIS", Total: 5},
+			},
+		},
+		{
+			name: "softirqs",
+			content: "                    CPU0       CPU1\n" +
+				"          HI:          1          0\n" +
+				"      NET_RX:        120         80\n",
+			wantCPUs: []string{"cpu0", "cpu1"},
+			wantIRQs: []IRQ{
+				{Name: "HI", Total: 1, PerCPU: []uint64{1, 0}},
+				{Name: "NET_RX", Total: 200, PerCPU: []uint64{120, 80}},
+			},
+		},
+		{
+			// offline cpus are left out of the header, so the columns are
+			// named after the cpus that are online
+			name: "offline cpu",
+			content: "           CPU0       CPU2\n" +
+				" 24:         10         20   PCI-MSI 65536-edge      nvme0q0\n",
+			wantCPUs: []string{"cpu0", "cpu2"},
+			wantIRQs: []IRQ{
+				{Name: "24", Description: "PCI-MSI 65536-edge nvme0q0", Total: 30, PerCPU: []uint64{10, 20}},
+			},
+		},
+		{
+			name:    "empty",
+			content: "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "interrupts")
+			writeFile(t, path, tt.content)
+
+			cpus, irqs, err := readIRQs(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("readIRQs() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readIRQs() error = %v", err)
+			}
+			if !reflect.DeepEqual(cpus, tt.wantCPUs) {
+				t.Errorf("cpus = %v, want %v", cpus, tt.wantCPUs)
+			}
+			if !reflect.DeepEqual(irqs, tt.wantIRQs) {
+				t.Errorf("irqs = %+v, want %+v", irqs, tt.wantIRQs)
+			}
+		})
+	}
+}
+
+func TestReadStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    KernelInfo
+		wantErr bool
+	}{
+		{
+			name: "stat",
+			content: "cpu  10 0 20 300 0 0 0 0 0 0\n" +
+				"cpu0 10 0 20 300 0 0 0 0 0 0\n" +
+				"intr 1651278 44 12 0 0\n" +
+				"ctxt 3483752\n" +
+				"btime 1735732800\n" +
+				"processes 32883\n" +
+				"procs_running 2\n" +
+				"procs_blocked 1\n" +
+				"softirq 306786 1 139384 0 0\n",
+			want: KernelInfo{
+				ContextSwitches: 3483752,
+				Forks:           32883,
+				Interrupts:      1651278,
+				SoftIRQs:        306786,
+				ProcsRunning:    2,
+				ProcsBlocked:    1,
+			},
+		},
+		{
+			name:    "malformed counter",
+			content: "ctxt many\n",
+			wantErr: true,
+		},
+	}
+
+	defer func(old string) { ProcfsRoot = old }(ProcfsRoot)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ProcfsRoot = t.TempDir()
+			writeFile(t, filepath.Join(ProcfsRoot, "stat"), tt.content)
+
+			var k KernelInfo
+			err := k.readStat()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("readStat() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readStat() error = %v", err)
+			}
+			if !reflect.DeepEqual(k, tt.want) {
+				t.Errorf("readStat() = %+v, want %+v", k, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	defer func(old string) { ProcfsRoot = old }(ProcfsRoot)
+	ProcfsRoot = t.TempDir()
+	writeFile(t, filepath.Join(ProcfsRoot, "stat"), "ctxt 100\nprocesses 7\n")
+	writeFile(t, filepath.Join(ProcfsRoot, "interrupts"),
+		"           CPU0       CPU1\n"+
+			"  0:         44          6   IO-APIC   2-edge      timer\n"+
+			"ERR:          0\n")
+	writeFile(t, filepath.Join(ProcfsRoot, "softirqs"),
+		"                    CPU0       CPU1\n"+
+			"       TIMER:         30         10\n")
+
+	var k KernelInfo
+	if err := k.Collect(context.Background()); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	want := []CPUIRQs{{CPU: "cpu0", Interrupts: 44, SoftIRQs: 30}, {CPU: "cpu1", Interrupts: 6, SoftIRQs: 10}}
+	if !reflect.DeepEqual(k.CPUs, want) {
+		t.Errorf("CPUs = %+v, want %+v", k.CPUs, want)
+	}
+
+	counters := k.Counters()
+	for key, value := range map[string]uint64{"contextSwitches": 100, "forks": 7, "irq/0": 50, "irq/ERR": 0, "softirq/TIMER": 40, "cpu1/interrupts": 6} {
+		if got, ok := counters[key]; !ok || got != value {
+			t.Errorf("Counters()[%q] = %d, want %d", key, got, value)
+		}
+	}
+}
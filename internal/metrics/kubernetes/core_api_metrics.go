@@ -11,82 +11,86 @@ import (
 )
 
 type NamespaceInfo struct {
-	Name         string
-	CreationTime time.Time
+	Name         string    `json:"name"`
+	CreationTime time.Time `json:"creationTime"`
 }
 
 type ServiceInfo struct {
-	Name           string
-	Namespace      string
-	CreationTime   time.Time
-	ClusterIp      string
-	ClusterIps     []string
-	ExternalIps    []string
-	ExternalName   string
-	LoadBalancerIp string
-	PortName       string
-	NodePort       int32
-	Protocol       corev1.Protocol
-	Port           int32
-	TargetPort     intstr.IntOrString
-	Type           corev1.ServiceType
+	Name           string             `json:"name"`
+	Namespace      string             `json:"namespace"`
+	CreationTime   time.Time          `json:"creationTime"`
+	ClusterIp      string             `json:"clusterIp"`
+	ClusterIps     []string           `json:"clusterIps"`
+	ExternalIps    []string           `json:"externalIps"`
+	ExternalName   string             `json:"externalName"`
+	LoadBalancerIp string             `json:"loadBalancerIp"`
+	PortName       string             `json:"portName"`
+	NodePort       int32              `json:"nodePort"`
+	Protocol       corev1.Protocol    `json:"protocol"`
+	Port           int32              `json:"port"`
+	TargetPort     intstr.IntOrString `json:"targetPort"`
+	Type           corev1.ServiceType `json:"type"`
 }
 
 type PodInfo struct {
-	Name              string
-	CreationTimestamp time.Time
-	Namespace         string
-	HostIP            string
-	PodIP             string
-	Phase             corev1.PodPhase
-	ContainerImage    string
-	ContainerName     string
-	ContainerPort     []corev1.ContainerPort
-	VolumeName        string
-	SchedulerName     string
-	NodeName          string
+	Name              string                 `json:"name"`
+	CreationTimestamp time.Time              `json:"creationTimestamp"`
+	Namespace         string                 `json:"namespace"`
+	HostIP            string                 `json:"hostIP"`
+	PodIP             string                 `json:"podIP"`
+	Phase             corev1.PodPhase        `json:"phase"`
+	ContainerImage    string                 `json:"containerImage"`
+	ContainerName     string                 `json:"containerName"`
+	ContainerPort     []corev1.ContainerPort `json:"containerPort"`
+	VolumeName        string                 `json:"volumeName"`
+	SchedulerName     string                 `json:"schedulerName"`
+	NodeName          string                 `json:"nodeName"`
 }
 
 type NodeInfo struct {
-	Name              string
-	CreationTimestamp time.Time
-	VolumesAttached   []corev1.AttachedVolume
-	VolumesInUse      []corev1.UniqueVolumeName
-	Addressed         []corev1.NodeAddress
-	Unschedulable     bool
-	PodCIDRs          []string
+	Name              string                    `json:"name"`
+	CreationTimestamp time.Time                 `json:"creationTimestamp"`
+	VolumesAttached   []corev1.AttachedVolume   `json:"volumesAttached"`
+	VolumesInUse      []corev1.UniqueVolumeName `json:"volumesInUse"`
+	Addressed         []corev1.NodeAddress      `json:"addressed"`
+	Unschedulable     bool                      `json:"unschedulable"`
+	PodCIDRs          []string                  `json:"podCIDRs"`
 }
 
 type PersistentVolumeInfo struct {
-	Name             string
-	CreationTime     time.Time
-	Capacity         corev1.ResourceList
-	AccessModes      []corev1.PersistentVolumeAccessMode
-	ReclaimPolicy    corev1.PersistentVolumeReclaimPolicy
-	Status           corev1.PersistentVolumePhase
-	StorageClassName string
-	VolumeMode       *corev1.PersistentVolumeMode
+	Name             string                               `json:"name"`
+	CreationTime     time.Time                            `json:"creationTime"`
+	Capacity         corev1.ResourceList                  `json:"capacity"`
+	AccessModes      []corev1.PersistentVolumeAccessMode  `json:"accessModes"`
+	ReclaimPolicy    corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy"`
+	Status           corev1.PersistentVolumePhase         `json:"status"`
+	StorageClassName string                               `json:"storageClassName"`
+	VolumeMode       *corev1.PersistentVolumeMode         `json:"volumeMode"`
 }
 
 type PersistentVolumeClaimInfo struct {
-	Name             string
-	Namespace        string
-	CreationTime     time.Time
-	Status           corev1.PersistentVolumeClaimPhase
-	AccessModes      []corev1.PersistentVolumeAccessMode
-	StorageClassName string
-	VolumeMode       corev1.PersistentVolumeMode
-	Capacity         corev1.ResourceList
+	Name             string                              `json:"name"`
+	Namespace        string                              `json:"namespace"`
+	CreationTime     time.Time                           `json:"creationTime"`
+	Status           corev1.PersistentVolumeClaimPhase   `json:"status"`
+	AccessModes      []corev1.PersistentVolumeAccessMode `json:"accessModes"`
+	StorageClassName string                              `json:"storageClassName"`
+	VolumeMode       corev1.PersistentVolumeMode         `json:"volumeMode"`
+	Capacity         corev1.ResourceList                 `json:"capacity"`
 }
 
-func getNamespaceInfo() ([]NamespaceInfo, error) {
-	clientset := getClientset()
-	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(),
+func getNamespaceInfo(ctx context.Context) ([]NamespaceInfo, error) {
+	clientset, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(ctx,
 		metav1.ListOptions{})
 
 	if err != nil {
 		logging.Error(logtag, "error getting namespaces", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	results := make([]NamespaceInfo, 0, len(namespaces.Items))
@@ -99,14 +103,18 @@ func getNamespaceInfo() ([]NamespaceInfo, error) {
 	return results, nil
 }
 
-func getServicesInfo() ([]ServiceInfo, error) {
-	clientset := getClientset()
-	services, err := clientset.CoreV1().Services(metav1.NamespaceAll).List(context.Background(),
+func getServicesInfo(ctx context.Context) ([]ServiceInfo, error) {
+	clientset, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := clientset.CoreV1().Services(metav1.NamespaceAll).List(ctx,
 		metav1.ListOptions{})
 
 	if err != nil {
 		logging.Error(logtag, "error getting services", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	results := make([]ServiceInfo, 0, len(services.Items))
@@ -146,14 +154,18 @@ func getServicesInfo() ([]ServiceInfo, error) {
 	return results, nil
 }
 
-func getPodsInfo() ([]PodInfo, error) {
-	clientset := getClientset()
-	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.Background(),
+func getPodsInfo(ctx context.Context) ([]PodInfo, error) {
+	clientset, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx,
 		metav1.ListOptions{})
 
 	if err != nil {
 		logging.Error(logtag, "error getting pods", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	results := make([]PodInfo, 0, len(pods.Items))
@@ -194,13 +206,17 @@ func getPodsInfo() ([]PodInfo, error) {
 	return results, nil
 }
 
-func getNodesInfo() ([]NodeInfo, error) {
-	clientset := getClientset()
-	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+func getNodesInfo(ctx context.Context) ([]NodeInfo, error) {
+	clientset, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 
 	if err != nil {
 		logging.Error(logtag, "error getting nodes", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	results := make([]NodeInfo, 0, len(nodes.Items))
@@ -216,13 +232,17 @@ func getNodesInfo() ([]NodeInfo, error) {
 	return results, nil
 }
 
-func getPersistentVolumesInfo() ([]PersistentVolumeInfo, error) {
-	clientset := getClientset()
-	pvs, err := clientset.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
+func getPersistentVolumesInfo(ctx context.Context) ([]PersistentVolumeInfo, error) {
+	clientset, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	pvs, err := clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
 
 	if err != nil {
 		logging.Error(logtag, "error getting persistent volumes", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	results := make([]PersistentVolumeInfo, 0, len(pvs.Items))
@@ -241,27 +261,37 @@ func getPersistentVolumesInfo() ([]PersistentVolumeInfo, error) {
 	return results, nil
 }
 
-func getPersistentVolumeClaimsInfo() ([]PersistentVolumeClaimInfo, error) {
-	clientset := getClientset()
-	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+func getPersistentVolumeClaimsInfo(ctx context.Context) ([]PersistentVolumeClaimInfo, error) {
+	clientset, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
 
 	if err != nil {
 		logging.Error(logtag, "error getting persistent volume claims", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	results := make([]PersistentVolumeClaimInfo, 0, len(pvcs.Items))
 	for _, pvc := range pvcs.Items {
-		results = append(results, PersistentVolumeClaimInfo{
-			Name:             pvc.Name,
-			Namespace:        pvc.Namespace,
-			CreationTime:     pvc.CreationTimestamp.Time,
-			Status:           pvc.Status.Phase,
-			AccessModes:      pvc.Spec.AccessModes,
-			StorageClassName: *pvc.Spec.StorageClassName,
-			VolumeMode:       *pvc.Spec.VolumeMode,
-			Capacity:         pvc.Status.Capacity,
-		})
+		info := PersistentVolumeClaimInfo{
+			Name:         pvc.Name,
+			Namespace:    pvc.Namespace,
+			CreationTime: pvc.CreationTimestamp.Time,
+			Status:       pvc.Status.Phase,
+			AccessModes:  pvc.Spec.AccessModes,
+			Capacity:     pvc.Status.Capacity,
+		}
+		// both are optional, eg for claims relying on the default storage class
+		if pvc.Spec.StorageClassName != nil {
+			info.StorageClassName = *pvc.Spec.StorageClassName
+		}
+		if pvc.Spec.VolumeMode != nil {
+			info.VolumeMode = *pvc.Spec.VolumeMode
+		}
+		results = append(results, info)
 	}
 	return results, nil
 }
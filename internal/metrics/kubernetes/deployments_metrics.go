@@ -12,24 +12,27 @@ import (
 var namespace string = "" //for all namespaces
 
 type DeploymentInfo struct {
-	Name                string
-	Namespace           string
-	AvailableReplicas   int32
-	ReadyReplicas       int32
-	UpdatedReplicas     int32
-	TerminatingReplicas *int32
-	TotalReplicas       int32
-	CreationTime        time.Time
+	Name                string    `json:"name"`
+	Namespace           string    `json:"namespace"`
+	AvailableReplicas   int32     `json:"availableReplicas"`
+	ReadyReplicas       int32     `json:"readyReplicas"`
+	UpdatedReplicas     int32     `json:"updatedReplicas"`
+	TerminatingReplicas *int32    `json:"terminatingReplicas"`
+	TotalReplicas       int32     `json:"totalReplicas"`
+	CreationTime        time.Time `json:"creationTime"`
 }
 
-func getDeploymentInfo() ([]DeploymentInfo, error) {
+func getDeploymentInfo(ctx context.Context) ([]DeploymentInfo, error) {
 
-	clientset := getClientset()
+	clientset, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
 
-	deployments, err := clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		logging.Error(logtag, "error getting deployments", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	results := make([]DeploymentInfo, 0, len(deployments.Items))
@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"context"
 	"path/filepath"
 
 	"github.com/techtacles/sysmonitoring/internal/logging"
@@ -15,53 +16,53 @@ var ExplicitKubeconfigPath string
 var logtag string = "kubernetes"
 
 type KubeInfo struct {
-	DeploymentStats            []DeploymentInfo
-	NamespaceStats             []NamespaceInfo
-	PersistentVolumeStats      []PersistentVolumeInfo
-	PersistentVolumeClaimStats []PersistentVolumeClaimInfo
-	NodeStats                  []NodeInfo
-	PodStats                   []PodInfo
-	ServiceStats               []ServiceInfo
+	DeploymentStats            []DeploymentInfo            `json:"deploymentStats"`
+	NamespaceStats             []NamespaceInfo             `json:"namespaceStats"`
+	PersistentVolumeStats      []PersistentVolumeInfo      `json:"persistentVolumeStats"`
+	PersistentVolumeClaimStats []PersistentVolumeClaimInfo `json:"persistentVolumeClaimStats"`
+	NodeStats                  []NodeInfo                  `json:"nodeStats"`
+	PodStats                   []PodInfo                   `json:"podStats"`
+	ServiceStats               []ServiceInfo               `json:"serviceStats"`
 }
 
-func (k *KubeInfo) Collect() error {
-	deploy, err := getDeploymentInfo()
+func (k *KubeInfo) Collect(ctx context.Context) error {
+	deploy, err := getDeploymentInfo(ctx)
 	if err != nil {
 		return err
 	}
 	k.DeploymentStats = deploy
 
-	namespace, err := getNamespaceInfo()
+	namespace, err := getNamespaceInfo(ctx)
 	if err != nil {
 		return err
 	}
 	k.NamespaceStats = namespace
 
-	pv, err := getPersistentVolumesInfo()
+	pv, err := getPersistentVolumesInfo(ctx)
 	if err != nil {
 		return err
 	}
 	k.PersistentVolumeStats = pv
 
-	pvc, err := getPersistentVolumeClaimsInfo()
+	pvc, err := getPersistentVolumeClaimsInfo(ctx)
 	if err != nil {
 		return err
 	}
 	k.PersistentVolumeClaimStats = pvc
 
-	node, err := getNodesInfo()
+	node, err := getNodesInfo(ctx)
 	if err != nil {
 		return err
 	}
 	k.NodeStats = node
 
-	pod, err := getPodsInfo()
+	pod, err := getPodsInfo(ctx)
 	if err != nil {
 		return err
 	}
 	k.PodStats = pod
 
-	service, err := getServicesInfo()
+	service, err := getServicesInfo(ctx)
 	if err != nil {
 		return err
 	}
@@ -81,19 +82,19 @@ func GetKubeConfigPath() string {
 	return ""
 }
 
-func getClientset() *kubernetes.Clientset {
+func getClientset() (*kubernetes.Clientset, error) {
 	path := GetKubeConfigPath()
 
 	config, err := clientcmd.BuildConfigFromFlags("", path)
 	if err != nil {
 		logging.Error(logtag, "error getting config", err)
-		panic(err.Error())
+		return nil, err
 	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		logging.Error(logtag, "error getting clientset", err)
-		panic(err.Error())
+		return nil, err
 	}
-	return clientset
+	return clientset, nil
 }
@@ -1,56 +1,56 @@
 package memory
 
 import (
-	"strings"
+	"context"
 
 	"github.com/shirou/gopsutil/v4/mem"
-	"github.com/shirou/gopsutil/v4/process"
 	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
 )
 
 const logtag string = "memory"
 
 type MemoryInfo struct {
-	Vmemory               VirtualMemoryInfo
-	SwapMemoryTotal       uint64
-	SwapMemoryUsed        uint64
-	SwapMemoryFree        uint64
-	SwapMemoryUsedPercent float64
-	ProcessInfo           []ProcessInfo
+	Vmemory               VirtualMemoryInfo `json:"vmemory"`
+	SwapMemoryTotal       uint64            `json:"swapMemoryTotal"`
+	SwapMemoryUsed        uint64            `json:"swapMemoryUsed"`
+	SwapMemoryFree        uint64            `json:"swapMemoryFree"`
+	SwapMemoryUsedPercent float64           `json:"swapMemoryUsedPercent"`
+	ProcessInfo           []ProcessInfo     `json:"processInfo"`
 }
 
 type ProcessInfo struct {
-	Pid                   int
-	MemPercent            float32
-	ChildrenPids          []int32
-	ParentPid             int32
-	IsChild               bool
-	ProcessName           string
-	NumThreads            int32
-	Username              string
-	VirtualMemorySize     uint64
-	PhysicalMemorySize    uint64
-	MaxPhysicalMemorySize uint64
-	MemoryUsedByHeap      uint64
-	MemoryUsedByStack     uint64
-	LockedMemory          uint64
+	Pid                   int     `json:"pid"`
+	MemPercent            float32 `json:"memPercent"`
+	ChildrenPids          []int32 `json:"childrenPids"`
+	ParentPid             int32   `json:"parentPid"`
+	IsChild               bool    `json:"isChild"`
+	ProcessName           string  `json:"processName"`
+	NumThreads            int32   `json:"numThreads"`
+	Username              string  `json:"username"`
+	VirtualMemorySize     uint64  `json:"virtualMemorySize"`
+	PhysicalMemorySize    uint64  `json:"physicalMemorySize"`
+	MaxPhysicalMemorySize uint64  `json:"maxPhysicalMemorySize"`
+	MemoryUsedByHeap      uint64  `json:"memoryUsedByHeap"`
+	MemoryUsedByStack     uint64  `json:"memoryUsedByStack"`
+	LockedMemory          uint64  `json:"lockedMemory"`
 }
 
 type VirtualMemoryInfo struct {
-	Total          uint64
-	Available      uint64
-	Used           uint64
-	UsedPercentage float64
-	Free           uint64
-	Shared         uint64
-	SReclaimable   uint64
-	SUnreclaimable uint64
-	Active         uint64
-	Inactive       uint64
+	Total          uint64  `json:"total"`
+	Available      uint64  `json:"available"`
+	Used           uint64  `json:"used"`
+	UsedPercentage float64 `json:"usedPercentage"`
+	Free           uint64  `json:"free"`
+	Shared         uint64  `json:"shared"`
+	SReclaimable   uint64  `json:"sReclaimable"`
+	SUnreclaimable uint64  `json:"sUnreclaimable"`
+	Active         uint64  `json:"active"`
+	Inactive       uint64  `json:"inactive"`
 }
 
-func (m *MemoryInfo) Collect() error {
-	processes, err := getProcesses()
+func (m *MemoryInfo) Collect(ctx context.Context) error {
+	processes, err := Processes(ctx, proctable.ListFilter)
 
 	if err != nil {
 		logging.Error(logtag, "error instantiating memory processes", err)
@@ -59,9 +59,9 @@ func (m *MemoryInfo) Collect() error {
 
 	m.ProcessInfo = processes
 
-	m.getSwapMemoryInfo()
+	m.getSwapMemoryInfo(ctx)
 
-	vm, err := getVirtualMemoryInfo()
+	vm, err := getVirtualMemoryInfo(ctx)
 	if err != nil {
 		logging.Error(logtag, "error instantiating virtual mem", err)
 		return err
@@ -72,8 +72,8 @@ func (m *MemoryInfo) Collect() error {
 	return nil
 }
 
-func (m *MemoryInfo) getSwapMemoryInfo() error {
-	swap_memory_info, err := mem.SwapMemory()
+func (m *MemoryInfo) getSwapMemoryInfo(ctx context.Context) error {
+	swap_memory_info, err := mem.SwapMemoryWithContext(ctx)
 	if err != nil {
 		logging.Error(logtag, "unable to get swap memory info", err)
 		return err
@@ -88,8 +88,8 @@ func (m *MemoryInfo) getSwapMemoryInfo() error {
 
 }
 
-func getVirtualMemoryInfo() (VirtualMemoryInfo, error) {
-	vmem, err := mem.VirtualMemory()
+func getVirtualMemoryInfo(ctx context.Context) (VirtualMemoryInfo, error) {
+	vmem, err := mem.VirtualMemoryWithContext(ctx)
 	if err != nil {
 		return VirtualMemoryInfo{}, err
 	}
@@ -108,69 +108,39 @@ func getVirtualMemoryInfo() (VirtualMemoryInfo, error) {
 	}, nil
 }
 
-func getProcesses() ([]ProcessInfo, error) {
-	procs, err := process.Processes()
+// Processes returns the processes matching filter, largest first
+func Processes(ctx context.Context, filter proctable.Filter) ([]ProcessInfo, error) {
+	table, err := proctable.Get(ctx)
 	if err != nil {
 		logging.Error(logtag, "error retrieving processes", err)
 		return nil, err
 	}
 
-	results := make([]ProcessInfo, 0, len(procs))
-
-	for _, proc := range procs {
-		username, err := proc.Username()
-
-		if err != nil {
-			continue
-		}
-
-		mempercent, err := proc.MemoryPercent()
-		if err != nil {
-			continue
-		}
-
-		if username == "root" || strings.HasPrefix(username, "_") {
-			continue
-		}
-
-		// we dont want to add noise by getting memory <1
-		if mempercent <= 1 {
-			continue
-		}
+	processes, err := filter.Apply(table.Processes, proctable.SortMem)
+	if err != nil {
+		logging.Error(logtag, "error filtering processes", err)
+		return nil, err
+	}
 
-		ppid, err := proc.Ppid()
-		if err != nil || ppid == 0 {
-			continue
-		}
+	results := make([]ProcessInfo, 0, len(processes))
 
-		meminfo, err := proc.MemoryInfo()
-		if err != nil {
-			continue
-		}
-		p := ProcessInfo{
+	for _, proc := range processes {
+		results = append(results, ProcessInfo{
 			Pid:                   int(proc.Pid),
-			ParentPid:             ppid,
-			IsChild:               true,
-			MemPercent:            mempercent,
-			Username:              username,
-			VirtualMemorySize:     meminfo.VMS,
-			PhysicalMemorySize:    meminfo.RSS,
-			MaxPhysicalMemorySize: meminfo.HWM,
-			MemoryUsedByHeap:      meminfo.Data,
-			MemoryUsedByStack:     meminfo.Stack,
-			LockedMemory:          meminfo.Locked,
-		}
-
-		p.ProcessName, _ = proc.Name()
-		p.NumThreads, _ = proc.NumThreads()
-		if children, err := proc.Children(); err == nil {
-			p.ChildrenPids = make([]int32, 0, len(children))
-			for _, c := range children {
-				p.ChildrenPids = append(p.ChildrenPids, c.Pid)
-			}
-		}
-
-		results = append(results, p)
+			ParentPid:             proc.ParentPid,
+			IsChild:               proc.ParentPid != 0,
+			MemPercent:            proc.MemPercent,
+			Username:              proc.Username,
+			ProcessName:           proc.Name,
+			NumThreads:            proc.NumThreads,
+			ChildrenPids:          proc.ChildrenPids,
+			VirtualMemorySize:     proc.Memory.VMS,
+			PhysicalMemorySize:    proc.Memory.RSS,
+			MaxPhysicalMemorySize: proc.Memory.HWM,
+			MemoryUsedByHeap:      proc.Memory.Data,
+			MemoryUsedByStack:     proc.Memory.Stack,
+			LockedMemory:          proc.Memory.Locked,
+		})
 
 	}
 	logging.Info(logtag, "finished instantiating GetProcesses()")
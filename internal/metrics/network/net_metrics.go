@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"runtime"
 
 	gnet "github.com/shirou/gopsutil/v4/net"
@@ -10,20 +11,20 @@ import (
 const logtag string = "network"
 
 type NetworkInfo struct {
-	NumEstablishedConnections int // this gets the number of established
-	NumTotalConnections       int
-	Runtime                   string
-	IOStats                   []IOInfo
-	Connections               []ConnStatInfo
+	NumEstablishedConnections int            `json:"numEstablishedConnections"` // this gets the number of established
+	NumTotalConnections       int            `json:"numTotalConnections"`
+	Runtime                   string         `json:"runtime"`
+	IOStats                   []IOInfo       `json:"ioStats"`
+	Connections               []ConnStatInfo `json:"connections"`
 }
 
 type ConnStatInfo struct {
-	Family     uint32
-	LocalAddr  gnet.Addr
-	RemoteAddr gnet.Addr
-	Status     string
-	Pid        int32
-	Type       uint32
+	Family     uint32    `json:"family"`
+	LocalAddr  gnet.Addr `json:"localAddr"`
+	RemoteAddr gnet.Addr `json:"remoteAddr"`
+	Status     string    `json:"status"`
+	Pid        int32     `json:"pid"`
+	Type       uint32    `json:"type"`
 }
 
 type IOInfo struct {
@@ -38,15 +39,15 @@ type IOInfo struct {
 	Dropout     uint64 `json:"dropout"`
 }
 
-func (n *NetworkInfo) Collect() error {
+func (n *NetworkInfo) Collect(ctx context.Context) error {
 	n.Runtime = runtime.GOOS
 
-	iostat, err := collectIOStats()
+	iostat, err := collectIOStats(ctx)
 	if err != nil {
 		return err
 	}
 
-	constat, established_conn, total_conn, err := collectConnections()
+	constat, established_conn, total_conn, err := collectConnections(ctx)
 	if err != nil {
 		return err
 	}
@@ -58,10 +59,27 @@ func (n *NetworkInfo) Collect() error {
 	return nil
 }
 
-func collectIOStats() ([]IOInfo, error) {
+// Counters returns the cumulative IO counters of every interface keyed by
+// "<interface>/<counter>", eg "eth0/bytesRecv"
+func (n *NetworkInfo) Counters() map[string]uint64 {
+	counters := make(map[string]uint64, len(n.IOStats)*8)
+	for _, io := range n.IOStats {
+		counters[io.Name+"/bytesSent"] = io.BytesSent
+		counters[io.Name+"/bytesRecv"] = io.BytesRecv
+		counters[io.Name+"/packetsSent"] = io.PacketsSent
+		counters[io.Name+"/packetsRecv"] = io.PacketsRecv
+		counters[io.Name+"/errin"] = io.Errin
+		counters[io.Name+"/errout"] = io.Errout
+		counters[io.Name+"/dropin"] = io.Dropin
+		counters[io.Name+"/dropout"] = io.Dropout
+	}
+	return counters
+}
+
+func collectIOStats(ctx context.Context) ([]IOInfo, error) {
 	logging.Info(logtag, "collecting network IO stats")
 
-	stats, err := gnet.IOCounters(true)
+	stats, err := gnet.IOCountersWithContext(ctx, true)
 	if err != nil {
 		logging.Error(logtag, "failed to get IO counters", err)
 		return nil, err
@@ -86,10 +104,10 @@ func collectIOStats() ([]IOInfo, error) {
 	return results, nil
 }
 
-func collectConnections() ([]ConnStatInfo, int, int, error) {
+func collectConnections(ctx context.Context) ([]ConnStatInfo, int, int, error) {
 	logging.Info(logtag, "collecting network connections")
 
-	conns, err := gnet.Connections("all")
+	conns, err := gnet.ConnectionsWithContext(ctx, "all")
 	if err != nil {
 		logging.Error(logtag, "failed to get connections", err)
 		return nil, 0, 0, err
@@ -0,0 +1,189 @@
+package pressure
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+const logtag string = "pressure"
+
+// ProcfsRoot is where procfs is mounted, eg "/host/proc" when sysmon runs in
+// a container watching its host, or a directory of fixture files
+var ProcfsRoot = "/proc"
+
+// resources are the files read under /proc/pressure. irq is only present
+// on kernels 6.1 and later with CONFIG_IRQ_TIME_ACCOUNTING.
+var resources = []string{"cpu", "memory", "io", "irq"}
+
+// PressureInfo is the Pressure Stall Information (PSI) of the kernel: the
+// share of time tasks were stalled waiting for a resource. Resources the
+// kernel does not report are nil.
+type PressureInfo struct {
+	CPU    *Resource `json:"cpu,omitempty"`
+	Memory *Resource `json:"memory,omitempty"`
+	IO     *Resource `json:"io,omitempty"`
+	IRQ    *Resource `json:"irq,omitempty"`
+}
+
+// Resource holds the two PSI lines of a resource. Some is the time at least
+// one task was stalled, Full the time every non-idle task was stalled at
+// once. Full is nil for cpu on kernels before 5.13.
+type Resource struct {
+	Some *Stall `json:"some,omitempty"`
+	Full *Stall `json:"full,omitempty"`
+}
+
+// Stall is one PSI line. The averages are percentages over the last 10, 60
+// and 300 seconds, Total the stall time since boot in microseconds.
+type Stall struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+// Available reports whether the kernel exposes PSI. It needs Linux 4.20 or
+// later built with CONFIG_PSI, and psi=1 on kernels where it is off by
+// default.
+func Available() bool {
+	_, err := os.Stat(filepath.Join(ProcfsRoot, "pressure", "cpu"))
+	return err == nil
+}
+
+func (p *PressureInfo) Collect(ctx context.Context) error {
+	logging.Info(logtag, "collecting pressure stall information")
+	found := false
+	for _, name := range resources {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		r, err := readResource(filepath.Join(ProcfsRoot, "pressure", name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			// reading a pressure file fails with EOPNOTSUPP when PSI is
+			// compiled in but disabled
+			logging.Error(logtag, fmt.Sprintf("error reading %s pressure", name), err)
+			return err
+		}
+		found = true
+
+		switch name {
+		case "cpu":
+			p.CPU = r
+		case "memory":
+			p.Memory = r
+		case "io":
+			p.IO = r
+		case "irq":
+			p.IRQ = r
+		}
+	}
+
+	if !found {
+		err := fmt.Errorf("pressure stall information is not available under %s", ProcfsRoot)
+		logging.Error(logtag, "error collecting pressure stall information", err)
+		return err
+	}
+	return nil
+}
+
+// NamedResource is a resource with the name of its pressure file
+type NamedResource struct {
+	Name string
+	*Resource
+}
+
+// Resources returns the reported resources in a fixed order
+func (p *PressureInfo) Resources() []NamedResource {
+	var out []NamedResource
+	for _, r := range []NamedResource{{"cpu", p.CPU}, {"memory", p.Memory}, {"io", p.IO}, {"irq", p.IRQ}} {
+		if r.Resource != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Counters exposes the stall totals so the aggregator publishes them as
+// rates, in microseconds stalled per second
+func (p *PressureInfo) Counters() map[string]uint64 {
+	counters := make(map[string]uint64)
+	for _, r := range p.Resources() {
+		if r.Some != nil {
+			counters[r.Name+"/some"] = r.Some.Total
+		}
+		if r.Full != nil {
+			counters[r.Name+"/full"] = r.Full.Total
+		}
+	}
+	return counters
+}
+
+// readResource parses a pressure file:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readResource(path string) (*Resource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Resource{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		stall, err := parseStall(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		switch fields[0] {
+		case "some":
+			r.Some = stall
+		case "full":
+			r.Full = stall
+		}
+	}
+	return r, scanner.Err()
+}
+
+func parseStall(fields []string) (*Stall, error) {
+	s := &Stall{}
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed field %q", field)
+		}
+
+		var err error
+		switch key {
+		case "avg10":
+			s.Avg10, err = strconv.ParseFloat(value, 64)
+		case "avg60":
+			s.Avg60, err = strconv.ParseFloat(value, 64)
+		case "avg300":
+			s.Avg300, err = strconv.ParseFloat(value, 64)
+		case "total":
+			s.Total, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed field %q: %w", field, err)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,147 @@
+package pressure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, "pressure")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantSome *Stall
+		wantFull *Stall
+		wantErr  bool
+	}{
+		{
+			name: "memory",
+			content: "some avg10=1.50 avg60=0.75 avg300=0.25 total=123456\n" +
+				"full avg10=0.50 avg60=0.10 avg300=0.00 total=7890\n",
+			wantSome: &Stall{Avg10: 1.5, Avg60: 0.75, Avg300: 0.25, Total: 123456},
+			wantFull: &Stall{Avg10: 0.5, Avg60: 0.1, Total: 7890},
+		},
+		{
+			name:     "cpu without a full line",
+			content:  "some avg10=2.00 avg60=1.00 avg300=0.50 total=42\n",
+			wantSome: &Stall{Avg10: 2, Avg60: 1, Avg300: 0.5, Total: 42},
+		},
+		{
+			name:     "irq with only a full line",
+			content:  "full avg10=0.00 avg60=0.00 avg300=0.00 total=314\n",
+			wantFull: &Stall{Total: 314},
+		},
+		{
+			name:    "field without a value",
+			content: "some avg10=0.00 avg60 avg300=0.00 total=0\n",
+			wantErr: true,
+		},
+		{
+			name:    "field that is not a number",
+			content: "some avg10=0.00 avg60=0.00 avg300=0.00 total=-1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resource")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := readResource(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readResource() = %+v, want an error", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readResource() error = %v", err)
+			}
+			checkStall(t, "some", r.Some, tt.wantSome)
+			checkStall(t, "full", r.Full, tt.wantFull)
+		})
+	}
+}
+
+func checkStall(t *testing.T, line string, got, want *Stall) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("%s = %+v, want %+v", line, got, want)
+	case *got != *want:
+		t.Errorf("%s = %+v, want %+v", line, *got, *want)
+	}
+}
+
+func TestParseStallIgnoresUnknownKeys(t *testing.T) {
+	s, err := parseStall([]string{"avg10=1.00", "avg600=9.00", "total=5"})
+	if err != nil {
+		t.Fatalf("parseStall() error = %v", err)
+	}
+	if want := (Stall{Avg10: 1, Total: 5}); *s != want {
+		t.Errorf("parseStall() = %+v, want %+v", *s, want)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"cpu": "some avg10=0.00 avg60=0.00 avg300=0.00 total=100\n",
+		"io":  "some avg10=0.00 avg60=0.00 avg300=0.00 total=200\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=150\n",
+	})
+	defer func(old string) { ProcfsRoot = old }(ProcfsRoot)
+	ProcfsRoot = root
+
+	if !Available() {
+		t.Fatal("Available() = false with a cpu pressure file")
+	}
+	var p PressureInfo
+	if err := p.Collect(context.Background()); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if p.CPU == nil || p.IO == nil || p.Memory != nil || p.IRQ != nil {
+		t.Fatalf("Collect() = %+v, want cpu and io only", p)
+	}
+
+	counters := p.Counters()
+	want := map[string]uint64{"cpu/some": 100, "io/some": 200, "io/full": 150}
+	if len(counters) != len(want) {
+		t.Errorf("Counters() = %v, want %v", counters, want)
+	}
+	for key, value := range want {
+		if counters[key] != value {
+			t.Errorf("Counters()[%q] = %d, want %d", key, counters[key], value)
+		}
+	}
+}
+
+func TestCollectNotAvailable(t *testing.T) {
+	defer func(old string) { ProcfsRoot = old }(ProcfsRoot)
+	ProcfsRoot = t.TempDir()
+
+	if Available() {
+		t.Error("Available() = true without /proc/pressure")
+	}
+	var p PressureInfo
+	if err := p.Collect(context.Background()); err == nil {
+		t.Errorf("Collect() = %+v, want an error", p)
+	}
+}
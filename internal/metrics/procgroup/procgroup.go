@@ -0,0 +1,207 @@
+// Package procgroup watches named groups of processes, such as every nginx
+// worker, so that a service that crashed or lost instances shows up instead
+// of silently dropping out of the process lists.
+package procgroup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+)
+
+const logtag string = "procgroup"
+
+// CollectorName is the name the groups are collected under
+const CollectorName = "process_groups"
+
+// Group statuses
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // fewer instances than MinInstances
+	StatusDown     = "down"     // no instances at all
+)
+
+// Group selects processes by name, user and command line. Empty fields
+// match every process.
+type Group struct {
+	Name         string
+	ProcessName  string         // exact process name, eg "nginx"
+	User         string         // exact user name, eg "www-data"
+	Cmdline      *regexp.Regexp // matched against the full command line
+	MinInstances int
+}
+
+// Matches reports whether a process belongs to the group
+func (g Group) Matches(p proctable.Process) bool {
+	if g.ProcessName != "" && p.Name != g.ProcessName {
+		return false
+	}
+	if g.User != "" && p.Username != g.User {
+		return false
+	}
+	if g.Cmdline != nil && !g.Cmdline.MatchString(p.Command) {
+		return false
+	}
+	return true
+}
+
+// GroupsInfo is the state of every watched group
+type GroupsInfo struct {
+	Groups []GroupInfo `json:"groups"`
+}
+
+// GroupInfo is the state of one group. Restarts counts the instances that
+// replaced lost ones while the group was at or below MinInstances (at least
+// one), so a crashed and restarted service counts but a pool of short-lived
+// workers churning above its minimum does not.
+type GroupInfo struct {
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	Instances     int        `json:"instances"`
+	MinInstances  int        `json:"minInstances"`
+	Pids          []int32    `json:"pids"`
+	CPUPercent    float64    `json:"cpuPercent"`
+	MemPercent    float64    `json:"memPercent"`
+	RSS           uint64     `json:"rss"`
+	NumThreads    int32      `json:"numThreads"`
+	Restarts      int        `json:"restarts"`
+	LastRestart   *time.Time `json:"lastRestart,omitempty"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"` // of the oldest instance
+	UptimeSeconds float64    `json:"uptimeSeconds"`       // of the oldest instance
+	DownSince     *time.Time `json:"downSince,omitempty"`
+}
+
+// instance identifies a process even when its pid is reused
+type instance struct {
+	pid        int32
+	createTime int64
+}
+
+type groupState struct {
+	watched     bool
+	seen        bool // the group has had instances
+	instances   map[instance]bool
+	restarts    int
+	lastRestart time.Time
+	downSince   time.Time
+	status      string
+}
+
+// Watcher tracks groups between collections
+type Watcher struct {
+	mu     sync.Mutex
+	groups []Group
+	states map[string]*groupState
+}
+
+// NewWatcher returns a watcher for the given groups
+func NewWatcher(groups []Group) *Watcher {
+	states := make(map[string]*groupState, len(groups))
+	for _, g := range groups {
+		states[g.Name] = &groupState{}
+	}
+	return &Watcher{groups: groups, states: states}
+}
+
+// Collect matches the process table against every group
+func (w *Watcher) Collect(ctx context.Context) (*GroupsInfo, error) {
+	table, err := proctable.Get(ctx)
+	if err != nil {
+		logging.Error(logtag, "error reading process table", err)
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	info := &GroupsInfo{Groups: make([]GroupInfo, 0, len(w.groups))}
+	for _, g := range w.groups {
+		info.Groups = append(info.Groups, w.update(g, table))
+	}
+	return info, nil
+}
+
+func (w *Watcher) update(g Group, table *proctable.Snapshot) GroupInfo {
+	state := w.states[g.Name]
+	result := GroupInfo{Name: g.Name, MinInstances: g.MinInstances, Pids: []int32{}}
+
+	current := make(map[instance]bool)
+	var oldest time.Time
+	for _, p := range table.Processes {
+		if !g.Matches(p) {
+			continue
+		}
+		current[instance{pid: p.Pid, createTime: p.CreateTime.UnixMilli()}] = true
+
+		result.Pids = append(result.Pids, p.Pid)
+		result.CPUPercent += p.CPUPercent
+		result.MemPercent += float64(p.MemPercent)
+		result.RSS += p.Memory.RSS
+		result.NumThreads += p.NumThreads
+		if oldest.IsZero() || p.CreateTime.Before(oldest) {
+			oldest = p.CreateTime
+		}
+	}
+	result.Instances = len(current)
+
+	if state.watched && state.seen {
+		survivors, started := 0, 0
+		for inst := range current {
+			if state.instances[inst] {
+				survivors++
+			} else {
+				started++
+			}
+		}
+		// new instances only replace lost ones while the group has fallen
+		// to its minimum, eg a single server that changed pid
+		if short := max(g.MinInstances, 1) - survivors; short > 0 && started > 0 {
+			state.restarts += min(started, short)
+			state.lastRestart = table.At
+		}
+	}
+	state.watched = true
+	state.seen = state.seen || len(current) > 0
+	state.instances = current
+
+	if result.Instances == 0 {
+		if state.downSince.IsZero() {
+			state.downSince = table.At
+		}
+	} else {
+		state.downSince = time.Time{}
+	}
+
+	switch {
+	case result.Instances == 0 && g.MinInstances > 0:
+		result.Status = StatusDown
+	case result.Instances < g.MinInstances:
+		result.Status = StatusDegraded
+	default:
+		result.Status = StatusOK
+	}
+	if result.Status != state.status && result.Status != StatusOK {
+		logging.Error(logtag, fmt.Sprintf("process group %s is %s: %d of %d instances running", g.Name, result.Status, result.Instances, g.MinInstances), nil)
+	}
+	state.status = result.Status
+
+	result.Restarts = state.restarts
+	if !state.lastRestart.IsZero() {
+		t := state.lastRestart
+		result.LastRestart = &t
+	}
+	if !state.downSince.IsZero() {
+		t := state.downSince
+		result.DownSince = &t
+	}
+	if !oldest.IsZero() {
+		result.StartedAt = &oldest
+		result.UptimeSeconds = table.At.Sub(oldest).Seconds()
+	}
+	return result
+}
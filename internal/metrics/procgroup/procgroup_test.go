@@ -0,0 +1,191 @@
+package procgroup
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/metrics/proctable"
+)
+
+var base = time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+
+// proc is a process, started the given number of seconds after base
+type proc struct {
+	pid     int32
+	started int
+}
+
+// step is one collection, a minute after the previous one, and what the
+// group should look like after it. Times are given as step indexes, -1 for
+// none.
+type step struct {
+	procs       []proc
+	status      string
+	instances   int
+	restarts    int
+	lastRestart int
+	downSince   int
+}
+
+// snapshot returns a table with the given nginx processes and an unrelated
+// shell at collection i
+func snapshot(i int, procs []proc) *proctable.Snapshot {
+	s := &proctable.Snapshot{
+		At:        base.Add(time.Duration(i) * time.Minute),
+		Processes: []proctable.Process{{Pid: 1, Name: "bash", Username: "root", CreateTime: base}},
+	}
+	for _, p := range procs {
+		s.Processes = append(s.Processes, proctable.Process{
+			Pid:        p.pid,
+			Name:       "nginx",
+			Username:   "www-data",
+			Command:    "nginx: worker process",
+			CreateTime: base.Add(time.Duration(p.started) * time.Second),
+			CPUPercent: 1.5,
+			MemPercent: 0.5,
+			Memory:     proctable.Memory{RSS: 100},
+			NumThreads: 2,
+		})
+	}
+	return s
+}
+
+func atStep(i int) *time.Time {
+	if i < 0 {
+		return nil
+	}
+	t := base.Add(time.Duration(i) * time.Minute)
+	return &t
+}
+
+func TestWatcherUpdate(t *testing.T) {
+	tests := []struct {
+		name  string
+		min   int
+		steps []step
+	}{
+		{
+			name: "single instance changing pid is a restart",
+			min:  1,
+			steps: []step{
+				{procs: []proc{{100, 0}}, status: StatusOK, instances: 1, lastRestart: -1, downSince: -1},
+				{procs: []proc{{200, 70}}, status: StatusOK, instances: 1, restarts: 1, lastRestart: 1, downSince: -1},
+				{procs: []proc{{200, 70}}, status: StatusOK, instances: 1, restarts: 1, lastRestart: 1, downSince: -1},
+			},
+		},
+		{
+			// the pid is the same but the process is not
+			name: "reused pid is a restart",
+			min:  1,
+			steps: []step{
+				{procs: []proc{{100, 0}}, status: StatusOK, instances: 1, lastRestart: -1, downSince: -1},
+				{procs: []proc{{100, 70}}, status: StatusOK, instances: 1, restarts: 1, lastRestart: 1, downSince: -1},
+			},
+		},
+		{
+			name: "churn above the minimum is not a restart",
+			min:  2,
+			steps: []step{
+				{procs: []proc{{10, 0}, {11, 0}, {12, 0}, {13, 0}}, status: StatusOK, instances: 4, lastRestart: -1, downSince: -1},
+				{procs: []proc{{10, 0}, {11, 0}, {14, 70}, {15, 70}}, status: StatusOK, instances: 4, lastRestart: -1, downSince: -1},
+				{procs: []proc{{14, 70}, {15, 70}, {16, 130}}, status: StatusOK, instances: 3, lastRestart: -1, downSince: -1},
+			},
+		},
+		{
+			// one worker is still short of the minimum when its
+			// replacement starts
+			name: "replacement at the minimum is a restart",
+			min:  3,
+			steps: []step{
+				{procs: []proc{{1, 0}, {2, 0}, {3, 0}}, status: StatusOK, instances: 3, lastRestart: -1, downSince: -1},
+				{procs: []proc{{1, 0}, {2, 0}, {4, 70}, {5, 70}}, status: StatusOK, instances: 4, restarts: 1, lastRestart: 1, downSince: -1},
+			},
+		},
+		{
+			name: "down and back",
+			min:  1,
+			steps: []step{
+				{procs: []proc{{100, 0}}, status: StatusOK, instances: 1, lastRestart: -1, downSince: -1},
+				{status: StatusDown, lastRestart: -1, downSince: 1},
+				{status: StatusDown, lastRestart: -1, downSince: 1},
+				{procs: []proc{{300, 150}}, status: StatusOK, instances: 1, restarts: 1, lastRestart: 3, downSince: -1},
+				{status: StatusDown, restarts: 1, lastRestart: 3, downSince: 4},
+				{procs: []proc{{400, 280}}, status: StatusOK, instances: 1, restarts: 2, lastRestart: 5, downSince: -1},
+			},
+		},
+		{
+			name: "degraded, down and back with the whole pool",
+			min:  2,
+			steps: []step{
+				{procs: []proc{{1, 0}, {2, 0}}, status: StatusOK, instances: 2, lastRestart: -1, downSince: -1},
+				{procs: []proc{{1, 0}}, status: StatusDegraded, instances: 1, lastRestart: -1, downSince: -1},
+				{status: StatusDown, lastRestart: -1, downSince: 2},
+				{procs: []proc{{3, 170}, {4, 170}}, status: StatusOK, instances: 2, restarts: 2, lastRestart: 3, downSince: -1},
+			},
+		},
+		{
+			// a service started after sysmon did not restart
+			name: "first start is not a restart",
+			min:  1,
+			steps: []step{
+				{status: StatusDown, lastRestart: -1, downSince: 0},
+				{status: StatusDown, lastRestart: -1, downSince: 0},
+				{procs: []proc{{100, 110}}, status: StatusOK, instances: 1, lastRestart: -1, downSince: -1},
+			},
+		},
+		{
+			name: "optional group",
+			min:  0,
+			steps: []step{
+				{status: StatusOK, lastRestart: -1, downSince: 0},
+				{procs: []proc{{100, 70}}, status: StatusOK, instances: 1, lastRestart: -1, downSince: -1},
+				{procs: []proc{{200, 130}}, status: StatusOK, instances: 1, restarts: 1, lastRestart: 2, downSince: -1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Group{Name: "web", ProcessName: "nginx", User: "www-data", Cmdline: regexp.MustCompile("worker"), MinInstances: tt.min}
+			w := NewWatcher([]Group{g})
+			for i, s := range tt.steps {
+				got := w.update(g, snapshot(i, s.procs))
+				if got.Status != s.status || got.Instances != s.instances || got.Restarts != s.restarts {
+					t.Errorf("step %d: %s with %d instances and %d restarts, want %s with %d and %d",
+						i, got.Status, got.Instances, got.Restarts, s.status, s.instances, s.restarts)
+				}
+				if want := atStep(s.lastRestart); !reflect.DeepEqual(got.LastRestart, want) {
+					t.Errorf("step %d: lastRestart = %v, want %v", i, got.LastRestart, want)
+				}
+				if want := atStep(s.downSince); !reflect.DeepEqual(got.DownSince, want) {
+					t.Errorf("step %d: downSince = %v, want %v", i, got.DownSince, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWatcherUpdateTotals(t *testing.T) {
+	g := Group{Name: "web", ProcessName: "nginx", MinInstances: 1}
+	w := NewWatcher([]Group{g})
+	got := w.update(g, snapshot(2, []proc{{101, 60}, {100, 30}}))
+
+	started := base.Add(30 * time.Second)
+	want := GroupInfo{
+		Name:          "web",
+		Status:        StatusOK,
+		Instances:     2,
+		MinInstances:  1,
+		Pids:          []int32{101, 100},
+		CPUPercent:    3,
+		MemPercent:    1,
+		RSS:           200,
+		NumThreads:    4,
+		StartedAt:     &started,
+		UptimeSeconds: 90,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("update() = %+v, want %+v", got, want)
+	}
+}
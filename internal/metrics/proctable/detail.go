@@ -0,0 +1,253 @@
+package proctable
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+// Redacted replaces the value of environment variables not in ShowEnv
+const Redacted = "[REDACTED]"
+
+// ShowEnv lists the environment variables whose values Inspect returns. The
+// others are listed by name only, as secrets are passed under too many
+// names, eg DATABASE_URL or *_DSN, to be caught by a deny-list.
+var ShowEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "PWD", "LANG", "LC_ALL", "TZ", "TERM", "HOSTNAME"}
+
+// Detail is everything known about one process. Details the platform does
+// not report or sysmon is not allowed to read, eg the environment of another
+// user's process, are left empty and the reason is recorded in Errors.
+type Detail struct {
+	Process
+	Cmdline         []string          `json:"cmdline"`
+	Cwd             string            `json:"cwd"`
+	Exe             string            `json:"exe"`
+	Environ         []string          `json:"environ"`
+	NumFDs          int32             `json:"numFds"`
+	OpenFiles       []OpenFile        `json:"openFiles"`
+	Rlimits         []Rlimit          `json:"rlimits"`
+	IO              *IOCounters       `json:"io"`
+	ContextSwitches *ContextSwitches  `json:"contextSwitches"`
+	Connections     []Connection      `json:"connections"`
+	Errors          map[string]string `json:"errors,omitempty"`
+}
+
+// OpenFile is a file held open by the process
+type OpenFile struct {
+	Fd   uint64 `json:"fd"`
+	Path string `json:"path"`
+}
+
+// Rlimit is a resource limit. Unlimited values are reported as the largest
+// uint64.
+type Rlimit struct {
+	Resource string `json:"resource"`
+	Soft     uint64 `json:"soft"`
+	Hard     uint64 `json:"hard"`
+	Used     uint64 `json:"used"`
+}
+
+// IOCounters are the I/O totals of the process since it started
+type IOCounters struct {
+	ReadCount      uint64 `json:"readCount"`
+	WriteCount     uint64 `json:"writeCount"`
+	ReadBytes      uint64 `json:"readBytes"`
+	WriteBytes     uint64 `json:"writeBytes"`
+	DiskReadBytes  uint64 `json:"diskReadBytes"`
+	DiskWriteBytes uint64 `json:"diskWriteBytes"`
+}
+
+// ContextSwitches counts how often the process gave up the CPU
+type ContextSwitches struct {
+	Voluntary   int64 `json:"voluntary"`
+	Involuntary int64 `json:"involuntary"`
+}
+
+// Connection is a socket held by the process
+type Connection struct {
+	Fd         uint32 `json:"fd"`
+	Family     string `json:"family"`
+	Type       string `json:"type"`
+	LocalAddr  string `json:"localAddr"`
+	RemoteAddr string `json:"remoteAddr"`
+	Status     string `json:"status"`
+}
+
+// rlimitNames names the resources of process.RlimitStat
+var rlimitNames = map[int32]string{
+	process.RLIMIT_CPU:        "cpu",
+	process.RLIMIT_FSIZE:      "fsize",
+	process.RLIMIT_DATA:       "data",
+	process.RLIMIT_STACK:      "stack",
+	process.RLIMIT_CORE:       "core",
+	process.RLIMIT_RSS:        "rss",
+	process.RLIMIT_NPROC:      "nproc",
+	process.RLIMIT_NOFILE:     "nofile",
+	process.RLIMIT_MEMLOCK:    "memlock",
+	process.RLIMIT_AS:         "as",
+	process.RLIMIT_LOCKS:      "locks",
+	process.RLIMIT_SIGPENDING: "sigpending",
+	process.RLIMIT_MSGQUEUE:   "msgqueue",
+	process.RLIMIT_NICE:       "nice",
+	process.RLIMIT_RTPRIO:     "rtprio",
+	process.RLIMIT_RTTIME:     "rttime",
+}
+
+// Inspect returns the details of one process. The environment is only read
+// when withEnv is set. It returns ErrNotFound when the process is not
+// running.
+func Inspect(ctx context.Context, pid int32, withEnv bool) (*Detail, error) {
+	table, err := Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var row *Process
+	for i := range table.Processes {
+		if table.Processes[i].Pid == pid {
+			row = &table.Processes[i]
+			break
+		}
+	}
+	if row == nil {
+		return nil, fmt.Errorf("pid %d: %w", pid, ErrNotFound)
+	}
+
+	logging.Info(logtag, fmt.Sprintf("inspecting process %d", pid))
+	proc := &process.Process{Pid: pid}
+	d := &Detail{Process: *row, Errors: make(map[string]string)}
+	fail := func(field string, err error) {
+		d.Errors[field] = err.Error()
+	}
+
+	if d.Cmdline, err = proc.CmdlineSliceWithContext(ctx); err != nil {
+		fail("cmdline", err)
+	}
+	if d.Cwd, err = proc.CwdWithContext(ctx); err != nil {
+		fail("cwd", err)
+	}
+	if d.Exe, err = proc.ExeWithContext(ctx); err != nil {
+		fail("exe", err)
+	}
+	if withEnv {
+		if env, err := proc.EnvironWithContext(ctx); err != nil {
+			fail("environ", err)
+		} else {
+			d.Environ = redactEnv(env)
+		}
+	}
+	if d.NumFDs, err = proc.NumFDsWithContext(ctx); err != nil {
+		fail("numFds", err)
+	}
+
+	if files, err := proc.OpenFilesWithContext(ctx); err != nil {
+		fail("openFiles", err)
+	} else {
+		d.OpenFiles = make([]OpenFile, 0, len(files))
+		for _, f := range files {
+			d.OpenFiles = append(d.OpenFiles, OpenFile{Fd: f.Fd, Path: f.Path})
+		}
+	}
+
+	if limits, err := proc.RlimitUsageWithContext(ctx, true); err != nil {
+		fail("rlimits", err)
+	} else {
+		d.Rlimits = make([]Rlimit, 0, len(limits))
+		for _, l := range limits {
+			name, ok := rlimitNames[l.Resource]
+			if !ok {
+				name = strconv.Itoa(int(l.Resource))
+			}
+			d.Rlimits = append(d.Rlimits, Rlimit{Resource: name, Soft: l.Soft, Hard: l.Hard, Used: l.Used})
+		}
+	}
+
+	if io, err := proc.IOCountersWithContext(ctx); err != nil {
+		fail("io", err)
+	} else {
+		d.IO = &IOCounters{
+			ReadCount:      io.ReadCount,
+			WriteCount:     io.WriteCount,
+			ReadBytes:      io.ReadBytes,
+			WriteBytes:     io.WriteBytes,
+			DiskReadBytes:  io.DiskReadBytes,
+			DiskWriteBytes: io.DiskWriteBytes,
+		}
+	}
+
+	if switches, err := proc.NumCtxSwitchesWithContext(ctx); err != nil {
+		fail("contextSwitches", err)
+	} else {
+		d.ContextSwitches = &ContextSwitches{Voluntary: switches.Voluntary, Involuntary: switches.Involuntary}
+	}
+
+	if conns, err := proc.ConnectionsWithContext(ctx); err != nil {
+		fail("connections", err)
+	} else {
+		d.Connections = make([]Connection, 0, len(conns))
+		for _, c := range conns {
+			d.Connections = append(d.Connections, Connection{
+				Fd:         c.Fd,
+				Family:     familyName(c.Family),
+				Type:       typeName(c.Type),
+				LocalAddr:  joinAddr(c.Laddr.IP, c.Laddr.Port),
+				RemoteAddr: joinAddr(c.Raddr.IP, c.Raddr.Port),
+				Status:     c.Status,
+			})
+		}
+	}
+
+	return d, nil
+}
+
+// redactEnv hides the values of variables not in ShowEnv
+func redactEnv(env []string) []string {
+	result := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(ShowEnv, name) {
+			kv = name + "=" + Redacted
+		}
+		result = append(result, kv)
+	}
+	return result
+}
+
+func familyName(family uint32) string {
+	switch family {
+	case syscall.AF_INET:
+		return "inet"
+	case syscall.AF_INET6:
+		return "inet6"
+	case syscall.AF_UNIX:
+		return "unix"
+	}
+	return strconv.FormatUint(uint64(family), 10)
+}
+
+func typeName(t uint32) string {
+	switch t {
+	case syscall.SOCK_STREAM:
+		return "stream"
+	case syscall.SOCK_DGRAM:
+		return "dgram"
+	}
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+func joinAddr(ip string, port uint32) string {
+	if ip == "" && port == 0 {
+		return ""
+	}
+	if port == 0 {
+		return ip
+	}
+	return net.JoinHostPort(ip, strconv.FormatUint(uint64(port), 10))
+}
@@ -0,0 +1,198 @@
+package proctable
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+)
+
+// Filter selects the processes listed by the cpu and memory collectors.
+// The zero value lists every process except kernel threads.
+type Filter struct {
+	IncludeUsers  []string // user names or globs, eg "www-data" or "svc-*"; empty includes every user
+	ExcludeUsers  []string // user names or globs, eg "root" or "_*"
+	IncludeNames  []string // regular expressions matched against the process name; empty includes every name
+	ExcludeNames  []string // regular expressions matched against the process name
+	MinCPU        float64  // percent of one core
+	MinMem        float64  // percent of physical memory
+	KernelThreads bool     // include kernel threads such as kworker
+	Top           int      // maximum number of processes per list, 0 for all
+}
+
+// DefaultTop bounds the process lists unless a filter sets its own Top.
+// The lists are part of every snapshot, so they end up in the stream, the
+// history and the store.
+const DefaultTop = 25
+
+// DefaultFilter is the filter the config file and flags are merged into
+var DefaultFilter = Filter{Top: DefaultTop}
+
+// ListFilter is the filter applied by the cpu and memory collectors
+var ListFilter = DefaultFilter
+
+// FilterOverride changes some settings of a Filter. Nil fields keep the
+// setting; anything else replaces it, so an explicit zero or empty list
+// loosens the filter it is merged into.
+type FilterOverride struct {
+	IncludeUsers  []string
+	ExcludeUsers  []string
+	IncludeNames  []string
+	ExcludeNames  []string
+	MinCPU        *float64
+	MinMem        *float64
+	KernelThreads *bool
+	Top           *int
+}
+
+// Merge returns f with the settings of o replaced
+func (f Filter) Merge(o FilterOverride) Filter {
+	for _, list := range []struct {
+		dst *[]string
+		src []string
+	}{
+		{&f.IncludeUsers, o.IncludeUsers},
+		{&f.ExcludeUsers, o.ExcludeUsers},
+		{&f.IncludeNames, o.IncludeNames},
+		{&f.ExcludeNames, o.ExcludeNames},
+	} {
+		if list.src != nil {
+			*list.dst = slices.Clone(list.src)
+		}
+	}
+	if o.MinCPU != nil {
+		f.MinCPU = *o.MinCPU
+	}
+	if o.MinMem != nil {
+		f.MinMem = *o.MinMem
+	}
+	if o.KernelThreads != nil {
+		f.KernelThreads = *o.KernelThreads
+	}
+	if o.Top != nil {
+		f.Top = *o.Top
+	}
+	return f
+}
+
+// Validate checks the patterns and thresholds
+func (f Filter) Validate() error {
+	_, err := f.compile()
+	return err
+}
+
+// Apply returns the processes matching the filter, sorted in descending
+// order by sortKey, one of the Sort* keys, and cut to Top
+func (f Filter) Apply(processes []Process, sortKey string) ([]Process, error) {
+	m, err := f.compile()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := sortKeys[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort %q", sortKey)
+	}
+
+	matched := make([]Process, 0, len(processes))
+	for _, p := range processes {
+		if m.matches(p) {
+			matched = append(matched, p)
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b Process) int {
+		if c := key.compare(b, a); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Pid, b.Pid)
+	})
+
+	if f.Top > 0 && len(matched) > f.Top {
+		matched = matched[:f.Top]
+	}
+	return matched, nil
+}
+
+// matcher is a filter with its patterns compiled
+type matcher struct {
+	Filter
+	includeNames []*regexp.Regexp
+	excludeNames []*regexp.Regexp
+}
+
+func (f Filter) compile() (*matcher, error) {
+	if f.MinCPU < 0 || f.MinMem < 0 {
+		return nil, fmt.Errorf("minimum cpu and memory must not be negative")
+	}
+	if f.Top < 0 {
+		return nil, fmt.Errorf("top must not be negative")
+	}
+	for _, user := range slices.Concat(f.IncludeUsers, f.ExcludeUsers) {
+		if _, err := path.Match(user, ""); err != nil {
+			return nil, fmt.Errorf("invalid user pattern %q: %w", user, err)
+		}
+	}
+
+	m := &matcher{Filter: f}
+	for _, list := range []struct {
+		patterns []string
+		compiled *[]*regexp.Regexp
+	}{{f.IncludeNames, &m.includeNames}, {f.ExcludeNames, &m.excludeNames}} {
+		for _, pattern := range list.patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+			}
+			*list.compiled = append(*list.compiled, re)
+		}
+	}
+	return m, nil
+}
+
+func (m *matcher) matches(p Process) bool {
+	if !m.KernelThreads && IsKernelThread(p) {
+		return false
+	}
+	if len(m.IncludeUsers) > 0 && !matchUser(m.IncludeUsers, p.Username) {
+		return false
+	}
+	if matchUser(m.ExcludeUsers, p.Username) {
+		return false
+	}
+	if len(m.includeNames) > 0 && !matchName(m.includeNames, p.Name) {
+		return false
+	}
+	if matchName(m.excludeNames, p.Name) {
+		return false
+	}
+	return p.CPUPercent >= m.MinCPU && float64(p.MemPercent) >= m.MinMem
+}
+
+// IsKernelThread reports whether a process is a kernel thread. On Linux
+// they are kthreadd (pid 2) and its children, and have no command line.
+// Pid 0 is the kernel itself on macOS and the idle process on Windows.
+func IsKernelThread(p Process) bool {
+	if p.Pid == 0 {
+		return true
+	}
+	return p.Command == "" && (p.Pid == 2 || p.ParentPid == 2)
+}
+
+func matchUser(patterns []string, user string) bool {
+	for _, pattern := range patterns {
+		// validated by compile
+		if ok, _ := path.Match(pattern, user); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchName(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
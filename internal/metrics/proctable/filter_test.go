@@ -0,0 +1,163 @@
+package proctable
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		sort   string
+		want   []int32
+	}{
+		{
+			name: "kernel threads are hidden by default",
+			sort: SortCPU,
+			want: []int32{201, 101, 102, 200, 100, 1, 300, 301},
+		},
+		{
+			name:   "kernel threads",
+			filter: Filter{KernelThreads: true},
+			sort:   SortPid,
+			want:   []int32{301, 300, 201, 200, 102, 101, 100, 3, 2, 1},
+		},
+		{name: "include user glob", filter: Filter{IncludeUsers: []string{"www-*"}}, sort: SortCPU, want: []int32{101, 102}},
+		{name: "exclude users", filter: Filter{ExcludeUsers: []string{"root", "a*"}}, sort: SortCPU, want: []int32{201, 101, 102, 200}},
+		{name: "include names", filter: Filter{IncludeNames: []string{"^post", "^bash$"}}, sort: SortCPU, want: []int32{201, 200, 300}},
+		{name: "exclude names", filter: Filter{ExcludeNames: []string{"nginx|postgres"}}, sort: SortCPU, want: []int32{1, 300, 301}},
+		{name: "minimum cpu", filter: Filter{MinCPU: 2}, sort: SortCPU, want: []int32{201, 101, 102, 200}},
+		{name: "minimum memory", filter: Filter{MinMem: 2}, sort: SortMem, want: []int32{200, 201}},
+		{name: "top", filter: Filter{Top: 3}, sort: SortRSS, want: []int32{200, 201, 101}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.Apply(fixture().Processes, tt.sort)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if pids := pids(got); !reflect.DeepEqual(pids, tt.want) {
+				t.Errorf("Apply() pids = %v, want %v", pids, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{name: "zero value", filter: Filter{}},
+		{name: "default", filter: DefaultFilter},
+		{name: "negative cpu", filter: Filter{MinCPU: -1}, wantErr: true},
+		{name: "negative memory", filter: Filter{MinMem: -0.5}, wantErr: true},
+		{name: "negative top", filter: Filter{Top: -1}, wantErr: true},
+		{name: "user glob", filter: Filter{ExcludeUsers: []string{"["}}, wantErr: true},
+		{name: "name pattern", filter: Filter{IncludeNames: []string{"("}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := (Filter{}).Apply(fixture().Processes, "size"); err == nil {
+		t.Error("Apply() with an unknown sort succeeded")
+	}
+}
+
+func TestIsKernelThread(t *testing.T) {
+	tests := []struct {
+		name    string
+		process Process
+		want    bool
+	}{
+		{name: "kernel or idle process", process: Process{Pid: 0, Command: "idle"}, want: true},
+		{name: "kthreadd", process: Process{Pid: 2}, want: true},
+		{name: "child of kthreadd", process: Process{Pid: 40, ParentPid: 2}, want: true},
+		{name: "init", process: Process{Pid: 1, Command: "/sbin/init"}},
+		{name: "command line under kthreadd", process: Process{Pid: 41, ParentPid: 2, Command: "/usr/bin/helper"}},
+		{name: "zombie without a command line", process: Process{Pid: 301, ParentPid: 300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsKernelThread(tt.process); got != tt.want {
+				t.Errorf("IsKernelThread() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMerge(t *testing.T) {
+	zero := 0.0
+	half := 0.5
+	no := false
+	all := 0
+	ten := 10
+	base := Filter{
+		IncludeUsers:  []string{"www-data"},
+		ExcludeUsers:  []string{"root"},
+		IncludeNames:  []string{"^nginx$"},
+		ExcludeNames:  []string{"^kworker"},
+		MinCPU:        1,
+		MinMem:        2,
+		KernelThreads: true,
+		Top:           25,
+	}
+
+	tests := []struct {
+		name     string
+		override FilterOverride
+		want     Filter
+	}{
+		{name: "nothing set keeps the base", want: base},
+		{
+			name:     "values replace",
+			override: FilterOverride{ExcludeUsers: []string{"nobody"}, MinCPU: &half, Top: &ten},
+			want: Filter{
+				IncludeUsers:  []string{"www-data"},
+				ExcludeUsers:  []string{"nobody"},
+				IncludeNames:  []string{"^nginx$"},
+				ExcludeNames:  []string{"^kworker"},
+				MinCPU:        0.5,
+				MinMem:        2,
+				KernelThreads: true,
+				Top:           10,
+			},
+		},
+		{
+			name: "zero and empty values clear",
+			override: FilterOverride{
+				IncludeUsers:  []string{},
+				ExcludeUsers:  []string{},
+				IncludeNames:  []string{},
+				ExcludeNames:  []string{},
+				MinCPU:        &zero,
+				MinMem:        &zero,
+				KernelThreads: &no,
+				Top:           &all,
+			},
+			want: Filter{IncludeUsers: []string{}, ExcludeUsers: []string{}, IncludeNames: []string{}, ExcludeNames: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := base.Merge(tt.override); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// the merged filter does not share its lists with the override
+	users := []string{"alice"}
+	merged := base.Merge(FilterOverride{IncludeUsers: users})
+	users[0] = "mallory"
+	if merged.IncludeUsers[0] != "alice" {
+		t.Errorf("Merge() shares its lists with the override: %v", merged.IncludeUsers)
+	}
+}
@@ -0,0 +1,280 @@
+// Package proctable keeps one table of the running processes that is shared
+// by every collector reporting on processes. Process handles are cached
+// across refreshes so static details are only read once, and CPU usage is
+// measured between two consecutive refreshes rather than averaged over the
+// lifetime of the process.
+package proctable
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+const logtag string = "proctable"
+
+const (
+	// MaxAge is how long a refresh is reused, so collectors running in the
+	// same round share one walk of the process list
+	MaxAge = 2 * time.Second
+	// PrimeWindow is how long the first refresh waits between its two
+	// samples, as CPU usage needs a previous sample to compare against
+	PrimeWindow = 500 * time.Millisecond
+)
+
+// ErrNotFound is returned when a process is not in the table
+var ErrNotFound = errors.New("process not found")
+
+// Process is one row of the table
+type Process struct {
+	Pid          int32     `json:"pid"`
+	ParentPid    int32     `json:"parentPid"`
+	ChildrenPids []int32   `json:"childrenPids"`
+	Name         string    `json:"name"`
+	Command      string    `json:"command"` // full command line
+	Username     string    `json:"username"`
+	Status       string    `json:"status"` // eg "running", "sleep" or "zombie"
+	CreateTime   time.Time `json:"createTime"`
+	NumThreads   int32     `json:"numThreads"`
+	CPUPercent   float64   `json:"cpuPercent"` // of one core, over the interval since the previous refresh
+	CPUSeconds   float64   `json:"cpuSeconds"` // user and system time since the process started
+	MemPercent   float32   `json:"memPercent"`
+	Memory       Memory    `json:"memory"`
+}
+
+// Memory is the memory usage of a process in bytes
+type Memory struct {
+	RSS    uint64 `json:"rss"`
+	VMS    uint64 `json:"vms"`
+	HWM    uint64 `json:"hwm"`
+	Data   uint64 `json:"data"`
+	Stack  uint64 `json:"stack"`
+	Locked uint64 `json:"locked"`
+	Swap   uint64 `json:"swap"`
+}
+
+// Snapshot is the table as read by one refresh
+type Snapshot struct {
+	At        time.Time `json:"at"`
+	Window    float64   `json:"windowSeconds"` // time covered by CPUPercent
+	Processes []Process `json:"processes"`
+}
+
+// entry caches a process handle and the details that do not change while
+// the process runs
+type entry struct {
+	proc       *process.Process
+	name       string
+	command    string
+	username   string
+	createTime int64
+	startTime  int64 // identifies the process, see startTime
+	cpuSeconds float64
+	seenAt     time.Time
+}
+
+// Table caches process handles between refreshes
+type Table struct {
+	mu      sync.Mutex
+	entries map[int32]*entry
+	last    *Snapshot
+	lastAt  time.Time // of the last refresh, including the baseline one
+}
+
+// Default is the table shared by the built-in collectors
+var Default = New()
+
+// New returns an empty table
+func New() *Table {
+	return &Table{entries: make(map[int32]*entry)}
+}
+
+// Get returns the default table, refreshed if it is older than MaxAge
+func Get(ctx context.Context) (*Snapshot, error) {
+	return Default.Get(ctx)
+}
+
+// Get returns the table, refreshed if it is older than MaxAge. The returned
+// snapshot is shared and must not be modified.
+func (t *Table) Get(ctx context.Context) (*Snapshot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.last != nil && time.Since(t.last.At) < MaxAge {
+		return t.last, nil
+	}
+
+	if t.lastAt.IsZero() {
+		// the first sample only serves as a baseline for CPU usage
+		if _, err := t.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if t.last == nil {
+		// wait out PrimeWindow after the baseline without holding the lock,
+		// then use the snapshot of whichever caller refreshed first
+		if wait := time.Until(t.lastAt.Add(PrimeWindow)); wait > 0 {
+			t.mu.Unlock()
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				t.mu.Lock()
+				return nil, ctx.Err()
+			}
+			t.mu.Lock()
+			if t.last != nil && time.Since(t.last.At) < MaxAge {
+				return t.last, nil
+			}
+		}
+	}
+
+	snapshot, err := t.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.last = snapshot
+	return snapshot, nil
+}
+
+func (t *Table) refresh(ctx context.Context) (*Snapshot, error) {
+	logging.Info(logtag, "refreshing process table")
+	pids, err := process.PidsWithContext(ctx)
+	if err != nil {
+		logging.Error(logtag, "error listing processes", err)
+		return nil, err
+	}
+
+	var memTotal uint64
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		memTotal = vm.Total
+	}
+
+	now := time.Now()
+	var window time.Duration
+	if !t.lastAt.IsZero() {
+		window = now.Sub(t.lastAt)
+	}
+
+	live := make(map[int32]bool, len(pids))
+	processes := make([]Process, 0, len(pids))
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		e, err := t.lookup(ctx, pid)
+		if err != nil {
+			continue
+		}
+		times, err := e.proc.TimesWithContext(ctx)
+		if err != nil {
+			// the process exited while the table was read
+			delete(t.entries, pid)
+			continue
+		}
+		live[pid] = true
+
+		p := Process{
+			Pid:        pid,
+			Name:       e.name,
+			Command:    e.command,
+			Username:   e.username,
+			CreateTime: time.UnixMilli(e.createTime),
+			CPUSeconds: times.User + times.System,
+		}
+		p.CPUPercent = cpuPercent(e, p.CPUSeconds, now)
+		e.cpuSeconds = p.CPUSeconds
+		e.seenAt = now
+
+		p.ParentPid, _ = e.proc.PpidWithContext(ctx)
+		p.NumThreads, _ = e.proc.NumThreadsWithContext(ctx)
+		if status, err := e.proc.StatusWithContext(ctx); err == nil && len(status) > 0 {
+			p.Status = status[0]
+		}
+		if m, err := e.proc.MemoryInfoWithContext(ctx); err == nil {
+			p.Memory = Memory{RSS: m.RSS, VMS: m.VMS, HWM: m.HWM, Data: m.Data, Stack: m.Stack, Locked: m.Locked, Swap: m.Swap}
+			if memTotal > 0 {
+				p.MemPercent = float32(float64(m.RSS) / float64(memTotal) * 100)
+			}
+		}
+		processes = append(processes, p)
+	}
+
+	for pid := range t.entries {
+		if !live[pid] {
+			delete(t.entries, pid)
+		}
+	}
+
+	linkChildren(processes)
+	t.lastAt = now
+	return &Snapshot{At: now, Window: window.Seconds(), Processes: processes}, nil
+}
+
+// lookup returns the cached entry of pid, replacing it if the pid has been
+// reused by a new process since the previous refresh
+func (t *Table) lookup(ctx context.Context, pid int32) (*entry, error) {
+	started, err := startTime(ctx, pid)
+	if err != nil {
+		delete(t.entries, pid)
+		return nil, err
+	}
+
+	if e, ok := t.entries[pid]; ok && e.startTime == started {
+		return e, nil
+	}
+
+	proc := &process.Process{Pid: pid}
+	createTime, err := proc.CreateTimeWithContext(ctx)
+	if err != nil {
+		delete(t.entries, pid)
+		return nil, err
+	}
+
+	e := &entry{proc: proc, createTime: createTime, startTime: started}
+	e.name, _ = proc.NameWithContext(ctx)
+	e.command, _ = proc.CmdlineWithContext(ctx)
+	e.username, _ = proc.UsernameWithContext(ctx)
+	t.entries[pid] = e
+	return e, nil
+}
+
+// cpuPercent is the CPU used since the previous refresh. A process started
+// since then is measured from its start.
+func cpuPercent(e *entry, cpuSeconds float64, now time.Time) float64 {
+	since := e.seenAt
+	used := cpuSeconds - e.cpuSeconds
+	if since.IsZero() {
+		since = time.UnixMilli(e.createTime)
+		used = cpuSeconds
+	}
+
+	elapsed := now.Sub(since).Seconds()
+	if elapsed <= 0 || used < 0 {
+		return 0
+	}
+	return used / elapsed * 100
+}
+
+func linkChildren(processes []Process) {
+	index := make(map[int32]int, len(processes))
+	for i, p := range processes {
+		index[p.Pid] = i
+	}
+	for _, p := range processes {
+		if i, ok := index[p.ParentPid]; ok && p.ParentPid != p.Pid {
+			processes[i].ChildrenPids = append(processes[i].ChildrenPids, p.Pid)
+		}
+	}
+	for i := range processes {
+		sort.Slice(processes[i].ChildrenPids, func(a, b int) bool {
+			return processes[i].ChildrenPids[a] < processes[i].ChildrenPids[b]
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package proctable
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort keys accepted by Query.Sort
+const (
+	SortPid     = "pid"
+	SortName    = "name"
+	SortUser    = "user"
+	SortState   = "state"
+	SortCPU     = "cpu"
+	SortMem     = "mem"
+	SortRSS     = "rss"
+	SortThreads = "threads"
+	SortCPUTime = "cputime"
+	SortStarted = "started"
+)
+
+// sortKeys compares two processes in ascending order for each sort key.
+// Numeric keys are sorted in descending order by default.
+var sortKeys = map[string]struct {
+	compare    func(a, b Process) int
+	descending bool
+}{
+	SortPid:     {func(a, b Process) int { return cmp.Compare(a.Pid, b.Pid) }, false},
+	SortName:    {func(a, b Process) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) }, false},
+	SortUser:    {func(a, b Process) int { return strings.Compare(a.Username, b.Username) }, false},
+	SortState:   {func(a, b Process) int { return strings.Compare(a.Status, b.Status) }, false},
+	SortCPU:     {func(a, b Process) int { return cmp.Compare(a.CPUPercent, b.CPUPercent) }, true},
+	SortMem:     {func(a, b Process) int { return cmp.Compare(a.MemPercent, b.MemPercent) }, true},
+	SortRSS:     {func(a, b Process) int { return cmp.Compare(a.Memory.RSS, b.Memory.RSS) }, true},
+	SortThreads: {func(a, b Process) int { return cmp.Compare(a.NumThreads, b.NumThreads) }, true},
+	SortCPUTime: {func(a, b Process) int { return cmp.Compare(a.CPUSeconds, b.CPUSeconds) }, true},
+	SortStarted: {func(a, b Process) int { return a.CreateTime.Compare(b.CreateTime) }, true},
+}
+
+// SortKeys returns the accepted sort keys
+func SortKeys() []string {
+	keys := make([]string, 0, len(sortKeys))
+	for key := range sortKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Query selects, orders and limits the rows of a snapshot. The zero value
+// returns every process sorted by CPU usage.
+type Query struct {
+	User   string  // exact user name
+	Name   string  // regular expression matched against the process name
+	State  string  // eg "running", "sleep" or "zombie"
+	MinCPU float64 // percent of one core
+	MinMem float64 // percent of physical memory
+	Sort   string  // one of the Sort* keys, defaults to SortCPU
+	Order  string  // "asc" or "desc", defaults to descending for numeric keys
+	Top    int     // maximum number of rows, 0 for all
+}
+
+// Result is the outcome of a query
+type Result struct {
+	At        time.Time `json:"at"`
+	Window    float64   `json:"windowSeconds"`
+	Total     int       `json:"total"`   // processes in the table
+	Matched   int       `json:"matched"` // processes matching the filters, before Top
+	Processes []Process `json:"processes"`
+}
+
+// Run applies the query to a snapshot
+func (q Query) Run(s *Snapshot) (*Result, error) {
+	var name *regexp.Regexp
+	if q.Name != "" {
+		var err error
+		if name, err = regexp.Compile(q.Name); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", q.Name, err)
+		}
+	}
+
+	sortKey := q.Sort
+	if sortKey == "" {
+		sortKey = SortCPU
+	}
+	key, ok := sortKeys[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort %q, must be one of %s", q.Sort, strings.Join(SortKeys(), ", "))
+	}
+	descending := key.descending
+	switch q.Order {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		return nil, fmt.Errorf("invalid order %q, must be asc or desc", q.Order)
+	}
+	if q.Top < 0 {
+		return nil, fmt.Errorf("top must not be negative")
+	}
+
+	matched := make([]Process, 0, len(s.Processes))
+	for _, p := range s.Processes {
+		if q.User != "" && p.Username != q.User {
+			continue
+		}
+		if name != nil && !name.MatchString(p.Name) {
+			continue
+		}
+		if q.State != "" && !strings.EqualFold(p.Status, q.State) {
+			continue
+		}
+		if p.CPUPercent < q.MinCPU || float64(p.MemPercent) < q.MinMem {
+			continue
+		}
+		matched = append(matched, p)
+	}
+
+	slices.SortStableFunc(matched, func(a, b Process) int {
+		c := key.compare(a, b)
+		if descending {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Pid, b.Pid)
+		}
+		return c
+	})
+
+	result := &Result{
+		At:        s.At,
+		Window:    s.Window,
+		Total:     len(s.Processes),
+		Matched:   len(matched),
+		Processes: matched,
+	}
+	if q.Top > 0 && len(matched) > q.Top {
+		result.Processes = matched[:q.Top]
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package proctable
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var fixtureAt = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// fixture is a small process table: init with nginx, postgres and a shell
+// below it, and kthreadd with one kernel thread
+func fixture() *Snapshot {
+	proc := func(pid, ppid int32, name, command, user, status string, cpu float64, mem float32, rss uint64, threads int32) Process {
+		return Process{
+			Pid:        pid,
+			ParentPid:  ppid,
+			Name:       name,
+			Command:    command,
+			Username:   user,
+			Status:     status,
+			CreateTime: fixtureAt.Add(time.Duration(pid) * time.Second),
+			NumThreads: threads,
+			CPUPercent: cpu,
+			CPUSeconds: cpu * 10,
+			MemPercent: mem,
+			Memory:     Memory{RSS: rss},
+		}
+	}
+	return &Snapshot{
+		At:     fixtureAt,
+		Window: 1,
+		Processes: []Process{
+			proc(1, 0, "systemd", "/sbin/init", "root", "sleep", 0.5, 0.1, 10, 1),
+			proc(2, 0, "kthreadd", "", "root", "sleep", 0, 0, 0, 1),
+			proc(3, 2, "kworker/0:1", "", "root", "idle", 0, 0, 0, 1),
+			proc(100, 1, "nginx", "nginx: master process", "root", "sleep", 1, 0.5, 20, 1),
+			proc(101, 100, "nginx", "nginx: worker process", "www-data", "running", 10, 1.5, 30, 4),
+			proc(102, 100, "nginx", "nginx: worker process", "www-data", "running", 5, 1.5, 30, 4),
+			proc(200, 1, "postgres", "/usr/bin/postgres", "postgres", "sleep", 2, 5, 200, 1),
+			proc(201, 200, "postgres", "postgres: checkpointer", "postgres", "running", 20, 2, 50, 1),
+			proc(300, 1, "bash", "-bash", "alice", "sleep", 0, 0.2, 5, 1),
+			// a zombie has no command line either, but is no kernel thread
+			proc(301, 300, "defunct", "", "alice", "zombie", 0, 0, 0, 1),
+		},
+	}
+}
+
+func pids(processes []Process) []int32 {
+	result := []int32{}
+	for _, p := range processes {
+		result = append(result, p.Pid)
+	}
+	return result
+}
+
+func TestQueryRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       Query
+		want        []int32
+		wantMatched int
+	}{
+		{
+			name:  "cpu descending by default, ties by pid",
+			query: Query{},
+			want:  []int32{201, 101, 102, 200, 100, 1, 2, 3, 300, 301},
+		},
+		{name: "user", query: Query{User: "www-data"}, want: []int32{101, 102}},
+		{name: "user is exact", query: Query{User: "www"}, want: []int32{}},
+		{name: "name pattern", query: Query{Name: "^ng"}, want: []int32{101, 102, 100}},
+		{name: "state ignores case", query: Query{State: "RUNNING"}, want: []int32{201, 101, 102}},
+		{name: "minimum cpu", query: Query{MinCPU: 5}, want: []int32{201, 101, 102}},
+		{name: "minimum memory", query: Query{MinMem: 1.5}, want: []int32{201, 101, 102, 200}},
+		{
+			name:  "name ascending by default",
+			query: Query{Sort: SortName},
+			want:  []int32{300, 301, 2, 3, 100, 101, 102, 200, 201, 1},
+		},
+		{name: "user sort", query: Query{Sort: SortUser, Top: 3}, want: []int32{300, 301, 200}, wantMatched: 10},
+		{name: "state sort", query: Query{Sort: SortState, Order: "desc", Top: 1}, want: []int32{301}, wantMatched: 10},
+		{name: "pid descending", query: Query{Sort: SortPid, Order: "desc", Top: 2}, want: []int32{301, 300}, wantMatched: 10},
+		{name: "rss ascending", query: Query{Sort: SortRSS, Order: "asc", MinMem: 0.1}, want: []int32{300, 1, 100, 101, 102, 201, 200}},
+		{name: "memory", query: Query{Sort: SortMem, Top: 2}, want: []int32{200, 201}, wantMatched: 10},
+		{name: "threads", query: Query{Sort: SortThreads, Top: 2}, want: []int32{101, 102}, wantMatched: 10},
+		{name: "cpu time", query: Query{Sort: SortCPUTime, Top: 1}, want: []int32{201}, wantMatched: 10},
+		{name: "newest first", query: Query{Sort: SortStarted, Top: 2}, want: []int32{301, 300}, wantMatched: 10},
+		{
+			name:        "top counts the matches before the cut",
+			query:       Query{User: "www-data", Top: 1},
+			want:        []int32{101},
+			wantMatched: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.query.Run(fixture())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := pids(result.Processes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run() pids = %v, want %v", got, tt.want)
+			}
+			wantMatched := tt.wantMatched
+			if wantMatched == 0 {
+				wantMatched = len(tt.want)
+			}
+			if result.Matched != wantMatched || result.Total != 10 || !result.At.Equal(fixtureAt) {
+				t.Errorf("Run() matched %d of %d at %s, want %d of 10 at %s", result.Matched, result.Total, result.At, wantMatched, fixtureAt)
+			}
+		})
+	}
+}
+
+func TestQueryRunErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+	}{
+		{name: "name pattern", query: Query{Name: "("}},
+		{name: "sort", query: Query{Sort: "size"}},
+		{name: "order", query: Query{Order: "up"}},
+		{name: "top", query: Query{Top: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result, err := tt.query.Run(fixture()); err == nil {
+				t.Errorf("Run() = %+v, want an error", result)
+			}
+		})
+	}
+}
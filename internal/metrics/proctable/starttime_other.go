@@ -0,0 +1,17 @@
+//go:build !linux
+
+package proctable
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// startTime identifies the process running as pid, so a cached entry can be
+// told apart from a new process that reused the pid. Without procfs it is
+// the create time read through a new handle, as handles cache it.
+func startTime(ctx context.Context, pid int32) (int64, error) {
+	proc := &process.Process{Pid: pid}
+	return proc.CreateTimeWithContext(ctx)
+}
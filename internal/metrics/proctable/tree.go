@@ -0,0 +1,180 @@
+package proctable
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// Rollup sums the usage of a process and all of its descendants
+type Rollup struct {
+	Processes  int     `json:"processes"`
+	CPUPercent float64 `json:"cpuPercent"`
+	RSS        uint64  `json:"rss"`
+	NumThreads int32   `json:"numThreads"`
+}
+
+// Node is a process in the tree with the usage of its whole subtree
+type Node struct {
+	Process
+	Subtree  Rollup  `json:"subtree"`
+	Children []*Node `json:"children"`
+}
+
+// Tree builds the process tree. Processes whose parent is not in the table,
+// such as init or processes whose parent exited between reads, are roots.
+func (s *Snapshot) Tree() []*Node {
+	nodes := make(map[int32]*Node, len(s.Processes))
+	for _, p := range s.Processes {
+		nodes[p.Pid] = &Node{Process: p}
+	}
+
+	var roots []*Node
+	for _, p := range s.Processes {
+		node := nodes[p.Pid]
+		if parent, ok := nodes[p.ParentPid]; ok && p.ParentPid != p.Pid {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	// every process has at most one parent, so the walk from the roots
+	// visits each process once. Processes in a parent cycle, which a pid
+	// reused between reads could in theory produce, are not reachable.
+	for _, root := range roots {
+		root.rollup()
+	}
+	return roots
+}
+
+// rollup fills in the subtree totals
+func (n *Node) rollup() Rollup {
+	n.Subtree = Rollup{
+		Processes:  1,
+		CPUPercent: n.CPUPercent,
+		RSS:        n.Memory.RSS,
+		NumThreads: n.NumThreads,
+	}
+	for _, child := range n.Children {
+		r := child.rollup()
+		n.Subtree.Processes += r.Processes
+		n.Subtree.CPUPercent += r.CPUPercent
+		n.Subtree.RSS += r.RSS
+		n.Subtree.NumThreads += r.NumThreads
+	}
+	return n.Subtree
+}
+
+// treeSortKeys order siblings, biggest subtree first except for pid
+var treeSortKeys = map[string]func(a, b *Node) int{
+	SortPid:     func(a, b *Node) int { return cmp.Compare(a.Pid, b.Pid) },
+	SortCPU:     func(a, b *Node) int { return cmp.Compare(b.Subtree.CPUPercent, a.Subtree.CPUPercent) },
+	SortRSS:     func(a, b *Node) int { return cmp.Compare(b.Subtree.RSS, a.Subtree.RSS) },
+	SortThreads: func(a, b *Node) int { return cmp.Compare(b.Subtree.NumThreads, a.Subtree.NumThreads) },
+}
+
+// TreeQuery selects the subtrees to return. The zero value returns the whole
+// tree with the biggest subtrees by RSS first.
+type TreeQuery struct {
+	Pid   int32  // only the subtree of this process
+	Name  string // regular expression; only the subtrees of matching processes
+	Sort  string // pid, cpu, rss (default) or threads
+	Depth int    // levels of children to include below each root, 0 for all
+}
+
+// TreeResult is the outcome of a tree query. Subtree totals always cover
+// every descendant, even those cut off by Depth.
+type TreeResult struct {
+	At     time.Time `json:"at"`
+	Window float64   `json:"windowSeconds"`
+	Total  int       `json:"total"`
+	Roots  []*Node   `json:"roots"`
+}
+
+// Run applies the query to a snapshot. It returns ErrNotFound when Pid is
+// not in the table.
+func (q TreeQuery) Run(s *Snapshot) (*TreeResult, error) {
+	var name *regexp.Regexp
+	if q.Name != "" {
+		var err error
+		if name, err = regexp.Compile(q.Name); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", q.Name, err)
+		}
+	}
+
+	sortKey := q.Sort
+	if sortKey == "" {
+		sortKey = SortRSS
+	}
+	compare, ok := treeSortKeys[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort %q, must be one of pid, cpu, rss or threads", q.Sort)
+	}
+	if q.Depth < 0 {
+		return nil, fmt.Errorf("depth must not be negative")
+	}
+
+	roots := s.Tree()
+	if q.Pid != 0 {
+		node := find(roots, q.Pid)
+		if node == nil {
+			return nil, fmt.Errorf("pid %d: %w", q.Pid, ErrNotFound)
+		}
+		roots = []*Node{node}
+	}
+	if name != nil {
+		roots = matching(roots, name)
+	}
+
+	if roots == nil {
+		roots = []*Node{}
+	}
+	for _, root := range roots {
+		prune(root, compare, q.Depth)
+	}
+	slices.SortStableFunc(roots, compare)
+
+	return &TreeResult{At: s.At, Window: s.Window, Total: len(s.Processes), Roots: roots}, nil
+}
+
+func find(nodes []*Node, pid int32) *Node {
+	for _, n := range nodes {
+		if n.Pid == pid {
+			return n
+		}
+		if found := find(n.Children, pid); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// matching returns the topmost nodes whose name matches, so a matching
+// process nested in another matching process is only listed once
+func matching(nodes []*Node, name *regexp.Regexp) []*Node {
+	var found []*Node
+	for _, n := range nodes {
+		if name.MatchString(n.Name) {
+			found = append(found, n)
+			continue
+		}
+		found = append(found, matching(n.Children, name)...)
+	}
+	return found
+}
+
+// prune sorts the children of n and drops those more than depth levels
+// below it
+func prune(n *Node, compare func(a, b *Node) int, depth int) {
+	slices.SortStableFunc(n.Children, compare)
+	for _, child := range n.Children {
+		if depth == 1 {
+			child.Children = nil
+			continue
+		}
+		prune(child, compare, max(depth-1, 0))
+	}
+}
@@ -0,0 +1,136 @@
+package proctable
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// shape renders nodes and their children as "1(100(101 102) 300)"
+func shape(nodes []*Node) string {
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		part := fmt.Sprint(n.Pid)
+		if len(n.Children) > 0 {
+			part += "(" + shape(n.Children) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTreeQueryRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		query TreeQuery
+		want  string
+	}{
+		{
+			name: "biggest subtrees by rss first",
+			want: "1(200(201) 100(101 102) 300(301)) 2(3)",
+		},
+		{
+			name:  "pid",
+			query: TreeQuery{Sort: SortPid},
+			want:  "1(100(101 102) 200(201) 300(301)) 2(3)",
+		},
+		{
+			name:  "cpu",
+			query: TreeQuery{Sort: SortCPU},
+			want:  "1(200(201) 100(101 102) 300(301)) 2(3)",
+		},
+		{
+			name:  "threads",
+			query: TreeQuery{Sort: SortThreads},
+			want:  "1(100(101 102) 200(201) 300(301)) 2(3)",
+		},
+		{
+			name:  "subtree of a pid",
+			query: TreeQuery{Pid: 100},
+			want:  "100(101 102)",
+		},
+		{
+			// the workers match as well but are inside the master's subtree
+			name:  "topmost matching names",
+			query: TreeQuery{Name: "^(nginx|postgres)$"},
+			want:  "200(201) 100(101 102)",
+		},
+		{
+			name:  "no matching names",
+			query: TreeQuery{Name: "^redis$"},
+			want:  "",
+		},
+		{
+			name:  "depth",
+			query: TreeQuery{Depth: 1, Sort: SortPid},
+			want:  "1(100 200 300) 2(3)",
+		},
+		{
+			name:  "depth below a pid",
+			query: TreeQuery{Pid: 1, Depth: 2, Sort: SortPid},
+			want:  "1(100(101 102) 200(201) 300(301))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.query.Run(fixture())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := shape(result.Roots); got != tt.want {
+				t.Errorf("Run() = %s, want %s", got, tt.want)
+			}
+			if result.Roots == nil || result.Total != 10 {
+				t.Errorf("Run() roots = %v, total = %d, want a non-nil list of 10", result.Roots, result.Total)
+			}
+		})
+	}
+}
+
+func TestTreeRollup(t *testing.T) {
+	// totals cover the whole subtree even when depth cuts it off
+	result, err := TreeQuery{Depth: 1}.Run(fixture())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	systemd, nginx := result.Roots[0], result.Roots[0].Children[1]
+	if want := (Rollup{Processes: 8, CPUPercent: 38.5, RSS: 345, NumThreads: 14}); systemd.Subtree != want {
+		t.Errorf("systemd subtree = %+v, want %+v", systemd.Subtree, want)
+	}
+	if want := (Rollup{Processes: 3, CPUPercent: 16, RSS: 80, NumThreads: 9}); nginx.Pid != 100 || nginx.Subtree != want {
+		t.Errorf("pid %d subtree = %+v, want nginx with %+v", nginx.Pid, nginx.Subtree, want)
+	}
+}
+
+func TestTreeOrphansAndLoops(t *testing.T) {
+	s := &Snapshot{Processes: []Process{
+		{Pid: 10, ParentPid: 9},  // parent exited between reads
+		{Pid: 11, ParentPid: 11}, // its own parent
+		{Pid: 12, ParentPid: 10},
+	}}
+	if got, want := shape(s.Tree()), "10(12) 11"; got != want {
+		t.Errorf("Tree() = %s, want %s", got, want)
+	}
+}
+
+func TestTreeQueryRunErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   TreeQuery
+		wantErr error
+	}{
+		{name: "unknown pid", query: TreeQuery{Pid: 999}, wantErr: ErrNotFound},
+		{name: "name pattern", query: TreeQuery{Name: "("}},
+		{name: "sort", query: TreeQuery{Sort: SortName}},
+		{name: "depth", query: TreeQuery{Depth: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.query.Run(fixture())
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
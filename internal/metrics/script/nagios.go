@@ -0,0 +1,152 @@
+package script
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseNagios parses plugin output in the nagios format:
+//
+//	TEXT OUTPUT | PERFDATA
+//	LONG TEXT LINE 1
+//	LONG TEXT LINE 2 | PERFDATA
+//	PERFDATA
+//
+// Everything after the first "|" on a long text line is perfdata.
+func parseNagios(out string) *Result {
+	result := &Result{Format: FormatNagios}
+
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	first, perf, _ := strings.Cut(lines[0], "|")
+	result.Output = strings.TrimSpace(first)
+	perfText := []string{perf}
+
+	var long []string
+	inPerf := false
+	for _, line := range lines[1:] {
+		if inPerf {
+			perfText = append(perfText, line)
+			continue
+		}
+		text, rest, found := strings.Cut(line, "|")
+		long = append(long, text)
+		if found {
+			perfText = append(perfText, rest)
+			inPerf = true
+		}
+	}
+	result.LongOutput = strings.TrimSpace(strings.Join(long, "\n"))
+
+	for _, text := range perfText {
+		result.PerfData = append(result.PerfData, parsePerfData(text)...)
+	}
+	return result
+}
+
+// parsePerfData parses space separated 'label'=value[UOM];[warn];[crit];[min];[max]
+// entries. Entries that cannot be parsed, including "U" (unknown) values,
+// are skipped.
+func parsePerfData(text string) []PerfData {
+	var results []PerfData
+	for _, token := range splitPerfData(text) {
+		label, data, found := splitLabel(token)
+		if !found || label == "" {
+			continue
+		}
+
+		fields := strings.Split(data, ";")
+		value, unit := splitUnit(fields[0])
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		p := PerfData{Label: label, Value: v, Unit: unit}
+		if len(fields) > 1 {
+			p.Warn = fields[1]
+		}
+		if len(fields) > 2 {
+			p.Crit = fields[2]
+		}
+		if len(fields) > 3 {
+			p.Min = parseOptionalFloat(fields[3])
+		}
+		if len(fields) > 4 {
+			p.Max = parseOptionalFloat(fields[4])
+		}
+		results = append(results, p)
+	}
+	return results
+}
+
+// splitPerfData splits on whitespace outside single quoted labels
+func splitPerfData(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range text {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			current.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// splitLabel separates label=data. A label in single quotes may contain
+// spaces and "=", and writes a single quote as two.
+func splitLabel(token string) (string, string, bool) {
+	if !strings.HasPrefix(token, "'") {
+		return strings.Cut(token, "=")
+	}
+	for i := 1; i < len(token); i++ {
+		if token[i] != '\'' {
+			continue
+		}
+		if i+1 < len(token) && token[i+1] == '\'' {
+			i++
+			continue
+		}
+		data, found := strings.CutPrefix(token[i+1:], "=")
+		return strings.ReplaceAll(token[1:i], "''", "'"), data, found
+	}
+	return "", "", false
+}
+
+// splitUnit separates a value like "12.5ms" or "2.5E-2s" into the longest
+// prefix that parses as a number, "12.5" or "2.5E-2", and the unit
+func splitUnit(s string) (string, string) {
+	// only plain decimal numbers: ParseFloat would also take "inf", "nan"
+	// and hex, which are not perfdata values
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.+-eE", r)
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	for i := end; i > 0; i-- {
+		if _, err := strconv.ParseFloat(s[:i], 64); err == nil {
+			return s[:i], s[i:]
+		}
+	}
+	return s[:end], s[end:]
+}
+
+func parseOptionalFloat(s string) *float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,151 @@
+package script
+
+import (
+	"reflect"
+	"testing"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestSplitUnit(t *testing.T) {
+	tests := []struct {
+		in, value, unit string
+	}{
+		{in: "12", value: "12"},
+		{in: "0.5s", value: "0.5", unit: "s"},
+		{in: "12.5ms", value: "12.5", unit: "ms"},
+		{in: "99%", value: "99", unit: "%"},
+		{in: "1024B", value: "1024", unit: "B"},
+		{in: "512KB", value: "512", unit: "KB"},
+		{in: "314c", value: "314", unit: "c"},
+		{in: "-3.5", value: "-3.5"},
+		{in: "2.5E-2s", value: "2.5E-2", unit: "s"},
+		{in: "1e3", value: "1e3"},
+		// an "e" that does not start an exponent belongs to the unit
+		{in: "5events", value: "5", unit: "events"},
+		{in: "U", unit: "U"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			value, unit := splitUnit(tt.in)
+			if value != tt.value || unit != tt.unit {
+				t.Errorf("splitUnit(%q) = %q, %q, want %q, %q", tt.in, value, unit, tt.value, tt.unit)
+			}
+		})
+	}
+}
+
+func TestParsePerfData(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []PerfData
+	}{
+		{
+			name: "all fields",
+			text: "time=0.012s;0.5;1.0;0;10",
+			want: []PerfData{{Label: "time", Value: 0.012, Unit: "s", Warn: "0.5", Crit: "1.0", Min: float(0), Max: float(10)}},
+		},
+		{
+			name: "ranges and empty fields",
+			text: "load1=0.5;@1:2;~:5;; users=3;;;0",
+			want: []PerfData{
+				{Label: "load1", Value: 0.5, Warn: "@1:2", Crit: "~:5"},
+				{Label: "users", Value: 3, Min: float(0)},
+			},
+		},
+		{
+			name: "quoted labels",
+			text: "'disk usage /'=45%;80;90 'it''s'=1 'a=b'=2",
+			want: []PerfData{
+				{Label: "disk usage /", Value: 45, Unit: "%", Warn: "80", Crit: "90"},
+				{Label: "it's", Value: 1},
+				{Label: "a=b", Value: 2},
+			},
+		},
+		{
+			name: "exponent values",
+			text: "rate=1.5e3c latency=2.5E-2s",
+			want: []PerfData{
+				{Label: "rate", Value: 1500, Unit: "c"},
+				{Label: "latency", Value: 0.025, Unit: "s"},
+			},
+		},
+		{
+			name: "unknown and malformed entries are skipped",
+			text: "ok=1 unknown=U;1;2 noequals ''=3 'unterminated=4 bad=x",
+			want: []PerfData{{Label: "ok", Value: 1}},
+		},
+		{
+			name: "whitespace",
+			text: "  a=1KB\tb=2MB  ",
+			want: []PerfData{{Label: "a", Value: 1, Unit: "KB"}, {Label: "b", Value: 2, Unit: "MB"}},
+		},
+		{
+			name: "empty",
+			text: " ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePerfData(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePerfData(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNagios(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want *Result
+	}{
+		{
+			name: "output only",
+			out:  "DISK OK\n",
+			want: &Result{Format: FormatNagios, Output: "DISK OK"},
+		},
+		{
+			name: "perfdata on the first line",
+			out:  "PING OK - rta 0.1ms | rta=0.1ms;100;500;0 pl=0%;20;60\n",
+			want: &Result{Format: FormatNagios, Output: "PING OK - rta 0.1ms", PerfData: []PerfData{
+				{Label: "rta", Value: 0.1, Unit: "ms", Warn: "100", Crit: "500", Min: float(0)},
+				{Label: "pl", Value: 0, Unit: "%", Warn: "20", Crit: "60"},
+			}},
+		},
+		{
+			// perfdata continues on a long text line after its "|" and on
+			// every line after that
+			name: "perfdata on long output lines",
+			out: "DISK WARNING | '/'=80%\n" +
+				"/ is 80% full\n" +
+				"/home is 10% full | '/home'=10%\n" +
+				"'/var'=30%;80;90\n",
+			want: &Result{
+				Format:     FormatNagios,
+				Output:     "DISK WARNING",
+				LongOutput: "/ is 80% full\n/home is 10% full",
+				PerfData: []PerfData{
+					{Label: "/", Value: 80, Unit: "%"},
+					{Label: "/home", Value: 10, Unit: "%"},
+					{Label: "/var", Value: 30, Unit: "%", Warn: "80", Crit: "90"},
+				},
+			},
+		},
+		{
+			name: "long output without perfdata",
+			out:  "OK\nline one\nline two\n",
+			want: &Result{Format: FormatNagios, Output: "OK", LongOutput: "line one\nline two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseNagios(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNagios() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
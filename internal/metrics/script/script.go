@@ -0,0 +1,184 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+const logtag string = "script"
+
+const (
+	FormatJSON   = "json"
+	FormatNagios = "nagios"
+)
+
+// DefaultMaxOutputBytes caps how much output is read from a check
+const DefaultMaxOutputBytes int64 = 1024 * 1024
+
+// maxStderrBytes caps how much stderr is kept for error messages
+const maxStderrBytes = 4 * 1024
+
+// waitDelay is how long output pipes may stay open after a check is killed,
+// eg because it started a background process that inherited them
+const waitDelay = time.Second
+
+// ErrOutputTooLarge is returned when a check writes more than its output limit
+var ErrOutputTooLarge = errors.New("script output too large")
+
+// Nagios exit codes in order
+var nagiosStatuses = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// Check is an external executable whose output is published as a metric
+type Check struct {
+	Name           string
+	Command        string
+	Args           []string
+	Format         string        // FormatJSON or FormatNagios
+	Timeout        time.Duration // zero uses the aggregator timeout
+	Interval       time.Duration // zero uses the aggregator interval
+	MaxOutputBytes int64         // zero uses DefaultMaxOutputBytes
+}
+
+// Result is the parsed output of a check
+type Result struct {
+	Format   string `json:"format"`
+	ExitCode int    `json:"exitCode"`
+	// Data is the decoded stdout of a json check
+	Data interface{} `json:"data,omitempty"`
+	// Status, Output, LongOutput and PerfData are set for nagios checks
+	Status     string     `json:"status,omitempty"`
+	Output     string     `json:"output,omitempty"`
+	LongOutput string     `json:"longOutput,omitempty"`
+	PerfData   []PerfData `json:"perfData,omitempty"`
+}
+
+// PerfData is one nagios performance data entry, eg 'load1'=0.5;1;2;0;
+type PerfData struct {
+	Label string   `json:"label"`
+	Value float64  `json:"value"`
+	Unit  string   `json:"unit,omitempty"`
+	Warn  string   `json:"warn,omitempty"`
+	Crit  string   `json:"crit,omitempty"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+// Run executes the check and parses its output. The process is killed when
+// ctx is done. A nagios check reporting WARNING or CRITICAL is a successful
+// run; only a failure to execute the check or to parse its output is an error.
+func (c Check) Run(ctx context.Context) (*Result, error) {
+	limit := c.MaxOutputBytes
+	if limit <= 0 {
+		limit = DefaultMaxOutputBytes
+	}
+
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &limitedBuffer{limit: maxStderrBytes, truncate: true}
+
+	cmd := exec.CommandContext(ctx, c.Command, c.Args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+
+	logging.Info(logtag, fmt.Sprintf("running %s check", c.Name))
+	err := cmd.Run()
+
+	if stdout.exceeded {
+		return nil, fmt.Errorf("%s wrote more than %d bytes: %w", c.Name, limit, ErrOutputTooLarge)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		logging.Error(logtag, fmt.Sprintf("error running %s check", c.Name), err)
+		return nil, err
+	}
+
+	switch c.Format {
+	case FormatNagios:
+		if exitCode < 0 || exitCode >= len(nagiosStatuses) {
+			return nil, c.exitError(exitCode, stderr)
+		}
+		result := parseNagios(stdout.String())
+		result.ExitCode = exitCode
+		result.Status = nagiosStatuses[exitCode]
+		return result, nil
+	default:
+		if exitCode != 0 {
+			return nil, c.exitError(exitCode, stderr)
+		}
+		result := &Result{Format: FormatJSON}
+		if err := json.Unmarshal(stdout.Bytes(), &result.Data); err != nil {
+			logging.Error(logtag, fmt.Sprintf("error decoding %s output as json", c.Name), err)
+			return nil, fmt.Errorf("%s output is not valid json: %w", c.Name, err)
+		}
+		return result, nil
+	}
+}
+
+func (c Check) exitError(exitCode int, stderr *limitedBuffer) error {
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" {
+		return fmt.Errorf("%s exited with code %d", c.Name, exitCode)
+	}
+	return fmt.Errorf("%s exited with code %d: %s", c.Name, exitCode, msg)
+}
+
+// Counters exposes perfdata with the nagios counter unit "c" so the
+// aggregator can publish its rate
+func (r *Result) Counters() map[string]uint64 {
+	counters := make(map[string]uint64)
+	for _, p := range r.PerfData {
+		if p.Unit == "c" && p.Value >= 0 {
+			counters[p.Label] = uint64(p.Value)
+		}
+	}
+	return counters
+}
+
+// limitedBuffer keeps at most limit bytes. Past the limit it either fails the
+// write, which stops the check, or silently drops the rest when truncate is set.
+// The buffer is not embedded so io.Copy cannot bypass Write through ReadFrom.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	truncate bool
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - int64(b.buf.Len())
+	if int64(len(p)) <= room {
+		return b.buf.Write(p)
+	}
+
+	if room > 0 {
+		b.buf.Write(p[:room])
+	}
+	if b.truncate {
+		return len(p), nil
+	}
+	b.exceeded = true
+	return 0, ErrOutputTooLarge
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
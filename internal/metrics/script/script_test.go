@@ -0,0 +1,124 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// shellCheck returns a check running command with sh, skipping the test
+// where there is no sh
+func shellCheck(t *testing.T, format, command string) Check {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	return Check{Name: "test", Command: sh, Args: []string{"-c", command}, Format: format}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		command    string
+		maxOutput  int64
+		wantStatus string
+		wantErr    error
+		wantErrMsg string
+	}{
+		{
+			name:       "nagios warning is a result",
+			format:     FormatNagios,
+			command:    "echo 'LOAD WARNING | load1=5;4;8'; exit 1",
+			wantStatus: "WARNING",
+		},
+		{
+			name:       "nagios exit code out of range",
+			format:     FormatNagios,
+			command:    "echo oops >&2; exit 7",
+			wantErrMsg: "exited with code 7: oops",
+		},
+		{
+			name:    "json",
+			format:  FormatJSON,
+			command: `echo '{"queued": 3}'`,
+		},
+		{
+			name:       "invalid json",
+			format:     FormatJSON,
+			command:    "echo not json",
+			wantErrMsg: "not valid json",
+		},
+		{
+			name:      "output at the limit",
+			format:    FormatJSON,
+			command:   `printf '"%s"' 12345678`,
+			maxOutput: 10,
+		},
+		{
+			name:      "output over the limit",
+			format:    FormatJSON,
+			command:   `printf '"%s"' 123456789`,
+			maxOutput: 10,
+			wantErr:   ErrOutputTooLarge,
+		},
+		{
+			// the check is stopped by the failing write rather than being
+			// read to the end
+			name:      "endless output",
+			format:    FormatNagios,
+			command:   "yes",
+			maxOutput: 1024,
+			wantErr:   ErrOutputTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := shellCheck(t, tt.format, tt.command)
+			check.MaxOutputBytes = tt.maxOutput
+
+			result, err := check.Run(context.Background())
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantErrMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Fatalf("Run() error = %v, want one containing %q", err, tt.wantErrMsg)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Run() error = %v", err)
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", result.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+	if n, err := b.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("Write() = %d, %v, want 3, nil", n, err)
+	}
+	if _, err := b.Write([]byte("def")); !errors.Is(err, ErrOutputTooLarge) || !b.exceeded {
+		t.Fatalf("Write() past the limit error = %v, exceeded = %v", err, b.exceeded)
+	}
+	if got := b.String(); got != "abcde" {
+		t.Errorf("String() = %q, want %q", got, "abcde")
+	}
+
+	truncated := &limitedBuffer{limit: 2, truncate: true}
+	if n, err := truncated.Write([]byte("abc")); n != 3 || err != nil || truncated.exceeded {
+		t.Errorf("truncating Write() = %d, %v, exceeded = %v, want 3, nil, false", n, err, truncated.exceeded)
+	}
+	if got := truncated.String(); got != "ab" {
+		t.Errorf("String() = %q, want %q", got, "ab")
+	}
+}
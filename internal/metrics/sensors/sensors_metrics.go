@@ -0,0 +1,204 @@
+package sensors
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+const logtag string = "sensors"
+
+// SysfsRoot is where sysfs is mounted, eg "/host/sys" when sysmon runs in a
+// container watching its host, or a fake hwmon tree
+var SysfsRoot = "/sys"
+
+// Sensor kinds
+const (
+	KindTemperature = "temperature"
+	KindFan         = "fan"
+	KindVoltage     = "voltage"
+)
+
+// SensorsInfo holds every sensor reading, sorted by name
+type SensorsInfo struct {
+	Sensors   []Sensor `json:"sensors"`
+	AboveHigh int      `json:"aboveHigh"` // number of sensors above their high threshold
+}
+
+// Sensor is one reading. High and Critical are 0 when the chip does not
+// report them. Temperatures are in °C, fans in RPM and voltages in V.
+type Sensor struct {
+	Name          string  `json:"name"` // chip and label, eg "coretemp/Core 0"
+	Kind          string  `json:"kind"`
+	Unit          string  `json:"unit"`
+	Current       float64 `json:"current"`
+	High          float64 `json:"high,omitempty"`
+	Critical      float64 `json:"critical,omitempty"`
+	AboveHigh     bool    `json:"aboveHigh"`
+	AboveCritical bool    `json:"aboveCritical"`
+}
+
+// hwmonKinds maps the hwmon file prefixes to a kind, unit and the divisor
+// from the raw sysfs value
+var hwmonKinds = []struct {
+	prefix  string
+	kind    string
+	unit    string
+	divisor float64
+}{
+	{"temp", KindTemperature, "°C", 1000}, // millidegrees
+	{"fan", KindFan, "RPM", 1},
+	{"in", KindVoltage, "V", 1000}, // millivolts
+}
+
+// Available reports whether sensors can be read: from hwmon on Linux, or
+// where gopsutil reads temperatures from the platform, which Windows and
+// most VMs do not support
+func Available() bool {
+	if _, err := os.Stat(filepath.Join(SysfsRoot, "class", "hwmon")); err == nil {
+		return true
+	}
+	return platformSensors()
+}
+
+// platformSensors probes gopsutil once, as support does not change while
+// sysmon runs
+var platformSensors = sync.OnceValue(func() bool {
+	temps, _ := sensors.TemperaturesWithContext(context.Background())
+	return len(temps) > 0
+})
+
+func (s *SensorsInfo) Collect(ctx context.Context) error {
+	logging.Info(logtag, "collecting sensor readings")
+
+	hwmon := filepath.Join(SysfsRoot, "class", "hwmon")
+	if _, err := os.Stat(hwmon); err == nil {
+		readings, err := readHwmon(hwmon)
+		if err != nil {
+			logging.Error(logtag, "error reading hwmon sensors", err)
+			return err
+		}
+		s.Sensors = readings
+	} else {
+		// not linux, or a kernel without hwmon: gopsutil reads the
+		// platform's own interfaces and thermal zones
+		temps, err := sensors.TemperaturesWithContext(ctx)
+		if err != nil && len(temps) == 0 {
+			logging.Error(logtag, "error reading sensor temperatures", err)
+			return err
+		}
+		if err != nil {
+			logging.Error(logtag, "some sensor temperatures could not be read", err)
+		}
+		for _, t := range temps {
+			s.Sensors = append(s.Sensors, newSensor(t.SensorKey, KindTemperature, "°C", t.Temperature, t.High, t.Critical))
+		}
+	}
+
+	if s.Sensors == nil {
+		s.Sensors = []Sensor{}
+	}
+	sort.SliceStable(s.Sensors, func(i, j int) bool { return s.Sensors[i].Name < s.Sensors[j].Name })
+	for _, sensor := range s.Sensors {
+		if sensor.AboveHigh {
+			s.AboveHigh++
+		}
+	}
+	logging.Info(logtag, "successfully collected sensor readings")
+	return nil
+}
+
+func newSensor(name, kind, unit string, current, high, critical float64) Sensor {
+	return Sensor{
+		Name:          name,
+		Kind:          kind,
+		Unit:          unit,
+		Current:       current,
+		High:          high,
+		Critical:      critical,
+		AboveHigh:     high > 0 && current >= high,
+		AboveCritical: critical > 0 && current >= critical,
+	}
+}
+
+// readHwmon reads every chip under /sys/class/hwmon. A chip exposes one
+// set of files per sensor, eg temp1_input, temp1_label, temp1_max and
+// temp1_crit, either in its own directory or, on older kernels, in its
+// device directory.
+func readHwmon(dir string) ([]Sensor, error) {
+	chips, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Sensor
+	for _, chip := range chips {
+		chipDir := filepath.Join(dir, chip.Name())
+		inputs, _ := filepath.Glob(filepath.Join(chipDir, "*_input"))
+		if len(inputs) == 0 {
+			chipDir = filepath.Join(chipDir, "device")
+			inputs, _ = filepath.Glob(filepath.Join(chipDir, "*_input"))
+		}
+
+		chipName := readString(filepath.Join(dir, chip.Name(), "name"))
+		if chipName == "" {
+			chipName = readString(filepath.Join(chipDir, "name"))
+		}
+		if chipName == "" {
+			chipName = chip.Name()
+		}
+
+		for _, input := range inputs {
+			sensor := strings.TrimSuffix(filepath.Base(input), "_input") // eg temp1
+			for _, k := range hwmonKinds {
+				index := strings.TrimPrefix(sensor, k.prefix)
+				if index == sensor {
+					continue
+				}
+				if _, err := strconv.Atoi(index); err != nil {
+					continue
+				}
+
+				current, err := readValue(input)
+				if err != nil {
+					// sensors that are not wired up fail with EIO or ENODATA
+					continue
+				}
+				label := readString(filepath.Join(chipDir, sensor+"_label"))
+				if label == "" {
+					label = sensor
+				}
+				high, _ := readValue(filepath.Join(chipDir, sensor+"_max"))
+				critical, _ := readValue(filepath.Join(chipDir, sensor+"_crit"))
+
+				result = append(result, newSensor(chipName+"/"+label, k.kind, k.unit,
+					current/k.divisor, high/k.divisor, critical/k.divisor))
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readValue(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
@@ -0,0 +1,151 @@
+package sensors
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree writes files relative to root, creating their directories.
+// A name ending in "/" is created as an empty directory.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadHwmon(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []Sensor
+	}{
+		{
+			name: "labels and thresholds",
+			files: map[string]string{
+				"hwmon0/name":        "coretemp\n",
+				"hwmon0/temp1_input": "45000\n",
+				"hwmon0/temp1_label": "Package id 0\n",
+				"hwmon0/temp1_max":   "80000\n",
+				"hwmon0/temp1_crit":  "100000\n",
+				"hwmon0/temp2_input": "85000\n",
+				"hwmon0/temp2_label": "Core 0\n",
+				"hwmon0/temp2_max":   "80000\n",
+				"hwmon0/temp2_crit":  "85000\n",
+			},
+			want: []Sensor{
+				{Name: "coretemp/Package id 0", Kind: KindTemperature, Unit: "°C", Current: 45, High: 80, Critical: 100},
+				{Name: "coretemp/Core 0", Kind: KindTemperature, Unit: "°C", Current: 85, High: 80, Critical: 85, AboveHigh: true, AboveCritical: true},
+			},
+		},
+		{
+			name: "missing labels and thresholds",
+			files: map[string]string{
+				"hwmon1/name":       "nct6775\n",
+				"hwmon1/fan1_input": "1200\n",
+				"hwmon1/in0_input":  "1104\n",
+			},
+			want: []Sensor{
+				{Name: "nct6775/fan1", Kind: KindFan, Unit: "RPM", Current: 1200},
+				{Name: "nct6775/in0", Kind: KindVoltage, Unit: "V", Current: 1.104},
+			},
+		},
+		{
+			name: "device directory on older kernels",
+			files: map[string]string{
+				"hwmon2/device/name":        "it87\n",
+				"hwmon2/device/temp1_input": "30000\n",
+				"hwmon2/device/temp1_max":   "70000\n",
+			},
+			want: []Sensor{
+				{Name: "it87/temp1", Kind: KindTemperature, Unit: "°C", Current: 30, High: 70},
+			},
+		},
+		{
+			// a sensor that is not wired up fails to read with EIO; a
+			// directory in its place fails the read the same way
+			name: "unreadable inputs are skipped",
+			files: map[string]string{
+				"hwmon3/name":         "acpitz\n",
+				"hwmon3/temp1_input/": "",
+				"hwmon3/temp2_input":  "not a number\n",
+				"hwmon3/temp3_input":  "27800\n",
+			},
+			want: []Sensor{
+				{Name: "acpitz/temp3", Kind: KindTemperature, Unit: "°C", Current: 27.8},
+			},
+		},
+		{
+			name: "files that are not sensors",
+			files: map[string]string{
+				"hwmon4/name":              "thinkpad\n",
+				"hwmon4/pwm1_input":        "128\n",
+				"hwmon4/temperature_input": "1\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tt.files)
+
+			got, err := readHwmon(dir)
+			if err != nil {
+				t.Fatalf("readHwmon() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readHwmon() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectCountsAboveHigh(t *testing.T) {
+	defer func(old string) { SysfsRoot = old }(SysfsRoot)
+	SysfsRoot = t.TempDir()
+	writeTree(t, filepath.Join(SysfsRoot, "class", "hwmon"), map[string]string{
+		"hwmon0/name":        "nvme\n",
+		"hwmon0/temp1_input": "71000\n",
+		"hwmon0/temp1_max":   "70000\n",
+		"hwmon1/name":        "amdgpu\n",
+		"hwmon1/temp1_input": "50000\n",
+		"hwmon1/temp1_max":   "90000\n",
+	})
+
+	var s SensorsInfo
+	if err := s.Collect(context.Background()); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(s.Sensors) != 2 || s.Sensors[0].Name != "amdgpu/temp1" {
+		t.Fatalf("Collect() sensors = %+v, want amdgpu then nvme", s.Sensors)
+	}
+	if s.AboveHigh != 1 {
+		t.Errorf("AboveHigh = %d, want 1", s.AboveHigh)
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	defer func(old string) { SysfsRoot = old }(SysfsRoot)
+	SysfsRoot = t.TempDir()
+	writeTree(t, SysfsRoot, map[string]string{"class/hwmon/": ""})
+
+	if !Available() {
+		t.Error("Available() = false with a hwmon directory")
+	}
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"os/user"
 	"runtime"
 
@@ -10,14 +11,14 @@ import (
 const logtag string = "user"
 
 type UserInfo struct {
-	Username string
-	FullName string
-	HomeDir  string
-	Runtime  string
-	Arch     string
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+	HomeDir  string `json:"homeDir"`
+	Runtime  string `json:"runtime"`
+	Arch     string `json:"arch"`
 }
 
-func (u *UserInfo) Collect() error {
+func (u *UserInfo) Collect(ctx context.Context) error {
 	if err := u.collectUser(); err != nil {
 		return err
 	}
@@ -0,0 +1,530 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/techtacles/sysmonitoring/internal/logging"
+)
+
+const logtag string = "storage"
+
+const (
+	rawSuffix       = ".seg"
+	compactedSuffix = ".cseg"
+	tmpSuffix       = ".tmp"
+)
+
+const (
+	DefaultSegmentMaxBytes int64         = 8 * 1024 * 1024
+	DefaultSegmentMaxAge   time.Duration = time.Hour
+	DefaultRetention       time.Duration = 7 * 24 * time.Hour
+	DefaultMaxBytes        int64         = 512 * 1024 * 1024
+	DefaultCompactAfter    time.Duration = time.Hour
+	DefaultCompactStep     time.Duration = 5 * time.Minute
+)
+
+// ErrClosed is returned by Append once the store is closed
+var ErrClosed = errors.New("metric store is closed")
+
+// Record is one collector result as written to disk
+type Record struct {
+	Timestamp time.Time       `json:"ts"`
+	Metric    string          `json:"metric"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Options controls where and how much data the store keeps. Zero values
+// fall back to the defaults above.
+type Options struct {
+	Dir             string
+	SegmentMaxBytes int64         // the active segment is rolled over past this size
+	SegmentMaxAge   time.Duration // or once it is this old, so quiet hosts still compact
+	Retention       time.Duration // segments with no data newer than this are deleted
+	MaxBytes        int64         // oldest segments are deleted while the store is above this size
+	CompactAfter    time.Duration // closed segments older than this are downsampled
+	CompactStep     time.Duration // compacted segments keep one record per metric per step
+}
+
+// segment is a closed, read-only segment file
+type segment struct {
+	path      string
+	start     time.Time // time of the first record, taken from the file name
+	end       time.Time // last write to the file
+	size      int64
+	compacted bool
+}
+
+// Store is an append-only on-disk metric store. Records are written as JSON
+// lines to the active segment, which is rolled over once it grows past
+// SegmentMaxBytes or SegmentMaxAge. Closed segments are compacted and
+// removed by Maintain.
+type Store struct {
+	// maintaining serialises Maintain, which compacts without holding mu
+	maintaining sync.Mutex
+	// background tracks the maintenance started by Append, which Close
+	// waits for
+	background sync.WaitGroup
+
+	mu         sync.Mutex
+	opts       Options
+	active     *os.File
+	activePath string
+	activeSize int64
+	activeAt   time.Time
+	segments   []segment
+	closed     bool
+}
+
+// Open opens or creates a store in opts.Dir. Segments left by a previous run
+// are kept as closed segments and a fresh active segment is started.
+func Open(opts Options) (*Store, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("storage directory must not be empty")
+	}
+	if opts.SegmentMaxBytes <= 0 {
+		opts.SegmentMaxBytes = DefaultSegmentMaxBytes
+	}
+	if opts.SegmentMaxAge <= 0 {
+		opts.SegmentMaxAge = DefaultSegmentMaxAge
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.CompactAfter <= 0 {
+		opts.CompactAfter = DefaultCompactAfter
+	}
+	if opts.CompactStep <= 0 {
+		opts.CompactStep = DefaultCompactStep
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		logging.Error(logtag, "error creating storage directory", err)
+		return nil, err
+	}
+
+	s := &Store{opts: opts}
+	if err := s.loadSegments(); err != nil {
+		return nil, err
+	}
+	if err := s.Maintain(); err != nil {
+		logging.Error(logtag, "error running storage maintenance", err)
+	}
+
+	logging.Info(logtag, fmt.Sprintf("opened metric store in %s with %d segments", opts.Dir, len(s.segments)))
+	return s, nil
+}
+
+func (s *Store) loadSegments() error {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		logging.Error(logtag, "error reading storage directory", err)
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, tmpSuffix) {
+			// left over from an interrupted compaction
+			os.Remove(filepath.Join(s.opts.Dir, name))
+			continue
+		}
+
+		seg, ok := parseSegment(s.opts.Dir, entry)
+		if !ok {
+			continue
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	// a compaction interrupted after its rename leaves both the raw and the
+	// complete compacted segment
+	compacted := make(map[string]bool)
+	for _, seg := range s.segments {
+		if seg.compacted {
+			compacted[seg.path] = true
+		}
+	}
+	kept := s.segments[:0]
+	for _, seg := range s.segments {
+		if !seg.compacted && compacted[compactedPath(seg.path)] {
+			os.Remove(seg.path)
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	s.segments = kept
+
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].start.Before(s.segments[j].start)
+	})
+	return nil
+}
+
+func parseSegment(dir string, entry os.DirEntry) (segment, bool) {
+	name := entry.Name()
+	compacted := strings.HasSuffix(name, compactedSuffix)
+	if !compacted && !strings.HasSuffix(name, rawSuffix) {
+		return segment{}, false
+	}
+
+	stamp, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSuffix(name, compactedSuffix), rawSuffix), 10, 64)
+	if err != nil {
+		return segment{}, false
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return segment{}, false
+	}
+
+	return segment{
+		path:      filepath.Join(dir, name),
+		start:     time.Unix(0, stamp),
+		end:       info.ModTime(),
+		size:      info.Size(),
+		compacted: compacted,
+	}, true
+}
+
+// Append writes one record to the active segment
+func (s *Store) Append(metric string, ts time.Time, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(Record{Timestamp: ts, Metric: metric, Value: raw})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if s.active == nil {
+		if err := s.openActive(ts); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.active.Write(line)
+	s.activeSize += int64(n)
+	if err != nil {
+		logging.Error(logtag, "error writing to active segment", err)
+		return err
+	}
+
+	if s.activeSize >= s.opts.SegmentMaxBytes || ts.Sub(s.activeAt) >= s.opts.SegmentMaxAge {
+		if err := s.rollover(); err != nil {
+			return err
+		}
+		// compaction can take a while on a full segment, so it does not
+		// hold up the collector that triggered the rollover
+		s.background.Add(1)
+		go func() {
+			defer s.background.Done()
+			if err := s.Maintain(); err != nil {
+				logging.Error(logtag, "error running storage maintenance", err)
+			}
+		}()
+	}
+	return nil
+}
+
+func (s *Store) openActive(ts time.Time) error {
+	path := filepath.Join(s.opts.Dir, strconv.FormatInt(ts.UnixNano(), 10)+rawSuffix)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		logging.Error(logtag, "error opening active segment", err)
+		return err
+	}
+
+	s.active = f
+	s.activePath = path
+	s.activeSize = 0
+	s.activeAt = ts
+	return nil
+}
+
+// rollover closes the active segment. The next Append starts a new one.
+func (s *Store) rollover() error {
+	if s.active == nil {
+		return nil
+	}
+	if err := s.active.Close(); err != nil {
+		logging.Error(logtag, "error closing active segment", err)
+		return err
+	}
+
+	s.segments = append(s.segments, segment{
+		path:  s.activePath,
+		start: s.activeAt,
+		end:   time.Now(),
+		size:  s.activeSize,
+	})
+	s.active = nil
+	return nil
+}
+
+// Query returns the records for metric with since <= timestamp < until,
+// oldest first. A zero until means no upper bound.
+func (s *Store) Query(metric string, since, until time.Time) ([]Record, error) {
+	s.mu.Lock()
+	paths := make([]string, 0, len(s.segments)+1)
+	for _, seg := range s.segments {
+		if seg.end.Before(since) || (!until.IsZero() && !seg.start.Before(until)) {
+			continue
+		}
+		paths = append(paths, seg.path)
+	}
+	if s.active != nil && (until.IsZero() || s.activeAt.Before(until)) {
+		paths = append(paths, s.activePath)
+	}
+	s.mu.Unlock()
+
+	var results []Record
+	collect := func(rec Record) {
+		if rec.Metric != metric || rec.Timestamp.Before(since) {
+			return
+		}
+		if !until.IsZero() && !rec.Timestamp.Before(until) {
+			return
+		}
+		results = append(results, rec)
+	}
+	for _, path := range paths {
+		err := readSegment(path, collect)
+		if os.IsNotExist(err) && strings.HasSuffix(path, rawSuffix) {
+			// the segment was compacted since we listed it
+			err = readSegment(compactedPath(path), collect)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			logging.Error(logtag, "error reading segment", err)
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// compactedPath returns the path a raw segment is compacted to
+func compactedPath(path string) string {
+	return strings.TrimSuffix(path, rawSuffix) + compactedSuffix
+}
+
+// readSegment calls fn for every decodable record. A torn last line from a
+// crash is skipped rather than failing the whole segment.
+func readSegment(path string, fn func(Record)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		fn(rec)
+	}
+	return scanner.Err()
+}
+
+// Maintain rolls over an active segment older than SegmentMaxAge, applies
+// the retention limits and compacts old segments. Compaction runs without
+// holding the store's lock, so appends and queries carry on meanwhile.
+// Once the store is closed Maintain does nothing.
+func (s *Store) Maintain() error {
+	s.maintaining.Lock()
+	defer s.maintaining.Unlock()
+
+	now := time.Now()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.active != nil && now.Sub(s.activeAt) >= s.opts.SegmentMaxAge {
+		if err := s.rollover(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	err := s.applyRetention(now)
+	var pending []segment
+	for _, seg := range s.segments {
+		if !seg.compacted && now.Sub(seg.end) >= s.opts.CompactAfter {
+			pending = append(pending, seg)
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, seg := range pending {
+		compacted, err := s.compact(seg)
+		if err != nil {
+			return err
+		}
+
+		// swap the segment, then drop the raw file. A query that listed
+		// the raw path falls back to the compacted one. A store closed in
+		// the meantime keeps both, which the next Open resolves.
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil
+		}
+		for i := range s.segments {
+			if s.segments[i].path == seg.path {
+				s.segments[i] = compacted
+			}
+		}
+		s.mu.Unlock()
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	return s.applyRetention(now)
+}
+
+// applyRetention deletes segments past Retention and the oldest segments
+// while the store is above MaxBytes. Callers must hold s.mu.
+func (s *Store) applyRetention(now time.Time) error {
+	kept := s.segments[:0]
+	for _, seg := range s.segments {
+		if now.Sub(seg.end) > s.opts.Retention {
+			if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	s.segments = kept
+
+	total := s.activeSize
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	for len(s.segments) > 0 && total > s.opts.MaxBytes {
+		oldest := s.segments[0]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= oldest.size
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+// compact writes a compacted copy of a segment keeping only the latest
+// record per metric in every CompactStep-wide bucket. The caller removes the
+// original once the copy is in place.
+func (s *Store) compact(seg segment) (segment, error) {
+	type key struct {
+		metric string
+		bucket int64
+	}
+	latest := make(map[key]Record)
+	var order []key
+
+	err := readSegment(seg.path, func(rec Record) {
+		k := key{metric: rec.Metric, bucket: rec.Timestamp.UnixNano() / int64(s.opts.CompactStep)}
+		if _, seen := latest[k]; !seen {
+			order = append(order, k)
+		}
+		latest[k] = rec
+	})
+	if err != nil {
+		return seg, err
+	}
+
+	newPath := compactedPath(seg.path)
+	tmpPath := newPath + tmpSuffix
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return seg, err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, k := range order {
+		line, err := json.Marshal(latest[k])
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return seg, err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return seg, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return seg, err
+	}
+
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		os.Remove(tmpPath)
+		return seg, err
+	}
+	// keep the original write time so retention still sees the data's age
+	os.Chtimes(newPath, seg.end, seg.end)
+
+	info, err := os.Stat(newPath)
+	if err != nil {
+		return seg, err
+	}
+
+	logging.Info(logtag, fmt.Sprintf("compacted segment %s from %d to %d bytes", filepath.Base(seg.path), seg.size, info.Size()))
+	return segment{
+		path:      newPath,
+		start:     seg.start,
+		end:       seg.end,
+		size:      info.Size(),
+		compacted: true,
+	}, nil
+}
+
+// Close closes the active segment and waits for a running Maintain to
+// stop, so the store's files are left alone once Close returns
+func (s *Store) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.rollover()
+	s.mu.Unlock()
+
+	s.background.Wait()
+	s.maintaining.Lock()
+	s.maintaining.Unlock()
+	return err
+}
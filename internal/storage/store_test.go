@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// base is a fixed time in the recent past, so the default retention keeps
+// everything the tests write
+var base = time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+func openStore(t *testing.T, opts Options) *Store {
+	t.Helper()
+	s, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return s
+}
+
+func appendAll(t *testing.T, s *Store, metric string, offsets ...time.Duration) {
+	t.Helper()
+	for _, offset := range offsets {
+		if err := s.Append(metric, base.Add(offset), map[string]int64{"at": int64(offset / time.Second)}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+// queryAt returns the "at" values of the records of metric, in seconds
+// after base
+func queryAt(t *testing.T, s *Store, metric string, since, until time.Time) []int64 {
+	t.Helper()
+	records, err := s.Query(metric, since, until)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	at := []int64{}
+	for _, rec := range records {
+		var v struct{ At int64 }
+		if err := json.Unmarshal(rec.Value, &v); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Value, err)
+		}
+		at = append(at, v.At)
+	}
+	return at
+}
+
+// files returns the names in dir with the given suffix, sorted
+func files(t *testing.T, dir, suffix string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), suffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestOpenRequiresDir(t *testing.T) {
+	if _, err := Open(Options{}); err == nil {
+		t.Error("Open() with no directory succeeded")
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	s := openStore(t, Options{Dir: t.TempDir()})
+	defer s.Close()
+	appendAll(t, s, "cpu", 0, 10*time.Second, 20*time.Second, 30*time.Second)
+	appendAll(t, s, "memory", 5*time.Second)
+
+	tests := []struct {
+		name         string
+		metric       string
+		since, until time.Time
+		want         []int64
+	}{
+		{name: "everything", metric: "cpu", want: []int64{0, 10, 20, 30}},
+		{name: "since is inclusive", metric: "cpu", since: base.Add(10 * time.Second), want: []int64{10, 20, 30}},
+		{name: "until is exclusive", metric: "cpu", until: base.Add(20 * time.Second), want: []int64{0, 10}},
+		{name: "other metric", metric: "memory", want: []int64{5}},
+		{name: "unknown metric", metric: "disk", want: []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryAt(t, s, tt.metric, tt.since, tt.until); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollover(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		offsets      []time.Duration
+		wantSegments int
+	}{
+		{
+			// every record is over the limit on its own
+			name:         "by size",
+			opts:         Options{SegmentMaxBytes: 1},
+			offsets:      []time.Duration{0, time.Second, 2 * time.Second},
+			wantSegments: 3,
+		},
+		{
+			// the record at 2m closes the first segment, the one at 3m
+			// starts a new one
+			name:         "by age",
+			opts:         Options{SegmentMaxAge: time.Minute},
+			offsets:      []time.Duration{0, 30 * time.Second, 2 * time.Minute, 3 * time.Minute},
+			wantSegments: 2,
+		},
+		{
+			name:         "within limits",
+			offsets:      []time.Duration{0, time.Second, 2 * time.Second},
+			wantSegments: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Dir = t.TempDir()
+			s := openStore(t, tt.opts)
+			defer s.Close()
+			appendAll(t, s, "cpu", tt.offsets...)
+
+			if got := files(t, tt.opts.Dir, rawSuffix); len(got) != tt.wantSegments {
+				t.Errorf("segments = %v, want %d", got, tt.wantSegments)
+			}
+			if got := queryAt(t, s, "cpu", time.Time{}, time.Time{}); len(got) != len(tt.offsets) {
+				t.Errorf("Query() = %v, want %d records", got, len(tt.offsets))
+			}
+		})
+	}
+}
+
+func TestMaintainCompacts(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, Options{Dir: dir, SegmentMaxAge: time.Hour, CompactAfter: time.Nanosecond, CompactStep: time.Minute})
+	defer s.Close()
+	appendAll(t, s, "cpu", 0, 20*time.Second, 40*time.Second, 70*time.Second)
+	appendAll(t, s, "memory", 10*time.Second)
+
+	// the first run rolls the aged active segment over, the second
+	// compacts it
+	for i := 0; i < 2; i++ {
+		if err := s.Maintain(); err != nil {
+			t.Fatalf("Maintain() error = %v", err)
+		}
+	}
+
+	if got := files(t, dir, rawSuffix); len(got) != 0 {
+		t.Errorf("raw segments = %v, want none", got)
+	}
+	if got := files(t, dir, compactedSuffix); len(got) != 1 {
+		t.Errorf("compacted segments = %v, want one", got)
+	}
+	// one record per metric and minute, the latest of each
+	if got, want := queryAt(t, s, "cpu", time.Time{}, time.Time{}), []int64{40, 70}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cpu = %v, want %v", got, want)
+	}
+	if got, want := queryAt(t, s, "memory", time.Time{}, time.Time{}), []int64{10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("memory = %v, want %v", got, want)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, Options{Dir: dir, SegmentMaxBytes: 1})
+	appendAll(t, s, "cpu", 0, time.Second, 2*time.Second)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// age the first segment past the retention
+	segments := files(t, dir, rawSuffix)
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, segments[0]), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openStore(t, Options{Dir: dir, Retention: 24 * time.Hour})
+	defer s.Close()
+	if got, want := files(t, dir, rawSuffix), segments[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("segments = %v, want %v", got, want)
+	}
+	if got, want := queryAt(t, s, "cpu", time.Time{}, time.Time{}), []int64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, Options{Dir: dir, SegmentMaxBytes: 1})
+	appendAll(t, s, "cpu", 0, time.Second, 2*time.Second, 3*time.Second)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// every segment holds one record of the same size
+	segments := files(t, dir, rawSuffix)
+	info, err := os.Stat(filepath.Join(dir, segments[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = openStore(t, Options{Dir: dir, MaxBytes: 2*info.Size() + info.Size()/2})
+	defer s.Close()
+	if got, want := files(t, dir, rawSuffix), segments[2:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("segments = %v, want the newest two %v", got, want)
+	}
+	if got, want := queryAt(t, s, "cpu", time.Time{}, time.Time{}), []int64{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+}
+
+func TestReopenAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	record := func(offset time.Duration) string {
+		line, err := json.Marshal(Record{Timestamp: base.Add(offset), Metric: "cpu", Value: json.RawMessage(`{"at":` + strconv.Itoa(int(offset/time.Second)) + `}`)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(line) + "\n"
+	}
+	name := func(offset time.Duration) string {
+		return strconv.FormatInt(base.Add(offset).UnixNano(), 10)
+	}
+
+	// a segment cut off mid-line, a compaction that died before its
+	// rename and one that died after it
+	torn := name(0) + rawSuffix
+	writeFile(t, filepath.Join(dir, torn), record(0)+record(time.Second)+record(2 * time.Second)[:20])
+	writeFile(t, filepath.Join(dir, name(time.Minute)+compactedSuffix+tmpSuffix), record(time.Minute))
+	writeFile(t, filepath.Join(dir, name(2*time.Minute)+rawSuffix), record(2*time.Minute)+record(2*time.Minute+time.Second))
+	writeFile(t, filepath.Join(dir, name(2*time.Minute)+compactedSuffix), record(2*time.Minute+time.Second))
+
+	// a store that is never closed stands in for the crashed process
+	crashed := openStore(t, Options{Dir: dir})
+	appendAll(t, crashed, "cpu", 3*time.Minute)
+
+	s := openStore(t, Options{Dir: dir})
+	defer s.Close()
+	if got := files(t, dir, tmpSuffix); len(got) != 0 {
+		t.Errorf("temporary files = %v, want none", got)
+	}
+	if got, want := files(t, dir, compactedSuffix), []string{name(2*time.Minute) + compactedSuffix}; !reflect.DeepEqual(got, want) {
+		t.Errorf("compacted segments = %v, want %v", got, want)
+	}
+	if got, want := queryAt(t, s, "cpu", time.Time{}, time.Time{}), []int64{0, 1, 121, 180}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+}
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, Options{Dir: dir, SegmentMaxBytes: 1, CompactAfter: time.Nanosecond})
+	// every append rolls over and starts a maintenance run
+	appendAll(t, s, "cpu", 0, time.Second, 2*time.Second, 3*time.Second)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	before := files(t, dir, "")
+	if err := s.Maintain(); err != nil {
+		t.Errorf("Maintain() after Close error = %v", err)
+	}
+	if err := s.Append("cpu", base, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Append() after Close error = %v, want %v", err, ErrClosed)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if after := files(t, dir, ""); !reflect.DeepEqual(after, before) {
+		t.Errorf("files changed after Close from %v to %v", before, after)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}